@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"lang-portal/backend/media"
+	"lang-portal/backend/models"
+	"lang-portal/backend/tts"
+)
+
+var errSpeechDisabled = errors.New("text-to-speech is not configured")
+
+// GetWordAudio serves the audio for a word, generating and caching speech
+// on demand when the word has no audio yet
+func GetWordAudio(db *sql.DB, store *media.Store, speech *tts.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idStr := c.Param("id")
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			respondWithError(c, http.StatusBadRequest, "Invalid word ID")
+			return
+		}
+
+		item, err := models.GetWordAudio(db, id)
+		if err == sql.ErrNoRows {
+			item, err = generateWordAudio(c.Request.Context(), db, store, speech, id, false)
+		}
+		if err != nil {
+			respondWithAudioError(c, err)
+			return
+		}
+
+		// Word audio can be replaced, so clients must revalidate against the ETag
+		serveMedia(c, store, item, "no-cache")
+	}
+}
+
+// GenerateWordAudio (re)generates speech for a word
+func GenerateWordAudio(db *sql.DB, store *media.Store, speech *tts.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idStr := c.Param("id")
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			respondWithError(c, http.StatusBadRequest, "Invalid word ID")
+			return
+		}
+
+		force := c.Query("force") == "true"
+		item, err := generateWordAudio(c.Request.Context(), db, store, speech, id, force)
+		if err != nil {
+			respondWithAudioError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, item)
+	}
+}
+
+// GenerateGroupAudio starts a background job generating speech for every
+// word in a group
+func GenerateGroupAudio(db *sql.DB, store *media.Store, speech *tts.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if speech == nil {
+			respondWithAudioError(c, errSpeechDisabled)
+			return
+		}
+
+		idStr := c.Param("id")
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			respondWithError(c, http.StatusBadRequest, "Invalid group ID")
+			return
+		}
+
+		if _, err := models.LookupGroup(db, id); err != nil {
+			if err == sql.ErrNoRows {
+				respondWithError(c, http.StatusNotFound, "Group not found")
+				return
+			}
+			respondWithError(c, http.StatusInternalServerError, "Failed to get group")
+			return
+		}
+
+		words, err := models.GetGroupWords(db, id, models.WordFilter{})
+		if err != nil {
+			respondWithError(c, http.StatusInternalServerError, "Failed to get group words")
+			return
+		}
+
+		wordIDs := make([]int, 0, len(words))
+		for _, w := range words {
+			wordIDs = append(wordIDs, w.ID)
+		}
+
+		force := c.Query("force") == "true"
+		job := speech.Jobs.Start(wordIDs, func(ctx context.Context, wordID int) error {
+			if _, err := generateWordAudio(ctx, db, store, speech, wordID, force); err != nil {
+				return fmt.Errorf("word %d: %v", wordID, err)
+			}
+			return nil
+		})
+
+		c.JSON(http.StatusAccepted, job)
+	}
+}
+
+func GetAudioJob(speech *tts.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if speech == nil {
+			respondWithAudioError(c, errSpeechDisabled)
+			return
+		}
+
+		idStr := c.Param("id")
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			respondWithError(c, http.StatusBadRequest, "Invalid job ID")
+			return
+		}
+
+		job, ok := speech.Jobs.Get(id)
+		if !ok {
+			respondWithError(c, http.StatusNotFound, "Audio job not found")
+			return
+		}
+
+		c.JSON(http.StatusOK, job)
+	}
+}
+
+// generateWordAudio returns cached speech for the word's current text, or
+// synthesizes, stores and caches it. Older generated audio for the word is
+// replaced.
+func generateWordAudio(ctx context.Context, db *sql.DB, store *media.Store, speech *tts.Service, wordID int, force bool) (*models.WordMedia, error) {
+	word, err := models.LookupWord(db, wordID)
+	if err != nil {
+		return nil, err
+	}
+
+	if speech == nil {
+		return nil, errSpeechDisabled
+	}
+
+	if !force {
+		cached, err := models.GetCachedSpeech(db, word.ID, speech.Voice, word.Term)
+		if err == nil {
+			return cached, nil
+		}
+		if err != sql.ErrNoRows {
+			return nil, err
+		}
+	}
+
+	audio, err := speech.Provider.Synthesize(ctx, tts.Request{
+		Text:     word.Term,
+		Language: word.Language,
+		Voice:    speech.Voice,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The provider knows the format it produced, which sniffing cannot tell
+	// for MP3 without an ID3 tag
+	var item *models.WordMedia
+	_, err = store.Put(bytes.NewReader(audio.Data), media.Hint{MimeType: audio.MimeType}, func(blob *media.Blob) error {
+		item = &models.WordMedia{
+			WordID:     word.ID,
+			Kind:       "audio",
+			Hash:       blob.Hash,
+			MimeType:   blob.MimeType,
+			Size:       blob.Size,
+			Filename:   "speech-" + strconv.Itoa(word.ID),
+			Source:     models.MediaSourceTTS,
+			Voice:      speech.Voice,
+			SourceText: word.Term,
+		}
+		return models.CreateWordMedia(db, item)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	stale, err := models.DeleteStaleSpeech(db, word.ID, item.ID)
+	if err != nil {
+		return nil, err
+	}
+	removeOrphanedMedia(db, store, stale)
+
+	return item, nil
+}
+
+func respondWithAudioError(c *gin.Context, err error) {
+	switch err {
+	case sql.ErrNoRows:
+		respondWithError(c, http.StatusNotFound, "Word not found")
+	case errSpeechDisabled:
+		respondWithError(c, http.StatusServiceUnavailable, err.Error())
+	case media.ErrTooLarge, media.ErrUnsupportedType:
+		respondWithError(c, http.StatusBadGateway, "Speech service returned unusable audio: "+err.Error())
+	default:
+		respondWithError(c, http.StatusBadGateway, "Failed to generate audio")
+	}
+}
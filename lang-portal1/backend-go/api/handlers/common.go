@@ -1,17 +1,24 @@
 package handlers
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"lang-portal/backend/language"
+	"lang-portal/backend/models"
 )
 
 type PaginatedResponse struct {
-	Items         interface{} `json:"items"`
-	CurrentPage   int        `json:"current_page"`
-	TotalPages    int        `json:"total_pages"`
-	TotalItems    int        `json:"total_items"`
-	ItemsPerPage  int        `json:"items_per_page"`
+	Items        interface{} `json:"items"`
+	CurrentPage  int         `json:"current_page"`
+	TotalPages   int         `json:"total_pages"`
+	TotalItems   int         `json:"total_items"`
+	ItemsPerPage int         `json:"items_per_page"`
 }
 
 type ErrorResponse struct {
@@ -44,10 +51,119 @@ func respondWithError(c *gin.Context, code int, message string) {
 func newPaginatedResponse(items interface{}, currentPage, totalItems, perPage int) PaginatedResponse {
 	totalPages := (totalItems + perPage - 1) / perPage
 	return PaginatedResponse{
-		Items:         items,
-		CurrentPage:   currentPage,
-		TotalPages:    totalPages,
-		TotalItems:    totalItems,
-		ItemsPerPage:  perPage,
+		Items:        items,
+		CurrentPage:  currentPage,
+		TotalPages:   totalPages,
+		TotalItems:   totalItems,
+		ItemsPerPage: perPage,
 	}
 }
+
+// getLanguageParam reads the optional ?language= filter. It responds with
+// 400 and returns false for unsupported languages.
+func getLanguageParam(c *gin.Context) (string, bool) {
+	code := strings.ToLower(c.Query("language"))
+	if code == "" {
+		return "", true
+	}
+	if _, ok := language.Get(code); !ok {
+		respondWithError(c, http.StatusBadRequest, "Unsupported language")
+		return "", false
+	}
+	return code, true
+}
+
+// respondWithValidationError responds with 400 for validation errors and
+// reports whether err was one
+func respondWithValidationError(c *gin.Context, err error) bool {
+	var validationErr *models.ValidationError
+	if errors.As(err, &validationErr) {
+		respondWithError(c, http.StatusBadRequest, validationErr.Error())
+		return true
+	}
+	return false
+}
+
+// getFuriganaParam reads the optional ?furigana= format for word lists. It
+// responds with 400 and returns false for unknown formats.
+func getFuriganaParam(c *gin.Context) (string, bool) {
+	format := c.Query("furigana")
+	switch format {
+	case "", language.FuriganaHTML, language.FuriganaAnki, language.FuriganaJSON:
+		return format, true
+	}
+	respondWithError(c, http.StatusBadRequest, "Invalid furigana format")
+	return "", false
+}
+
+// bindPatch reads a JSON Merge Patch document from the request body. It
+// responds with 400 and returns false if the body is not a JSON object.
+func bindPatch(c *gin.Context) (models.Patch, bool) {
+	body, err := c.GetRawData()
+	if err != nil {
+		respondWithError(c, http.StatusBadRequest, "Invalid request body")
+		return nil, false
+	}
+
+	var patch models.Patch
+	if err := json.Unmarshal(body, &patch); err != nil || patch == nil {
+		respondWithError(c, http.StatusBadRequest, "Invalid request body")
+		return nil, false
+	}
+	return patch, true
+}
+
+// getSortOrderParam reads ?order=asc|desc and reports whether it is
+// descending. It responds with 400 and returns false for other values.
+func getSortOrderParam(c *gin.Context) (desc bool, ok bool) {
+	switch c.DefaultQuery("order", "asc") {
+	case "asc":
+		return false, true
+	case "desc":
+		return true, true
+	}
+	respondWithError(c, http.StatusBadRequest, "Invalid sort order")
+	return false, false
+}
+
+// getAttemptsParam reads ?attempts=all|first, which selects whether stats
+// count every attempt at a word in a session or only the first. It responds
+// with 400 and returns false for other values.
+func getAttemptsParam(c *gin.Context) (models.Attempts, bool) {
+	switch attempts := models.Attempts(c.DefaultQuery("attempts", string(models.AttemptsAll))); attempts {
+	case models.AttemptsAll, models.AttemptsFirst:
+		return attempts, true
+	}
+	respondWithError(c, http.StatusBadRequest, "Invalid attempts, use all or first")
+	return "", false
+}
+
+// getIntParam reads an optional integer query parameter between min and
+// max. It responds with 400 and returns false for other values.
+func getIntParam(c *gin.Context, name string, fallback, min, max int) (int, bool) {
+	str := c.Query(name)
+	if str == "" {
+		return fallback, true
+	}
+	value, err := strconv.Atoi(str)
+	if err != nil || value < min || value > max {
+		respondWithError(c, http.StatusBadRequest, fmt.Sprintf("Invalid %s, must be between %d and %d", name, min, max))
+		return 0, false
+	}
+	return value, true
+}
+
+// getSuspendedParam reads the optional ?suspended=true|false filter. It
+// responds with 400 and returns false for other values.
+func getSuspendedParam(c *gin.Context) (*bool, bool) {
+	str := c.Query("suspended")
+	if str == "" {
+		return nil, true
+	}
+	suspended, err := strconv.ParseBool(str)
+	if err != nil {
+		respondWithError(c, http.StatusBadRequest, "Invalid suspended filter")
+		return nil, false
+	}
+	return &suspended, true
+}
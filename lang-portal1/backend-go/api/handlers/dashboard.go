@@ -10,7 +10,12 @@ import (
 
 func GetLastStudySession(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		session, err := models.GetLastStudySession(db)
+		attempts, ok := getAttemptsParam(c)
+		if !ok {
+			return
+		}
+
+		session, err := models.GetLastStudySession(db, attempts)
 		if err != nil {
 			if err == sql.ErrNoRows {
 				c.JSON(http.StatusOK, nil)
@@ -26,7 +31,12 @@ func GetLastStudySession(db *sql.DB) gin.HandlerFunc {
 
 func GetDailyStudyProgress(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		progress, err := models.GetStudyProgress(db)
+		attempts, ok := getAttemptsParam(c)
+		if !ok {
+			return
+		}
+
+		progress, err := models.GetStudyProgress(db, attempts)
 		if err != nil {
 			respondWithError(c, http.StatusInternalServerError, "Failed to get study progress")
 			return
@@ -38,7 +48,12 @@ func GetDailyStudyProgress(db *sql.DB) gin.HandlerFunc {
 
 func GetQuickStats(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		stats, err := models.GetQuickStats(db)
+		attempts, ok := getAttemptsParam(c)
+		if !ok {
+			return
+		}
+
+		stats, err := models.GetQuickStats(db, attempts)
 		if err != nil {
 			respondWithError(c, http.StatusInternalServerError, "Failed to get quick stats")
 			return
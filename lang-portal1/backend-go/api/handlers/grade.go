@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"lang-portal/backend/models"
+)
+
+type GradeRequest struct {
+	WordID int     `json:"word_id" binding:"required"`
+	Answer *string `json:"answer" binding:"required"`
+	// Expect is any (the default), meaning, reading or term
+	Expect string `json:"expect"`
+}
+
+// GradeReviewRequest grades an answer and records it as a review
+type GradeReviewRequest struct {
+	Answer         *string `json:"answer" binding:"required"`
+	Expect         string  `json:"expect"`
+	ResponseTimeMs *int    `json:"response_time_ms"`
+	HintUsed       bool    `json:"hint_used"`
+	ClientApp      string  `json:"client_app"`
+}
+
+type GradeResponse struct {
+	*models.Grade
+	Review *models.WordReviewItem `json:"review,omitempty"`
+}
+
+// partialQuality grades partial answers when they are recorded: recalled,
+// but with serious difficulty
+const partialQuality = 3
+
+// gradeWord grades an answer against a word. It responds with an error and
+// returns false if that is not possible.
+func gradeWord(c *gin.Context, db *sql.DB, wordID int, answer, expect string) (*models.Word, *models.Grade, bool) {
+	word, err := models.LookupWord(db, wordID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			respondWithError(c, http.StatusNotFound, "Word not found")
+			return nil, nil, false
+		}
+		respondWithError(c, http.StatusInternalServerError, "Failed to get word")
+		return nil, nil, false
+	}
+
+	grade, err := models.GradeAnswer(*word, answer, expect)
+	if err != nil {
+		if !respondWithValidationError(c, err) {
+			respondWithError(c, http.StatusInternalServerError, "Failed to grade answer")
+		}
+		return nil, nil, false
+	}
+	return word, grade, true
+}
+
+// GradeAnswer grades a typed answer against a word without recording it
+func GradeAnswer(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req GradeRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondWithError(c, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+
+		_, grade, ok := gradeWord(c, db, req.WordID, *req.Answer, req.Expect)
+		if !ok {
+			return
+		}
+
+		c.JSON(http.StatusOK, GradeResponse{Grade: grade})
+	}
+}
+
+// GradeWordReview grades a typed answer against a word and records the
+// result as a review in the session. Partial answers are recorded as
+// correct with a quality of 3.
+func GradeWordReview(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sessionID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			respondWithError(c, http.StatusBadRequest, "Invalid session ID")
+			return
+		}
+		wordID, err := strconv.Atoi(c.Param("word_id"))
+		if err != nil {
+			respondWithError(c, http.StatusBadRequest, "Invalid word ID")
+			return
+		}
+
+		var req GradeReviewRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondWithError(c, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+
+		word, grade, ok := gradeWord(c, db, wordID, *req.Answer, req.Expect)
+		if !ok {
+			return
+		}
+
+		correct := grade.Result != models.GradeIncorrect
+		review := models.ReviewInput{
+			WordID:  word.ID,
+			Correct: &correct,
+			ReviewDetails: models.ReviewDetails{
+				Answer:         *req.Answer,
+				ExpectedAnswer: grade.Expected,
+				ResponseTimeMs: req.ResponseTimeMs,
+				HintUsed:       req.HintUsed,
+				ClientApp:      req.ClientApp,
+			},
+		}
+		if grade.Result == models.GradePartial {
+			quality := partialQuality
+			review.Quality = &quality
+		}
+		stored, err := models.AddWordReview(db, sessionID, review)
+		if err != nil {
+			respondWithSessionError(c, err, "Failed to record review")
+			return
+		}
+
+		c.JSON(http.StatusCreated, GradeResponse{Grade: grade, Review: stored})
+	}
+}
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"lang-portal/backend/models"
+)
+
+type WordIDsRequest struct {
+	WordIDs []int `json:"word_ids" binding:"required"`
+}
+
+type CloneGroupRequest struct {
+	Name string `json:"name"`
+}
+
+type MergeGroupsRequest struct {
+	GroupIDs    []int `json:"group_ids" binding:"required"`
+	KeepSources bool  `json:"keep_sources"`
+}
+
+type CombineGroupsRequest struct {
+	GroupIDs    []int  `json:"group_ids" binding:"required"`
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description"`
+	ParentID    *int   `json:"parent_id"`
+}
+
+// respondWithGroupError maps errors from group operations, using message
+// for unexpected ones
+func respondWithGroupError(c *gin.Context, err error, message string) {
+	switch err {
+	case sql.ErrNoRows:
+		respondWithError(c, http.StatusNotFound, "Group not found")
+	case models.ErrSmartGroup:
+		respondWithError(c, http.StatusConflict, "Words cannot be added to or removed from a smart group")
+	case models.ErrMergeIntoSelf:
+		respondWithError(c, http.StatusBadRequest, err.Error())
+	default:
+		if !respondWithValidationError(c, err) {
+			respondWithError(c, http.StatusInternalServerError, message)
+		}
+	}
+}
+
+// changeGroupWords builds the handlers for bulk membership changes
+func changeGroupWords(db *sql.DB, change func(db *sql.DB, groupID int, wordIDs []int) (*models.MembershipChange, error)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idStr := c.Param("id")
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			respondWithError(c, http.StatusBadRequest, "Invalid group ID")
+			return
+		}
+
+		var req WordIDsRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondWithError(c, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+
+		result, err := change(db, id, req.WordIDs)
+		if err != nil {
+			respondWithGroupError(c, err, "Failed to update group words")
+			return
+		}
+
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// AddWordsToGroup adds a list of words to a group, skipping those already
+// in it
+func AddWordsToGroup(db *sql.DB) gin.HandlerFunc {
+	return changeGroupWords(db, models.AddWordsToGroup)
+}
+
+// RemoveWordsFromGroup removes a list of words from a group
+func RemoveWordsFromGroup(db *sql.DB) gin.HandlerFunc {
+	return changeGroupWords(db, models.RemoveWordsFromGroup)
+}
+
+// ReplaceGroupWords makes a list of words the exact contents of a group
+func ReplaceGroupWords(db *sql.DB) gin.HandlerFunc {
+	return changeGroupWords(db, models.ReplaceGroupWords)
+}
+
+func CloneGroup(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idStr := c.Param("id")
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			respondWithError(c, http.StatusBadRequest, "Invalid group ID")
+			return
+		}
+
+		// The body is optional
+		var req CloneGroupRequest
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				respondWithError(c, http.StatusBadRequest, "Invalid request body")
+				return
+			}
+		}
+
+		group, err := models.CloneGroup(db, id, req.Name)
+		if err != nil {
+			respondWithGroupError(c, err, "Failed to clone group")
+			return
+		}
+
+		c.JSON(http.StatusCreated, group)
+	}
+}
+
+func MergeGroups(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idStr := c.Param("id")
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			respondWithError(c, http.StatusBadRequest, "Invalid group ID")
+			return
+		}
+
+		var req MergeGroupsRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondWithError(c, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+
+		result, err := models.MergeGroups(db, id, req.GroupIDs, req.KeepSources)
+		if err != nil {
+			respondWithGroupError(c, err, "Failed to merge groups")
+			return
+		}
+
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// CombineGroups creates a group from the union, intersection or difference
+// of other groups
+func CombineGroups(db *sql.DB, operation string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req CombineGroupsRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondWithError(c, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+
+		group := &models.Group{
+			ParentID:    req.ParentID,
+			Name:        req.Name,
+			Description: req.Description,
+		}
+		if err := models.CombineGroups(db, operation, req.GroupIDs, group); err != nil {
+			respondWithGroupError(c, err, "Failed to combine groups")
+			return
+		}
+
+		c.JSON(http.StatusCreated, group)
+	}
+}
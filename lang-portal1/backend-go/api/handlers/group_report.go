@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"lang-portal/backend/models"
+)
+
+const defaultWeakestWords = 5
+
+// GetGroupReport returns the mastery matrix of a group as JSON, or as a CSV
+// download with ?format=csv
+func GetGroupReport(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idStr := c.Param("id")
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			respondWithError(c, http.StatusBadRequest, "Invalid group ID")
+			return
+		}
+
+		format := c.DefaultQuery("format", "json")
+		if format != "json" && format != "csv" {
+			respondWithError(c, http.StatusBadRequest, "Invalid report format")
+			return
+		}
+
+		weakest, err := strconv.Atoi(c.DefaultQuery("weakest", strconv.Itoa(defaultWeakestWords)))
+		if err != nil || weakest < 0 {
+			respondWithError(c, http.StatusBadRequest, "Invalid weakest word count")
+			return
+		}
+
+		report, err := models.GetGroupReport(db, id, weakest)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				respondWithError(c, http.StatusNotFound, "Group not found")
+				return
+			}
+			respondWithError(c, http.StatusInternalServerError, "Failed to build group report")
+			return
+		}
+
+		if format == "csv" {
+			writeGroupReportCSV(c, report)
+			return
+		}
+		c.JSON(http.StatusOK, report)
+	}
+}
+
+// writeGroupReportCSV writes one row per word with a column per session
+func writeGroupReportCSV(c *gin.Context, report *models.GroupReport) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	header := []string{"word_id", "term", "romanization"}
+	for _, s := range report.Sessions {
+		header = append(header, fmt.Sprintf("session %d (%s)", s.ID, s.CreatedAt.Format("2006-01-02")))
+	}
+	header = append(header, "correct", "wrong", "accuracy", "trend", "mastered")
+	w.Write(header)
+
+	for _, word := range report.Words {
+		record := []string{strconv.Itoa(word.WordID), word.Term, word.Romanization}
+		for _, result := range word.Results {
+			cell := ""
+			if result != nil {
+				cell = *result
+			}
+			record = append(record, cell)
+		}
+		accuracy := ""
+		if word.Accuracy != nil {
+			accuracy = strconv.FormatFloat(*word.Accuracy, 'f', 1, 64)
+		}
+		record = append(record,
+			strconv.Itoa(word.CorrectCount),
+			strconv.Itoa(word.WrongCount),
+			accuracy,
+			word.Trend,
+			strconv.FormatBool(word.Mastered),
+		)
+		w.Write(escapeFormulas(record))
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		respondWithError(c, http.StatusInternalServerError, "Failed to write group report")
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="group-%d-report.csv"`, report.GroupID))
+	c.Data(http.StatusOK, "text/csv; charset=utf-8", buf.Bytes())
+}
+
+// escapeFormulas prefixes the cells that spreadsheets would evaluate as
+// formulas with a quote, so that words cannot inject formulas into reports
+func escapeFormulas(record []string) []string {
+	for i, cell := range record {
+		if cell != "" && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+			record[i] = "'" + cell
+		}
+	}
+	return record
+}
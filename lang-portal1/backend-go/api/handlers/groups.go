@@ -1,9 +1,13 @@
 package handlers
 
 import (
+	"bytes"
 	"database/sql"
+	"encoding/json"
+	"io"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"lang-portal/backend/models"
@@ -13,8 +17,25 @@ func GetGroups(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		page, perPage := getPaginationParams(c)
 
-		groups, total, err := models.GetGroups(db, page, perPage)
+		lang, ok := getLanguageParam(c)
+		if !ok {
+			return
+		}
+
+		filter := models.GroupFilter{Language: lang, Sort: c.Query("sort")}
+		if filter.Desc, ok = getSortOrderParam(c); !ok {
+			return
+		}
+		if filter.Attempts, ok = getAttemptsParam(c); !ok {
+			return
+		}
+
+		groups, total, err := models.GetGroups(db, filter, page, perPage)
 		if err != nil {
+			if err == models.ErrInvalidSort {
+				respondWithError(c, http.StatusBadRequest, "Invalid sort field")
+				return
+			}
 			respondWithError(c, http.StatusInternalServerError, "Failed to get groups")
 			return
 		}
@@ -32,7 +53,12 @@ func GetGroup(db *sql.DB) gin.HandlerFunc {
 			return
 		}
 
-		group, err := models.GetGroup(db, id)
+		attempts, ok := getAttemptsParam(c)
+		if !ok {
+			return
+		}
+
+		group, err := models.GetGroup(db, id, attempts)
 		if err != nil {
 			if err == sql.ErrNoRows {
 				respondWithError(c, http.StatusNotFound, "Group not found")
@@ -42,6 +68,14 @@ func GetGroup(db *sql.DB) gin.HandlerFunc {
 			return
 		}
 
+		if c.Query("include_descendants") == "true" {
+			group.Words, err = models.GetGroupTreeWords(db, id, models.WordFilter{})
+			if err != nil {
+				respondWithError(c, http.StatusInternalServerError, "Failed to get group words")
+				return
+			}
+		}
+
 		c.JSON(http.StatusOK, group)
 	}
 }
@@ -55,23 +89,112 @@ func GetGroupWords(db *sql.DB) gin.HandlerFunc {
 			return
 		}
 
-		words, err := models.GetGroupWords(db, id)
+		page, perPage := getPaginationParams(c)
+
+		lang, ok := getLanguageParam(c)
+		if !ok {
+			return
+		}
+
+		furigana, ok := getFuriganaParam(c)
+		if !ok {
+			return
+		}
+
+		query := models.GroupWordsQuery{
+			WordFilter:         models.WordFilter{Language: lang, Search: strings.TrimSpace(c.Query("search"))},
+			IncludeDescendants: c.Query("include_descendants") == "true",
+			Sort:               c.Query("sort"),
+		}
+		if query.Desc, ok = getSortOrderParam(c); !ok {
+			return
+		}
+		if query.Attempts, ok = getAttemptsParam(c); !ok {
+			return
+		}
+		if query.Suspended, ok = getSuspendedParam(c); !ok {
+			return
+		}
+
+		words, total, err := models.GetGroupWordsPage(db, id, query, page, perPage)
 		if err != nil {
 			if err == sql.ErrNoRows {
 				respondWithError(c, http.StatusNotFound, "Group not found")
 				return
 			}
+			if err == models.ErrInvalidSort {
+				respondWithError(c, http.StatusBadRequest, "Invalid sort field")
+				return
+			}
 			respondWithError(c, http.StatusInternalServerError, "Failed to get group words")
 			return
 		}
 
-		c.JSON(http.StatusOK, words)
+		if furigana != "" {
+			for i := range words {
+				if err := words[i].AddFurigana(furigana); err != nil {
+					respondWithError(c, http.StatusInternalServerError, "Failed to render furigana")
+					return
+				}
+			}
+		}
+
+		c.JSON(http.StatusOK, newPaginatedResponse(words, page, total, perPage))
+	}
+}
+
+func GetGroupChildren(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idStr := c.Param("id")
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			respondWithError(c, http.StatusBadRequest, "Invalid group ID")
+			return
+		}
+
+		children, err := models.GetGroupChildren(db, id)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				respondWithError(c, http.StatusNotFound, "Group not found")
+				return
+			}
+			respondWithError(c, http.StatusInternalServerError, "Failed to get child groups")
+			return
+		}
+
+		c.JSON(http.StatusOK, children)
 	}
 }
 
 type CreateGroupRequest struct {
-	Name        string `json:"name" binding:"required"`
-	Description string `json:"description"`
+	ParentID     *int            `json:"parent_id"`
+	Name         string          `json:"name" binding:"required"`
+	Description  string          `json:"description"`
+	Color        string          `json:"color"`
+	Icon         string          `json:"icon"`
+	SortPosition int             `json:"sort_position"`
+	Kind         string          `json:"kind"`
+	Filter       json.RawMessage `json:"filter"`
+}
+
+func (req CreateGroupRequest) toGroup() (*models.Group, error) {
+	group := &models.Group{
+		ParentID:     req.ParentID,
+		Name:         req.Name,
+		Description:  req.Description,
+		Color:        req.Color,
+		Icon:         req.Icon,
+		SortPosition: req.SortPosition,
+		Kind:         req.Kind,
+	}
+	if len(req.Filter) > 0 && string(req.Filter) != "null" {
+		filter, err := models.ParseSmartFilter(req.Filter)
+		if err != nil {
+			return nil, err
+		}
+		group.Filter = filter
+	}
+	return group, nil
 }
 
 func CreateGroup(db *sql.DB) gin.HandlerFunc {
@@ -82,13 +205,21 @@ func CreateGroup(db *sql.DB) gin.HandlerFunc {
 			return
 		}
 
-		group := &models.Group{
-			Name:        req.Name,
-			Description: req.Description,
+		group, err := req.toGroup()
+		if err == nil {
+			err = group.Normalize()
+		}
+		if err != nil {
+			if !respondWithValidationError(c, err) {
+				respondWithError(c, http.StatusInternalServerError, "Failed to validate group")
+			}
+			return
 		}
 
 		if err := models.CreateGroup(db, group); err != nil {
-			respondWithError(c, http.StatusInternalServerError, "Failed to create group")
+			if !respondWithValidationError(c, err) {
+				respondWithError(c, http.StatusInternalServerError, "Failed to create group")
+			}
 			return
 		}
 
@@ -105,19 +236,113 @@ func UpdateGroup(db *sql.DB) gin.HandlerFunc {
 			return
 		}
 
+		body, err := c.GetRawData()
+		if err != nil {
+			respondWithError(c, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
 		var req CreateGroupRequest
-		if err := c.ShouldBindJSON(&req); err != nil {
+		var fields map[string]json.RawMessage
+		if err := c.ShouldBindJSON(&req); err != nil || json.Unmarshal(body, &fields) != nil {
 			respondWithError(c, http.StatusBadRequest, "Invalid request body")
 			return
 		}
 
-		group := &models.Group{
-			ID:   id,
-			Name: req.Name,
+		existing, err := models.LookupGroup(db, id)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				respondWithError(c, http.StatusNotFound, "Group not found")
+				return
+			}
+			respondWithError(c, http.StatusInternalServerError, "Failed to get group")
+			return
+		}
+
+		group, err := req.toGroup()
+		if err == nil {
+			group.ID = id
+			keepOmittedGroupFields(group, existing, fields)
+			err = group.Normalize()
+		}
+		if err != nil {
+			if !respondWithValidationError(c, err) {
+				respondWithError(c, http.StatusInternalServerError, "Failed to validate group")
+			}
+			return
 		}
 
 		if err := models.UpdateGroup(db, group); err != nil {
-			respondWithError(c, http.StatusInternalServerError, "Failed to update group")
+			if err == sql.ErrNoRows {
+				respondWithError(c, http.StatusNotFound, "Group not found")
+				return
+			}
+			if !respondWithValidationError(c, err) {
+				respondWithError(c, http.StatusInternalServerError, "Failed to update group")
+			}
+			return
+		}
+
+		c.JSON(http.StatusOK, group)
+	}
+}
+
+// keepOmittedGroupFields keeps the nesting, kind and filter of a group
+// when a PUT leaves them out, so that renaming a group neither un-nests it
+// nor turns a smart group into a manual one. A null parent_id still moves
+// the group to the top level.
+func keepOmittedGroupFields(group, existing *models.Group, fields map[string]json.RawMessage) {
+	if _, ok := fields["parent_id"]; !ok {
+		group.ParentID = existing.ParentID
+	}
+	if _, ok := fields["kind"]; !ok {
+		if _, ok := fields["filter"]; !ok {
+			group.Kind, group.Filter = existing.Kind, existing.Filter
+		}
+	}
+}
+
+// PatchGroup applies a JSON Merge Patch, changing only the supplied fields
+func PatchGroup(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idStr := c.Param("id")
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			respondWithError(c, http.StatusBadRequest, "Invalid group ID")
+			return
+		}
+
+		patch, ok := bindPatch(c)
+		if !ok {
+			return
+		}
+
+		group, err := models.LookupGroup(db, id)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				respondWithError(c, http.StatusNotFound, "Group not found")
+				return
+			}
+			respondWithError(c, http.StatusInternalServerError, "Failed to get group")
+			return
+		}
+
+		if err := group.ApplyPatch(patch); err != nil {
+			if !respondWithValidationError(c, err) {
+				respondWithError(c, http.StatusInternalServerError, "Failed to validate group")
+			}
+			return
+		}
+
+		if err := models.UpdateGroup(db, group); err != nil {
+			if err == sql.ErrNoRows {
+				respondWithError(c, http.StatusNotFound, "Group not found")
+				return
+			}
+			if !respondWithValidationError(c, err) {
+				respondWithError(c, http.StatusInternalServerError, "Failed to update group")
+			}
 			return
 		}
 
@@ -161,11 +386,7 @@ func AddWordToGroup(db *sql.DB) gin.HandlerFunc {
 		}
 
 		if err := models.AddWordToGroup(db, groupID, wordID); err != nil {
-			if err == sql.ErrNoRows {
-				respondWithError(c, http.StatusNotFound, "Group or word not found")
-				return
-			}
-			respondWithError(c, http.StatusInternalServerError, "Failed to add word to group")
+			respondWithMembershipError(c, err, "Failed to add word to group")
 			return
 		}
 
@@ -191,11 +412,7 @@ func RemoveWordFromGroup(db *sql.DB) gin.HandlerFunc {
 		}
 
 		if err := models.RemoveWordFromGroup(db, groupID, wordID); err != nil {
-			if err == sql.ErrNoRows {
-				respondWithError(c, http.StatusNotFound, "Group or word not found")
-				return
-			}
-			respondWithError(c, http.StatusInternalServerError, "Failed to remove word from group")
+			respondWithMembershipError(c, err, "Failed to remove word from group")
 			return
 		}
 
@@ -203,6 +420,19 @@ func RemoveWordFromGroup(db *sql.DB) gin.HandlerFunc {
 	}
 }
 
+// respondWithMembershipError maps errors from changing group membership,
+// using message for unexpected ones
+func respondWithMembershipError(c *gin.Context, err error, message string) {
+	switch err {
+	case sql.ErrNoRows:
+		respondWithError(c, http.StatusNotFound, "Group or word not found")
+	case models.ErrSmartGroup:
+		respondWithError(c, http.StatusConflict, "Words cannot be added to or removed from a smart group")
+	default:
+		respondWithError(c, http.StatusInternalServerError, message)
+	}
+}
+
 func GetGroupStudySessions(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		idStr := c.Param("id")
@@ -214,7 +444,13 @@ func GetGroupStudySessions(db *sql.DB) gin.HandlerFunc {
 
 		page, perPage := getPaginationParams(c)
 
-		sessions, total, err := models.GetStudySessions(db, id, page, perPage)
+		lang, ok := getLanguageParam(c)
+		if !ok {
+			return
+		}
+
+		filter := models.StudySessionFilter{GroupID: id, Language: lang}
+		sessions, total, err := models.GetStudySessions(db, filter, page, perPage)
 		if err != nil {
 			respondWithError(c, http.StatusInternalServerError, "Failed to get group study sessions")
 			return
@@ -24,6 +24,29 @@ func ResetHistory(db *sql.DB) gin.HandlerFunc {
 			return
 		}
 
+		// Delete all scheduling state derived from the reviews
+		_, err = tx.Exec("DELETE FROM word_srs_state")
+		if err != nil {
+			respondWithError(c, http.StatusInternalServerError, "Failed to delete review schedules")
+			return
+		}
+
+		// Delete the word queues, scopes and quizzes of the study sessions
+		for _, table := range []string{"study_session_queue", "study_session_groups", "study_session_words"} {
+			if _, err = tx.Exec("DELETE FROM " + table); err != nil {
+				respondWithError(c, http.StatusInternalServerError, "Failed to delete session words")
+				return
+			}
+		}
+		_, err = tx.Exec("DELETE FROM quiz_questions")
+		if err == nil {
+			_, err = tx.Exec("DELETE FROM quizzes")
+		}
+		if err != nil {
+			respondWithError(c, http.StatusInternalServerError, "Failed to delete quizzes")
+			return
+		}
+
 		// Delete all study sessions
 		_, err = tx.Exec("DELETE FROM study_sessions")
 		if err != nil {
@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"lang-portal/backend/language"
+)
+
+type LanguageResponse struct {
+	Code string `json:"code"`
+	Name string `json:"name"`
+}
+
+func GetLanguages() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var languages []LanguageResponse
+		for _, l := range language.All() {
+			languages = append(languages, LanguageResponse{Code: l.Code(), Name: l.Name()})
+		}
+
+		c.JSON(http.StatusOK, languages)
+	}
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"lang-portal/backend/launch"
+	"lang-portal/backend/models"
+)
+
+type LaunchStudyActivityRequest struct {
+	SessionTargetRequest
+	QueueRequest
+}
+
+type LaunchResponse struct {
+	Session   *models.StudySessionDetail `json:"session"`
+	LaunchURL string                     `json:"launch_url"`
+	Token     string                     `json:"token"`
+	ExpiresAt time.Time                  `json:"expires_at"`
+}
+
+// LaunchStudyActivity starts a study session of an activity and returns the
+// URL to open it with, carrying the session, group, API base URL and a
+// token scoped to the session
+func LaunchStudyActivity(db *sql.DB, launcher *launch.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idStr := c.Param("id")
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			respondWithError(c, http.StatusBadRequest, "Invalid activity ID")
+			return
+		}
+
+		var req LaunchStudyActivityRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondWithError(c, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+
+		target, err := req.toSessionTarget()
+		if err != nil {
+			respondWithValidationError(c, err)
+			return
+		}
+
+		session, activity, ok := startStudySession(c, db, target, id, req.toQueueSpec())
+		if !ok {
+			return
+		}
+
+		var groupID int
+		if session.GroupID != nil {
+			groupID = *session.GroupID
+		}
+		token, expires := launcher.Token(session.ID)
+		launchURL, err := launch.URL(activity.LaunchURL, launch.Params{
+			SessionID:  session.ID,
+			GroupID:    groupID,
+			APIBaseURL: apiBaseURL(c, launcher),
+			Token:      token,
+		})
+		if err != nil {
+			respondWithError(c, http.StatusInternalServerError, "Failed to build launch URL")
+			return
+		}
+
+		c.JSON(http.StatusCreated, LaunchResponse{
+			Session:   session,
+			LaunchURL: launchURL,
+			Token:     token,
+			ExpiresAt: expires,
+		})
+	}
+}
+
+// apiBaseURL is the configured API base URL, or the one the request came in
+// on. X-Forwarded-Proto is only honored from trusted proxies.
+func apiBaseURL(c *gin.Context, launcher *launch.Service) string {
+	if launcher.APIBaseURL != "" {
+		return launcher.APIBaseURL
+	}
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	if proto := c.GetHeader("X-Forwarded-Proto"); proto != "" && launcher.TrustsProxy(c.RemoteIP()) {
+		scheme = proto
+	}
+	return scheme + "://" + c.Request.Host + "/api"
+}
+
+// AuthorizeSessionToken requires the token of the study session a request
+// is on, sent as `Authorization: Bearer <token>`. Tokens are handed out when
+// a session is created or launched and only grant access to that session.
+// They are not accepted in the query string, which ends up in request logs.
+func AuthorizeSessionToken(launcher *launch.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		auth := c.GetHeader("Authorization")
+		token, ok := strings.CutPrefix(auth, "Bearer ")
+		if !ok || token == "" {
+			respondWithError(c, http.StatusUnauthorized, "Missing session token")
+			c.Abort()
+			return
+		}
+
+		sessionID, err := launcher.Verify(token)
+		if err != nil {
+			respondWithError(c, http.StatusUnauthorized, err.Error())
+			c.Abort()
+			return
+		}
+		if c.Param("id") != strconv.Itoa(sessionID) {
+			respondWithError(c, http.StatusForbidden, "Token is not valid for this study session")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
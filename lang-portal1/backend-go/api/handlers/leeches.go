@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"lang-portal/backend/models"
+)
+
+// GetLeeches lists the words failed often enough to be leeches
+func GetLeeches(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		page, perPage := getPaginationParams(c)
+
+		leeches, total, err := models.GetLeeches(db, page, perPage)
+		if err != nil {
+			respondWithError(c, http.StatusInternalServerError, "Failed to get leeches")
+			return
+		}
+
+		c.JSON(http.StatusOK, newPaginatedResponse(leeches, page, total, perPage))
+	}
+}
+
+// UnsuspendWord puts a suspended word back into study queues
+func UnsuspendWord(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idStr := c.Param("id")
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			respondWithError(c, http.StatusBadRequest, "Invalid word ID")
+			return
+		}
+
+		word, err := models.UnsuspendWord(db, id)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				respondWithError(c, http.StatusNotFound, "Word not found")
+				return
+			}
+			respondWithError(c, http.StatusInternalServerError, "Failed to unsuspend word")
+			return
+		}
+
+		c.JSON(http.StatusOK, word)
+	}
+}
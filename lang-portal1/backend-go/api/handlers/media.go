@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"lang-portal/backend/media"
+	"lang-portal/backend/models"
+)
+
+func GetWordMedia(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idStr := c.Param("id")
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			respondWithError(c, http.StatusBadRequest, "Invalid word ID")
+			return
+		}
+
+		items, err := models.GetWordMedia(db, id)
+		if err != nil {
+			respondWithError(c, http.StatusInternalServerError, "Failed to get word media")
+			return
+		}
+
+		c.JSON(http.StatusOK, items)
+	}
+}
+
+func UploadWordMedia(db *sql.DB, store *media.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idStr := c.Param("id")
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			respondWithError(c, http.StatusBadRequest, "Invalid word ID")
+			return
+		}
+
+		// Leave room for the multipart envelope around the file itself
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, store.MaxSize+1<<20)
+
+		fileHeader, err := c.FormFile("file")
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				respondWithError(c, http.StatusRequestEntityTooLarge, media.ErrTooLarge.Error())
+				return
+			}
+			respondWithError(c, http.StatusBadRequest, "Missing file")
+			return
+		}
+
+		file, err := fileHeader.Open()
+		if err != nil {
+			respondWithError(c, http.StatusBadRequest, "Invalid file")
+			return
+		}
+		defer file.Close()
+
+		var item *models.WordMedia
+		var attachErr error
+		hint := media.Hint{Filename: fileHeader.Filename, MimeType: fileHeader.Header.Get("Content-Type")}
+		_, err = store.Put(file, hint, func(blob *media.Blob) error {
+			item = &models.WordMedia{
+				WordID:   id,
+				Kind:     media.Kind(blob.MimeType),
+				Hash:     blob.Hash,
+				MimeType: blob.MimeType,
+				Size:     blob.Size,
+				Filename: fileHeader.Filename,
+			}
+			attachErr = models.CreateWordMedia(db, item)
+			return attachErr
+		})
+		if err != nil {
+			switch {
+			case err == media.ErrTooLarge:
+				respondWithError(c, http.StatusRequestEntityTooLarge, err.Error())
+			case err == media.ErrUnsupportedType:
+				respondWithError(c, http.StatusUnsupportedMediaType, err.Error())
+			case attachErr == sql.ErrNoRows:
+				respondWithError(c, http.StatusNotFound, "Word not found")
+			case attachErr != nil:
+				respondWithError(c, http.StatusInternalServerError, "Failed to attach media")
+			default:
+				respondWithError(c, http.StatusInternalServerError, "Failed to store media")
+			}
+			return
+		}
+
+		c.JSON(http.StatusCreated, item)
+	}
+}
+
+func DeleteWordMedia(db *sql.DB, store *media.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idStr := c.Param("id")
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			respondWithError(c, http.StatusBadRequest, "Invalid word ID")
+			return
+		}
+
+		mediaIDStr := c.Param("mediaId")
+		mediaID, err := strconv.Atoi(mediaIDStr)
+		if err != nil {
+			respondWithError(c, http.StatusBadRequest, "Invalid media ID")
+			return
+		}
+
+		hash, err := models.DeleteWordMedia(db, id, mediaID)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				respondWithError(c, http.StatusNotFound, "Media not found")
+				return
+			}
+			respondWithError(c, http.StatusInternalServerError, "Failed to delete media")
+			return
+		}
+
+		removeOrphanedMedia(db, store, []string{hash})
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// ServeMedia streams a blob. http.ServeContent takes care of Range and
+// conditional requests; blobs never change, so they can be cached forever.
+func ServeMedia(db *sql.DB, store *media.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		hash := c.Param("hash")
+
+		item, err := models.GetMediaByHash(db, hash)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				respondWithError(c, http.StatusNotFound, "Media not found")
+				return
+			}
+			respondWithError(c, http.StatusInternalServerError, "Failed to get media")
+			return
+		}
+
+		serveMedia(c, store, item, "public, max-age=31536000, immutable")
+	}
+}
+
+func serveMedia(c *gin.Context, store *media.Store, item *models.WordMedia, cacheControl string) {
+	file, err := store.Open(item.Hash)
+	if err != nil {
+		if os.IsNotExist(err) {
+			respondWithError(c, http.StatusNotFound, "Media not found")
+			return
+		}
+		respondWithError(c, http.StatusInternalServerError, "Failed to open media")
+		return
+	}
+	defer file.Close()
+
+	c.Header("Content-Type", item.MimeType)
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("ETag", `"`+item.Hash+`"`)
+	c.Header("Cache-Control", cacheControl)
+	http.ServeContent(c.Writer, c.Request, "", item.CreatedAt, file)
+}
+
+// removeOrphanedMedia deletes the blobs among hashes that no word references
+// any more. Failures are logged rather than returned since the database is
+// already consistent at this point.
+func removeOrphanedMedia(db *sql.DB, store *media.Store, hashes []string) {
+	err := store.RemoveOrphans(hashes, func(hashes []string) ([]string, error) {
+		return models.GetOrphanedMediaHashes(db, hashes)
+	})
+	if err != nil {
+		log.Printf("Error removing orphaned media: %v", err)
+	}
+}
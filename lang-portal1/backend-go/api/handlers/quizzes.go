@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"lang-portal/backend/launch"
+	"lang-portal/backend/models"
+)
+
+type CreateQuizRequest struct {
+	GroupID   int    `json:"group_id" binding:"required"`
+	Direction string `json:"direction"`
+	Count     int    `json:"count"`
+	Options   int    `json:"options"`
+	// StudySessionID records the answers in an active session; otherwise a
+	// session of StudyActivityID is started
+	StudySessionID  int `json:"study_session_id"`
+	StudyActivityID int `json:"study_activity_id"`
+}
+
+type AnswerQuizRequest struct {
+	Option         *int   `json:"option" binding:"required"`
+	ResponseTimeMs *int   `json:"response_time_ms"`
+	HintUsed       bool   `json:"hint_used"`
+	ClientApp      string `json:"client_app"`
+}
+
+type AnswerQuizResponse struct {
+	Correct  bool                   `json:"correct"`
+	Question *models.QuizQuestion   `json:"question"`
+	Review   *models.WordReviewItem `json:"review"`
+}
+
+// QuizResponse is a new quiz, with the token of the session started for it
+type QuizResponse struct {
+	*models.Quiz
+	Token     string     `json:"token,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// CreateQuiz generates a multiple-choice quiz on a group's words, linked to
+// the study session its answers are recorded in
+func CreateQuiz(db *sql.DB, launcher *launch.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req CreateQuizRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondWithError(c, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+		if req.StudySessionID == 0 && req.StudyActivityID == 0 {
+			respondWithError(c, http.StatusBadRequest, "study_session_id or study_activity_id is required")
+			return
+		}
+
+		quiz, err := models.NewQuiz(db, models.QuizSpec{
+			GroupID:   req.GroupID,
+			Direction: req.Direction,
+			Questions: req.Count,
+			Options:   req.Options,
+		})
+		if err != nil {
+			if err == sql.ErrNoRows {
+				respondWithError(c, http.StatusNotFound, "Group not found")
+				return
+			}
+			if !respondWithValidationError(c, err) {
+				respondWithError(c, http.StatusInternalServerError, "Failed to generate quiz")
+			}
+			return
+		}
+
+		response := QuizResponse{Quiz: quiz}
+		sessionID := req.StudySessionID
+		if sessionID != 0 {
+			session, err := models.GetStudySession(db, sessionID)
+			if err != nil {
+				respondWithSessionError(c, err, "Failed to get study session")
+				return
+			}
+			if session.Status != models.SessionActive {
+				respondWithSessionError(c, models.ErrSessionClosed, "")
+				return
+			}
+		} else {
+			session, _, ok := startStudySession(c, db, models.SessionTarget{GroupIDs: []int{req.GroupID}}, req.StudyActivityID, models.QueueSpec{})
+			if !ok {
+				return
+			}
+			sessionID = session.ID
+			token, expires := launcher.Token(session.ID)
+			response.Token, response.ExpiresAt = token, &expires
+		}
+
+		if err := models.SaveQuiz(db, quiz, sessionID); err != nil {
+			if !respondWithValidationError(c, err) {
+				respondWithError(c, http.StatusInternalServerError, "Failed to save quiz")
+			}
+			return
+		}
+
+		c.JSON(http.StatusCreated, response)
+	}
+}
+
+func GetQuiz(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idStr := c.Param("id")
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			respondWithError(c, http.StatusBadRequest, "Invalid quiz ID")
+			return
+		}
+
+		quiz, err := models.GetQuiz(db, id)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				respondWithError(c, http.StatusNotFound, "Quiz not found")
+				return
+			}
+			respondWithError(c, http.StatusInternalServerError, "Failed to get quiz")
+			return
+		}
+
+		c.JSON(http.StatusOK, quiz)
+	}
+}
+
+// AnswerQuizQuestion grades the option selected for a question of a quiz in
+// the session and records it as a review
+func AnswerQuizQuestion(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sessionID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			respondWithError(c, http.StatusBadRequest, "Invalid session ID")
+			return
+		}
+		quizID, err := strconv.Atoi(c.Param("quiz_id"))
+		if err != nil {
+			respondWithError(c, http.StatusBadRequest, "Invalid quiz ID")
+			return
+		}
+		position, err := strconv.Atoi(c.Param("position"))
+		if err != nil {
+			respondWithError(c, http.StatusBadRequest, "Invalid question position")
+			return
+		}
+
+		var req AnswerQuizRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondWithError(c, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+
+		question, review, err := models.AnswerQuizQuestion(db, sessionID, quizID, position, *req.Option, models.ReviewDetails{
+			ResponseTimeMs: req.ResponseTimeMs,
+			HintUsed:       req.HintUsed,
+			ClientApp:      req.ClientApp,
+		})
+		if err != nil {
+			switch err {
+			case sql.ErrNoRows:
+				respondWithError(c, http.StatusNotFound, "Quiz question not found")
+			case models.ErrQuestionAnswered:
+				respondWithError(c, http.StatusConflict, err.Error())
+			default:
+				respondWithSessionError(c, err, "Failed to record answer")
+			}
+			return
+		}
+
+		c.JSON(http.StatusOK, AnswerQuizResponse{Correct: review.Correct, Question: question, Review: review})
+	}
+}
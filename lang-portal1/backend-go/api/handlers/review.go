@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"database/sql"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"lang-portal/backend/models"
+)
+
+const (
+	defaultDueLimit     = 20
+	maxDueLimit         = 100
+	defaultForecastDays = 14
+	maxForecastDays     = 365
+)
+
+// GetDueReviews lists the words due for review, optionally within a group,
+// with ?new= never reviewed words to fill up the queue
+func GetDueReviews(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		groupID, ok := getIntParam(c, "group_id", 0, 1, math.MaxInt32)
+		if !ok {
+			return
+		}
+		limit, ok := getIntParam(c, "limit", defaultDueLimit, 1, maxDueLimit)
+		if !ok {
+			return
+		}
+		newWords, ok := getIntParam(c, "new", 0, 0, maxDueLimit)
+		if !ok {
+			return
+		}
+
+		due, err := models.GetDueReviews(db, models.DueQuery{GroupID: groupID, Limit: limit, New: newWords}, time.Now())
+		if err == sql.ErrNoRows {
+			respondWithError(c, http.StatusNotFound, "Group not found")
+			return
+		}
+		if err != nil {
+			respondWithError(c, http.StatusInternalServerError, "Failed to get due reviews")
+			return
+		}
+
+		c.JSON(http.StatusOK, due)
+	}
+}
+
+// GetReviewForecast counts the reviews due on each of the next ?days= days
+func GetReviewForecast(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		groupID, ok := getIntParam(c, "group_id", 0, 1, math.MaxInt32)
+		if !ok {
+			return
+		}
+		days, ok := getIntParam(c, "days", defaultForecastDays, 1, maxForecastDays)
+		if !ok {
+			return
+		}
+
+		forecast, err := models.GetReviewForecast(db, groupID, days, time.Now())
+		if err == sql.ErrNoRows {
+			respondWithError(c, http.StatusNotFound, "Group not found")
+			return
+		}
+		if err != nil {
+			respondWithError(c, http.StatusInternalServerError, "Failed to get review forecast")
+			return
+		}
+
+		c.JSON(http.StatusOK, forecast)
+	}
+}
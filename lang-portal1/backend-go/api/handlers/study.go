@@ -2,10 +2,13 @@ package handlers
 
 import (
 	"database/sql"
+	"encoding/json"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"lang-portal/backend/launch"
 	"lang-portal/backend/models"
 )
 
@@ -43,7 +46,13 @@ func GetStudyActivitySessions(db *sql.DB) gin.HandlerFunc {
 
 		page, perPage := getPaginationParams(c)
 
-		sessions, total, err := models.GetStudySessions(db, id, page, perPage)
+		lang, ok := getLanguageParam(c)
+		if !ok {
+			return
+		}
+
+		filter := models.StudySessionFilter{ActivityID: id, Language: lang}
+		sessions, total, err := models.GetStudySessions(db, filter, page, perPage)
 		if err != nil {
 			respondWithError(c, http.StatusInternalServerError, "Failed to get study sessions")
 			return
@@ -54,11 +63,65 @@ func GetStudyActivitySessions(db *sql.DB) gin.HandlerFunc {
 }
 
 type CreateStudySessionRequest struct {
-	GroupID         int `json:"group_id" binding:"required"`
 	StudyActivityID int `json:"study_activity_id" binding:"required"`
+	SessionTargetRequest
+	QueueRequest
+}
+
+// SessionTargetRequest picks the words of a new session: a group, several
+// groups, a list of words or a search query, as a smart group filter
+type SessionTargetRequest struct {
+	GroupID  int             `json:"group_id"`
+	GroupIDs []int           `json:"group_ids"`
+	WordIDs  []int           `json:"word_ids"`
+	Query    json.RawMessage `json:"query"`
+}
+
+func (r SessionTargetRequest) toSessionTarget() (models.SessionTarget, error) {
+	target := models.SessionTarget{GroupIDs: r.GroupIDs, WordIDs: r.WordIDs}
+	if r.GroupID != 0 {
+		target.GroupIDs = append([]int{r.GroupID}, r.GroupIDs...)
+	}
+	if len(r.Query) > 0 && string(r.Query) != "null" {
+		query, err := models.ParseSmartFilter(r.Query)
+		if err != nil {
+			return target, err
+		}
+		target.Query = query
+	}
+	return target, nil
 }
 
-func CreateStudySession(db *sql.DB) gin.HandlerFunc {
+// QueueRequest chooses how the word queue of a new session is built
+type QueueRequest struct {
+	Strategy string           `json:"strategy"`
+	Size     int              `json:"size"`
+	Seed     *int64           `json:"seed"`
+	Mix      *models.QueueMix `json:"mix"`
+}
+
+func (r QueueRequest) toQueueSpec() models.QueueSpec {
+	spec := models.QueueSpec{Strategy: r.Strategy, Size: r.Size, Seed: r.Seed}
+	if r.Mix != nil {
+		spec.Mix = *r.Mix
+	}
+	return spec
+}
+
+// StudySessionResponse is a new session with the token that authorizes
+// writes to it
+type StudySessionResponse struct {
+	*models.StudySessionDetail
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func newStudySessionResponse(session *models.StudySessionDetail, launcher *launch.Service) StudySessionResponse {
+	token, expires := launcher.Token(session.ID)
+	return StudySessionResponse{StudySessionDetail: session, Token: token, ExpiresAt: expires}
+}
+
+func CreateStudySession(db *sql.DB, launcher *launch.Service) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req CreateStudySessionRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
@@ -66,25 +129,97 @@ func CreateStudySession(db *sql.DB) gin.HandlerFunc {
 			return
 		}
 
-		session, err := models.CreateStudySession(db, req.GroupID, req.StudyActivityID)
+		target, err := req.toSessionTarget()
 		if err != nil {
-			respondWithError(c, http.StatusInternalServerError, "Failed to create study session")
+			respondWithValidationError(c, err)
 			return
 		}
 
-		c.JSON(http.StatusCreated, session)
+		session, _, ok := startStudySession(c, db, target, req.StudyActivityID, req.toQueueSpec())
+		if !ok {
+			return
+		}
+
+		c.JSON(http.StatusCreated, newStudySessionResponse(session, launcher))
+	}
+}
+
+// startStudySession creates a session of an enabled activity over a
+// target. It responds with an error and returns false if that is not
+// possible.
+func startStudySession(c *gin.Context, db *sql.DB, target models.SessionTarget, activityID int, queue models.QueueSpec) (*models.StudySessionDetail, *models.StudyActivity, bool) {
+	for _, groupID := range target.GroupIDs {
+		if _, err := models.LookupGroup(db, groupID); err != nil {
+			if err == sql.ErrNoRows {
+				respondWithError(c, http.StatusNotFound, "Group not found")
+				return nil, nil, false
+			}
+			respondWithError(c, http.StatusInternalServerError, "Failed to get group")
+			return nil, nil, false
+		}
+	}
+
+	activity, err := models.GetStudyActivity(db, activityID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			respondWithError(c, http.StatusNotFound, "Study activity not found")
+			return nil, nil, false
+		}
+		respondWithError(c, http.StatusInternalServerError, "Failed to get study activity")
+		return nil, nil, false
+	}
+	if !activity.Enabled {
+		respondWithError(c, http.StatusConflict, models.ErrActivityDisabled.Error())
+		return nil, nil, false
+	}
+
+	session, err := models.CreateStudySession(db, target, activityID, queue)
+	if err != nil {
+		if !respondWithValidationError(c, err) {
+			respondWithError(c, http.StatusInternalServerError, "Failed to create study session")
+		}
+		return nil, nil, false
+	}
+	return session, activity, true
+}
+
+// ReviewDetailsRequest holds the optional details of a review
+type ReviewDetailsRequest struct {
+	Answer         string `json:"answer"`
+	ExpectedAnswer string `json:"expected_answer"`
+	ResponseTimeMs *int   `json:"response_time_ms"`
+	HintUsed       bool   `json:"hint_used"`
+	Quality        *int   `json:"quality"`
+	ClientApp      string `json:"client_app"`
+}
+
+func (req ReviewDetailsRequest) toReviewDetails() models.ReviewDetails {
+	return models.ReviewDetails{
+		Answer:         req.Answer,
+		ExpectedAnswer: req.ExpectedAnswer,
+		ResponseTimeMs: req.ResponseTimeMs,
+		HintUsed:       req.HintUsed,
+		Quality:        req.Quality,
+		ClientApp:      req.ClientApp,
 	}
 }
 
+// AddWordReviewRequest needs correct, or a quality from which it is derived
 type AddWordReviewRequest struct {
-	Correct bool `json:"correct" binding:"required"`
+	Correct *bool `json:"correct"`
+	ReviewDetailsRequest
 }
 
 func GetStudySessions(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		page, perPage := getPaginationParams(c)
 
-		sessions, total, err := models.GetStudySessions(db, 0, page, perPage) // 0 means all activities
+		lang, ok := getLanguageParam(c)
+		if !ok {
+			return
+		}
+
+		sessions, total, err := models.GetStudySessions(db, models.StudySessionFilter{Language: lang}, page, perPage)
 		if err != nil {
 			respondWithError(c, http.StatusInternalServerError, "Failed to get study sessions")
 			return
@@ -117,9 +252,79 @@ func GetStudySession(db *sql.DB) gin.HandlerFunc {
 	}
 }
 
+// GetStudySessionQueue lists the words of a session in the order they are
+// meant to be studied, marking the ones already reviewed
+func GetStudySessionQueue(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			respondWithError(c, http.StatusBadRequest, "Invalid session ID")
+			return
+		}
+
+		queue, err := models.GetSessionQueue(db, id)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				respondWithError(c, http.StatusNotFound, "Study session not found")
+				return
+			}
+			respondWithError(c, http.StatusInternalServerError, "Failed to get session queue")
+			return
+		}
+
+		c.JSON(http.StatusOK, queue)
+	}
+}
+
+type SessionWordsResponse struct {
+	PaginatedResponse
+	Summary *models.SessionSummary `json:"summary"`
+}
+
+// GetStudySessionWords lists the reviews of a session along with a summary
+// of its results
+func GetStudySessionWords(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idStr := c.Param("id")
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			respondWithError(c, http.StatusBadRequest, "Invalid session ID")
+			return
+		}
+
+		page, perPage := getPaginationParams(c)
+
+		attempts, ok := getAttemptsParam(c)
+		if !ok {
+			return
+		}
+
+		words, total, err := models.GetSessionWords(db, id, page, perPage)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				respondWithError(c, http.StatusNotFound, "Study session not found")
+				return
+			}
+			respondWithError(c, http.StatusInternalServerError, "Failed to get study session words")
+			return
+		}
+
+		summary, err := models.GetSessionSummary(db, id, attempts)
+		if err != nil {
+			respondWithError(c, http.StatusInternalServerError, "Failed to get study session summary")
+			return
+		}
+
+		c.JSON(http.StatusOK, SessionWordsResponse{
+			PaginatedResponse: newPaginatedResponse(words, page, total, perPage),
+			Summary:           summary,
+		})
+	}
+}
+
 func AddWordReview(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		sessionIDStr := c.Param("session_id")
+		sessionIDStr := c.Param("id")
 		sessionID, err := strconv.Atoi(sessionIDStr)
 		if err != nil {
 			respondWithError(c, http.StatusBadRequest, "Invalid session ID")
@@ -139,12 +344,141 @@ func AddWordReview(db *sql.DB) gin.HandlerFunc {
 			return
 		}
 
-		err = models.AddWordReview(db, sessionID, wordID, req.Correct)
+		review, err := models.AddWordReview(db, sessionID, models.ReviewInput{
+			WordID:        wordID,
+			Correct:       req.Correct,
+			ReviewDetails: req.ReviewDetailsRequest.toReviewDetails(),
+		})
 		if err != nil {
-			respondWithError(c, http.StatusInternalServerError, "Failed to add word review")
+			respondWithSessionError(c, err, "Failed to add word review")
 			return
 		}
 
-		c.Status(http.StatusCreated)
+		c.JSON(http.StatusCreated, review)
 	}
 }
+
+type ReviewBatchItem struct {
+	WordID     int        `json:"word_id"`
+	Correct    *bool      `json:"correct"`
+	ReviewedAt *time.Time `json:"reviewed_at"`
+	ClientID   string     `json:"client_id"`
+	ReviewDetailsRequest
+}
+
+type ReviewBatchErrorResponse struct {
+	Error string `json:"error"`
+	*models.ReviewBatchResult
+}
+
+// AddWordReviews records an array of reviews at once, for clients that
+// studied offline. Nothing is recorded unless every review is valid.
+func AddWordReviews(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idStr := c.Param("id")
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			respondWithError(c, http.StatusBadRequest, "Invalid session ID")
+			return
+		}
+
+		var items []ReviewBatchItem
+		if err := c.ShouldBindJSON(&items); err != nil {
+			respondWithError(c, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+
+		reviews := make([]models.ReviewInput, len(items))
+		for i, item := range items {
+			reviews[i] = models.ReviewInput{
+				WordID:        item.WordID,
+				Correct:       item.Correct,
+				ReviewedAt:    item.ReviewedAt,
+				ClientID:      item.ClientID,
+				ReviewDetails: item.ReviewDetailsRequest.toReviewDetails(),
+			}
+		}
+
+		result, err := models.AddWordReviews(db, id, reviews)
+		if err == models.ErrInvalidBatch {
+			c.JSON(http.StatusBadRequest, ReviewBatchErrorResponse{Error: err.Error(), ReviewBatchResult: result})
+			return
+		}
+		if err != nil {
+			respondWithSessionError(c, err, "Failed to add word reviews")
+			return
+		}
+
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// DeleteLastReview undoes the last review recorded in a session, such as a
+// mis-tap
+func DeleteLastReview(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idStr := c.Param("id")
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			respondWithError(c, http.StatusBadRequest, "Invalid session ID")
+			return
+		}
+
+		review, err := models.DeleteLastReview(db, id)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				respondWithError(c, http.StatusNotFound, "Study session or review not found")
+				return
+			}
+			respondWithSessionError(c, err, "Failed to delete review")
+			return
+		}
+
+		c.JSON(http.StatusOK, review)
+	}
+}
+
+// respondWithSessionError maps errors from changing a study session, using
+// message for unexpected ones
+func respondWithSessionError(c *gin.Context, err error, message string) {
+	switch err {
+	case sql.ErrNoRows:
+		respondWithError(c, http.StatusNotFound, "Study session not found")
+	case models.ErrSessionClosed:
+		respondWithError(c, http.StatusConflict, "Study session is no longer active")
+	default:
+		if !respondWithValidationError(c, err) {
+			respondWithError(c, http.StatusInternalServerError, message)
+		}
+	}
+}
+
+// closeStudySession builds the handlers that end an active session
+func closeStudySession(db *sql.DB, status string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idStr := c.Param("id")
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			respondWithError(c, http.StatusBadRequest, "Invalid session ID")
+			return
+		}
+
+		session, err := models.CloseStudySession(db, id, status)
+		if err != nil {
+			respondWithSessionError(c, err, "Failed to close study session")
+			return
+		}
+
+		c.JSON(http.StatusOK, session)
+	}
+}
+
+// CompleteStudySession marks a session as finished
+func CompleteStudySession(db *sql.DB) gin.HandlerFunc {
+	return closeStudySession(db, models.SessionCompleted)
+}
+
+// AbandonStudySession marks a session as given up
+func AbandonStudySession(db *sql.DB) gin.HandlerFunc {
+	return closeStudySession(db, models.SessionAbandoned)
+}
@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"lang-portal/backend/launch"
+	"lang-portal/backend/models"
+)
+
+type StudyActivityRequest struct {
+	Name         string `json:"name" binding:"required"`
+	Description  string `json:"description"`
+	ThumbnailURL string `json:"thumbnail_url"`
+	LaunchURL    string `json:"launch_url"`
+	// Enabled defaults to true
+	Enabled *bool `json:"enabled"`
+}
+
+func (req StudyActivityRequest) toStudyActivity() *models.StudyActivity {
+	activity := &models.StudyActivity{
+		Name:         req.Name,
+		Description:  req.Description,
+		ThumbnailURL: req.ThumbnailURL,
+		LaunchURL:    req.LaunchURL,
+		Enabled:      true,
+	}
+	if req.Enabled != nil {
+		activity.Enabled = *req.Enabled
+	}
+	return activity
+}
+
+// respondWithActivityError maps errors from changing the activity catalog,
+// using message for unexpected ones
+func respondWithActivityError(c *gin.Context, err error, message string) {
+	switch err {
+	case sql.ErrNoRows:
+		respondWithError(c, http.StatusNotFound, "Study activity not found")
+	case models.ErrActivityInUse:
+		respondWithError(c, http.StatusConflict, "Study activity has study sessions, disable it instead")
+	default:
+		if !respondWithValidationError(c, err) {
+			respondWithError(c, http.StatusInternalServerError, message)
+		}
+	}
+}
+
+// GetStudyActivities lists the activity catalog, optionally only the
+// enabled or disabled activities with ?enabled=
+func GetStudyActivities(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		page, perPage := getPaginationParams(c)
+
+		var filter models.StudyActivityFilter
+		if enabledStr := c.Query("enabled"); enabledStr != "" {
+			enabled, err := strconv.ParseBool(enabledStr)
+			if err != nil {
+				respondWithError(c, http.StatusBadRequest, "Invalid enabled filter")
+				return
+			}
+			filter.Enabled = &enabled
+		}
+
+		activities, total, err := models.GetStudyActivities(db, filter, page, perPage)
+		if err != nil {
+			respondWithError(c, http.StatusInternalServerError, "Failed to get study activities")
+			return
+		}
+
+		c.JSON(http.StatusOK, newPaginatedResponse(activities, page, total, perPage))
+	}
+}
+
+// PostStudyActivities registers an activity. Bodies with a
+// study_activity_id are launches from before POST /study_sessions existed;
+// they still create a study session, and the response points at the
+// replacement route.
+func PostStudyActivities(db *sql.DB, launcher *launch.Service) gin.HandlerFunc {
+	createActivity := CreateStudyActivity(db)
+	createSession := CreateStudySession(db, launcher)
+	return func(c *gin.Context) {
+		body, err := c.GetRawData()
+		if err != nil {
+			respondWithError(c, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		var fields map[string]json.RawMessage
+		if json.Unmarshal(body, &fields) == nil {
+			if _, ok := fields["study_activity_id"]; ok {
+				c.Header("Deprecation", "true")
+				c.Header("Link", `</api/study_sessions>; rel="successor-version"`)
+				createSession(c)
+				return
+			}
+		}
+		createActivity(c)
+	}
+}
+
+func CreateStudyActivity(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req StudyActivityRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondWithError(c, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+
+		activity := req.toStudyActivity()
+		if err := activity.Normalize(); err != nil {
+			respondWithActivityError(c, err, "Failed to validate study activity")
+			return
+		}
+
+		if err := models.CreateStudyActivity(db, activity); err != nil {
+			respondWithActivityError(c, err, "Failed to create study activity")
+			return
+		}
+
+		c.JSON(http.StatusCreated, activity)
+	}
+}
+
+func UpdateStudyActivity(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idStr := c.Param("id")
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			respondWithError(c, http.StatusBadRequest, "Invalid activity ID")
+			return
+		}
+
+		var req StudyActivityRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondWithError(c, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+
+		activity := req.toStudyActivity()
+		activity.ID = id
+		if err := activity.Normalize(); err != nil {
+			respondWithActivityError(c, err, "Failed to validate study activity")
+			return
+		}
+
+		if err := models.UpdateStudyActivity(db, activity); err != nil {
+			respondWithActivityError(c, err, "Failed to update study activity")
+			return
+		}
+
+		c.JSON(http.StatusOK, activity)
+	}
+}
+
+// PatchStudyActivity applies a JSON Merge Patch, changing only the supplied
+// fields
+func PatchStudyActivity(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idStr := c.Param("id")
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			respondWithError(c, http.StatusBadRequest, "Invalid activity ID")
+			return
+		}
+
+		patch, ok := bindPatch(c)
+		if !ok {
+			return
+		}
+
+		activity, err := models.GetStudyActivity(db, id)
+		if err != nil {
+			respondWithActivityError(c, err, "Failed to get study activity")
+			return
+		}
+
+		if err := activity.ApplyPatch(patch); err != nil {
+			respondWithActivityError(c, err, "Failed to validate study activity")
+			return
+		}
+
+		if err := models.UpdateStudyActivity(db, activity); err != nil {
+			respondWithActivityError(c, err, "Failed to update study activity")
+			return
+		}
+
+		c.JSON(http.StatusOK, activity)
+	}
+}
+
+func DeleteStudyActivity(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idStr := c.Param("id")
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			respondWithError(c, http.StatusBadRequest, "Invalid activity ID")
+			return
+		}
+
+		if err := models.DeleteStudyActivity(db, id); err != nil {
+			respondWithActivityError(c, err, "Failed to delete study activity")
+			return
+		}
+
+		c.Status(http.StatusOK)
+	}
+}
@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"lang-portal/backend/models"
+)
+
+func GetWordRelations(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idStr := c.Param("id")
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			respondWithError(c, http.StatusBadRequest, "Invalid word ID")
+			return
+		}
+
+		if _, err := models.GetWord(db, id); err != nil {
+			if err == sql.ErrNoRows {
+				respondWithError(c, http.StatusNotFound, "Word not found")
+				return
+			}
+			respondWithError(c, http.StatusInternalServerError, "Failed to get word")
+			return
+		}
+
+		relations, err := models.GetWordRelations(db, id)
+		if err != nil {
+			respondWithError(c, http.StatusInternalServerError, "Failed to get word relations")
+			return
+		}
+
+		c.JSON(http.StatusOK, relations)
+	}
+}
+
+type CreateWordRelationRequest struct {
+	RelatedWordID int    `json:"related_word_id" binding:"required"`
+	RelationType  string `json:"relation_type" binding:"required"`
+}
+
+func CreateWordRelation(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idStr := c.Param("id")
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			respondWithError(c, http.StatusBadRequest, "Invalid word ID")
+			return
+		}
+
+		var req CreateWordRelationRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondWithError(c, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+
+		relation := &models.WordRelation{
+			WordID:        id,
+			RelatedWordID: req.RelatedWordID,
+			RelationType:  req.RelationType,
+		}
+
+		if err := models.CreateWordRelation(db, relation); err != nil {
+			respondWithRelationError(c, err, "Failed to create word relation")
+			return
+		}
+
+		c.JSON(http.StatusCreated, relation)
+	}
+}
+
+type UpdateWordRelationRequest struct {
+	RelationType string `json:"relation_type" binding:"required"`
+}
+
+func UpdateWordRelation(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idStr := c.Param("id")
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			respondWithError(c, http.StatusBadRequest, "Invalid word ID")
+			return
+		}
+
+		relationIDStr := c.Param("relationId")
+		relationID, err := strconv.Atoi(relationIDStr)
+		if err != nil {
+			respondWithError(c, http.StatusBadRequest, "Invalid relation ID")
+			return
+		}
+
+		var req UpdateWordRelationRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondWithError(c, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+
+		relation := &models.WordRelation{
+			ID:           relationID,
+			RelationType: req.RelationType,
+		}
+
+		if err := models.UpdateWordRelation(db, id, relation); err != nil {
+			respondWithRelationError(c, err, "Failed to update word relation")
+			return
+		}
+
+		c.JSON(http.StatusOK, relation)
+	}
+}
+
+func DeleteWordRelation(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idStr := c.Param("id")
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			respondWithError(c, http.StatusBadRequest, "Invalid word ID")
+			return
+		}
+
+		relationIDStr := c.Param("relationId")
+		relationID, err := strconv.Atoi(relationIDStr)
+		if err != nil {
+			respondWithError(c, http.StatusBadRequest, "Invalid relation ID")
+			return
+		}
+
+		if err := models.DeleteWordRelation(db, id, relationID); err != nil {
+			if err == sql.ErrNoRows {
+				respondWithError(c, http.StatusNotFound, "Word relation not found")
+				return
+			}
+			respondWithError(c, http.StatusInternalServerError, "Failed to delete word relation")
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func respondWithRelationError(c *gin.Context, err error, message string) {
+	switch err {
+	case sql.ErrNoRows:
+		respondWithError(c, http.StatusNotFound, "Word or relation not found")
+	case models.ErrInvalidRelationType, models.ErrSelfRelation:
+		respondWithError(c, http.StatusBadRequest, err.Error())
+	case models.ErrDuplicateRelation:
+		respondWithError(c, http.StatusConflict, err.Error())
+	default:
+		respondWithError(c, http.StatusInternalServerError, message)
+	}
+}
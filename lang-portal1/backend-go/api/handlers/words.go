@@ -7,6 +7,8 @@ import (
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"lang-portal/backend/language"
+	"lang-portal/backend/media"
 	"lang-portal/backend/models"
 )
 
@@ -14,12 +16,34 @@ func GetWords(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		page, perPage := getPaginationParams(c)
 
-		words, total, err := models.GetWords(db, page, perPage)
+		lang, ok := getLanguageParam(c)
+		if !ok {
+			return
+		}
+
+		furigana, ok := getFuriganaParam(c)
+		if !ok {
+			return
+		}
+
+		suspended, ok := getSuspendedParam(c)
+		if !ok {
+			return
+		}
+
+		words, total, err := models.GetWords(db, models.WordFilter{Language: lang, Suspended: suspended}, page, perPage)
 		if err != nil {
 			respondWithError(c, http.StatusInternalServerError, "Failed to get words")
 			return
 		}
 
+		if furigana != "" {
+			if err := models.AddFurigana(words, furigana); err != nil {
+				respondWithError(c, http.StatusInternalServerError, "Failed to render furigana")
+				return
+			}
+		}
+
 		c.JSON(http.StatusOK, newPaginatedResponse(words, page, total, perPage))
 	}
 }
@@ -47,11 +71,85 @@ func GetWord(db *sql.DB) gin.HandlerFunc {
 	}
 }
 
+type FuriganaResponse struct {
+	WordID   int         `json:"word_id"`
+	Format   string      `json:"format"`
+	Furigana interface{} `json:"furigana"`
+}
+
+func GetWordFurigana(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idStr := c.Param("id")
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			respondWithError(c, http.StatusBadRequest, "Invalid word ID")
+			return
+		}
+
+		format := c.DefaultQuery("format", language.FuriganaHTML)
+
+		word, err := models.LookupWord(db, id)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				respondWithError(c, http.StatusNotFound, "Word not found")
+				return
+			}
+			respondWithError(c, http.StatusInternalServerError, "Failed to get word")
+			return
+		}
+
+		tokens := word.FuriganaTokens()
+		if tokens == nil {
+			respondWithError(c, http.StatusUnprocessableEntity, "Furigana is only available for Japanese words")
+			return
+		}
+
+		rendered, err := language.RenderFurigana(tokens, format)
+		if err != nil {
+			respondWithError(c, http.StatusBadRequest, "Invalid furigana format")
+			return
+		}
+
+		c.JSON(http.StatusOK, FuriganaResponse{WordID: word.ID, Format: format, Furigana: rendered})
+	}
+}
+
 type CreateWordRequest struct {
-	Japanese string          `json:"japanese" binding:"required"`
-	Romaji   string          `json:"romaji" binding:"required"`
-	English  string          `json:"english" binding:"required"`
-	Parts    json.RawMessage `json:"parts"`
+	Language     string          `json:"language"`
+	Term         string          `json:"term"`
+	Romanization string          `json:"romanization"`
+	Glosses      []string        `json:"glosses"`
+	Parts        json.RawMessage `json:"parts"`
+
+	// Japanese-only fields accepted from older clients
+	Japanese string `json:"japanese"`
+	Romaji   string `json:"romaji"`
+	English  string `json:"english"`
+}
+
+// toWord maps the request onto a word, falling back to the Japanese-only
+// fields when the generic ones are missing
+func (req CreateWordRequest) toWord() *models.Word {
+	word := &models.Word{
+		Language:     req.Language,
+		Term:         req.Term,
+		Romanization: req.Romanization,
+		Glosses:      req.Glosses,
+		Parts:        req.Parts,
+	}
+	if word.Term == "" && req.Japanese != "" {
+		word.Term = req.Japanese
+		if word.Language == "" {
+			word.Language = "ja"
+		}
+	}
+	if word.Romanization == "" {
+		word.Romanization = req.Romaji
+	}
+	if len(word.Glosses) == 0 && req.English != "" {
+		word.Glosses = []string{req.English}
+	}
+	return word
 }
 
 func CreateWord(db *sql.DB) gin.HandlerFunc {
@@ -62,11 +160,12 @@ func CreateWord(db *sql.DB) gin.HandlerFunc {
 			return
 		}
 
-		word := &models.Word{
-			Japanese: req.Japanese,
-			Romaji:   req.Romaji,
-			English:  req.English,
-			Parts:    req.Parts,
+		word := req.toWord()
+		if err := word.Normalize(); err != nil {
+			if !respondWithValidationError(c, err) {
+				respondWithError(c, http.StatusInternalServerError, "Failed to validate word")
+			}
+			return
 		}
 
 		if err := models.CreateWord(db, word); err != nil {
@@ -93,15 +192,69 @@ func UpdateWord(db *sql.DB) gin.HandlerFunc {
 			return
 		}
 
-		word := &models.Word{
-			ID:       id,
-			Japanese: req.Japanese,
-			Romaji:   req.Romaji,
-			English:  req.English,
-			Parts:    req.Parts,
+		word := req.toWord()
+		word.ID = id
+		if err := word.Normalize(); err != nil {
+			if !respondWithValidationError(c, err) {
+				respondWithError(c, http.StatusInternalServerError, "Failed to validate word")
+			}
+			return
+		}
+
+		if err := models.UpdateWord(db, word); err != nil {
+			if err == sql.ErrNoRows {
+				respondWithError(c, http.StatusNotFound, "Word not found")
+				return
+			}
+			respondWithError(c, http.StatusInternalServerError, "Failed to update word")
+			return
+		}
+
+		c.JSON(http.StatusOK, word)
+	}
+}
+
+// PatchWord applies a JSON Merge Patch, changing only the supplied fields
+func PatchWord(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idStr := c.Param("id")
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			respondWithError(c, http.StatusBadRequest, "Invalid word ID")
+			return
+		}
+
+		patch, ok := bindPatch(c)
+		if !ok {
+			return
+		}
+
+		word, err := models.LookupWord(db, id)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				respondWithError(c, http.StatusNotFound, "Word not found")
+				return
+			}
+			respondWithError(c, http.StatusInternalServerError, "Failed to get word")
+			return
+		}
+
+		err = word.ApplyPatch(patch)
+		if err == nil {
+			err = word.Normalize()
+		}
+		if err != nil {
+			if !respondWithValidationError(c, err) {
+				respondWithError(c, http.StatusInternalServerError, "Failed to validate word")
+			}
+			return
 		}
 
 		if err := models.UpdateWord(db, word); err != nil {
+			if err == sql.ErrNoRows {
+				respondWithError(c, http.StatusNotFound, "Word not found")
+				return
+			}
 			respondWithError(c, http.StatusInternalServerError, "Failed to update word")
 			return
 		}
@@ -110,7 +263,7 @@ func UpdateWord(db *sql.DB) gin.HandlerFunc {
 	}
 }
 
-func DeleteWord(db *sql.DB) gin.HandlerFunc {
+func DeleteWord(db *sql.DB, store *media.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		idStr := c.Param("id")
 		id, err := strconv.Atoi(idStr)
@@ -119,11 +272,24 @@ func DeleteWord(db *sql.DB) gin.HandlerFunc {
 			return
 		}
 
+		attachments, err := models.GetWordMedia(db, id)
+		if err != nil {
+			respondWithError(c, http.StatusInternalServerError, "Failed to get word media")
+			return
+		}
+
 		if err := models.DeleteWord(db, id); err != nil {
 			respondWithError(c, http.StatusInternalServerError, "Failed to delete word")
 			return
 		}
 
+		// Clean up blobs that were only used by this word
+		var hashes []string
+		for _, m := range attachments {
+			hashes = append(hashes, m.Hash)
+		}
+		removeOrphanedMedia(db, store, hashes)
+
 		c.Status(http.StatusNoContent)
 	}
 }
@@ -144,8 +310,8 @@ func AddWordToGroupFromWord(db *sql.DB) gin.HandlerFunc {
 			return
 		}
 
-		if err := models.AddWordToGroup(db, wordID, groupID); err != nil {
-			respondWithError(c, http.StatusInternalServerError, "Failed to add word to group")
+		if err := models.AddWordToGroup(db, groupID, wordID); err != nil {
+			respondWithMembershipError(c, err, "Failed to add word to group")
 			return
 		}
 
@@ -169,8 +335,8 @@ func RemoveWordFromGroupHandler(db *sql.DB) gin.HandlerFunc {
 			return
 		}
 
-		if err := models.RemoveWordFromGroup(db, wordID, groupID); err != nil {
-			respondWithError(c, http.StatusInternalServerError, "Failed to remove word from group")
+		if err := models.RemoveWordFromGroup(db, groupID, wordID); err != nil {
+			respondWithMembershipError(c, err, "Failed to remove word from group")
 			return
 		}
 
@@ -5,9 +5,13 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"lang-portal/backend/api/handlers"
+	"lang-portal/backend/launch"
+	"lang-portal/backend/media"
+	"lang-portal/backend/models"
+	"lang-portal/backend/tts"
 )
 
-func SetupRoutes(r *gin.Engine, db *sql.DB) {
+func SetupRoutes(r *gin.Engine, db *sql.DB, store *media.Store, speech *tts.Service, launcher *launch.Service) {
 	// API group
 	api := r.Group("/api")
 
@@ -17,22 +21,49 @@ func SetupRoutes(r *gin.Engine, db *sql.DB) {
 	api.GET("/dashboard/quick-stats", handlers.GetQuickStats(db))
 
 	// Study activity routes
+	api.GET("/study_activities", handlers.GetStudyActivities(db))
+	api.POST("/study_activities", handlers.PostStudyActivities(db, launcher))
 	api.GET("/study_activities/:id", handlers.GetStudyActivity(db))
+	api.PUT("/study_activities/:id", handlers.UpdateStudyActivity(db))
+	api.PATCH("/study_activities/:id", handlers.PatchStudyActivity(db))
+	api.DELETE("/study_activities/:id", handlers.DeleteStudyActivity(db))
+	api.POST("/study_activities/:id/launch", handlers.LaunchStudyActivity(db, launcher))
 	api.GET("/study_activities/:id/study_sessions", handlers.GetStudyActivitySessions(db))
-	api.POST("/study_activities", handlers.CreateStudySession(db))
+
+	// Language routes
+	api.GET("/languages", handlers.GetLanguages())
 
 	// Word routes
 	api.GET("/words", handlers.GetWords(db))
 	api.POST("/words", handlers.CreateWord(db))
-	
+	api.GET("/words/leeches", handlers.GetLeeches(db))
+
 	// Single word routes
 	wordRoutes := api.Group("/words/:id")
 	{
 		wordRoutes.GET("", handlers.GetWord(db))
 		wordRoutes.PUT("", handlers.UpdateWord(db))
-		wordRoutes.DELETE("", handlers.DeleteWord(db))
+		wordRoutes.PATCH("", handlers.PatchWord(db))
+		wordRoutes.DELETE("", handlers.DeleteWord(db, store))
+		wordRoutes.POST("/unsuspend", handlers.UnsuspendWord(db))
+		wordRoutes.GET("/furigana", handlers.GetWordFurigana(db))
+		wordRoutes.GET("/relations", handlers.GetWordRelations(db))
+		wordRoutes.POST("/relations", handlers.CreateWordRelation(db))
+		wordRoutes.PUT("/relations/:relationId", handlers.UpdateWordRelation(db))
+		wordRoutes.DELETE("/relations/:relationId", handlers.DeleteWordRelation(db))
+		wordRoutes.GET("/media", handlers.GetWordMedia(db))
+		wordRoutes.POST("/media", handlers.UploadWordMedia(db, store))
+		wordRoutes.DELETE("/media/:mediaId", handlers.DeleteWordMedia(db, store))
+		wordRoutes.GET("/audio", handlers.GetWordAudio(db, store, speech))
+		wordRoutes.HEAD("/audio", handlers.GetWordAudio(db, store, speech))
+		wordRoutes.POST("/audio", handlers.GenerateWordAudio(db, store, speech))
 	}
 
+	// Media routes
+	api.GET("/media/:hash", handlers.ServeMedia(db, store))
+	api.HEAD("/media/:hash", handlers.ServeMedia(db, store))
+	api.GET("/audio_jobs/:id", handlers.GetAudioJob(speech))
+
 	// Word-group relationship routes
 	wordGroupRoutes := api.Group("/word-groups")
 	{
@@ -45,19 +76,57 @@ func SetupRoutes(r *gin.Engine, db *sql.DB) {
 	{
 		groupRoutes.GET("", handlers.GetGroups(db))
 		groupRoutes.POST("", handlers.CreateGroup(db))
+		groupRoutes.POST("/union", handlers.CombineGroups(db, models.SetUnion))
+		groupRoutes.POST("/intersection", handlers.CombineGroups(db, models.SetIntersection))
+		groupRoutes.POST("/difference", handlers.CombineGroups(db, models.SetDifference))
 		groupRoutes.GET("/:id", handlers.GetGroup(db))
 		groupRoutes.PUT("/:id", handlers.UpdateGroup(db))
+		groupRoutes.PATCH("/:id", handlers.PatchGroup(db))
 		groupRoutes.DELETE("/:id", handlers.DeleteGroup(db))
+		groupRoutes.GET("/:id/children", handlers.GetGroupChildren(db))
 		groupRoutes.GET("/:id/words", handlers.GetGroupWords(db))
+		groupRoutes.POST("/:id/words", handlers.AddWordsToGroup(db))
+		groupRoutes.PUT("/:id/words", handlers.ReplaceGroupWords(db))
+		groupRoutes.DELETE("/:id/words", handlers.RemoveWordsFromGroup(db))
+		groupRoutes.POST("/:id/clone", handlers.CloneGroup(db))
+		groupRoutes.POST("/:id/merge", handlers.MergeGroups(db))
 		groupRoutes.POST("/:id/words/:wordId", handlers.AddWordToGroup(db))
 		groupRoutes.DELETE("/:id/words/:wordId", handlers.RemoveWordFromGroup(db))
+		groupRoutes.GET("/:id/report", handlers.GetGroupReport(db))
 		groupRoutes.GET("/:id/study_sessions", handlers.GetGroupStudySessions(db))
+		groupRoutes.POST("/:id/audio", handlers.GenerateGroupAudio(db, store, speech))
 	}
 
 	// Study session routes
 	api.GET("/study_sessions", handlers.GetStudySessions(db))
+	api.POST("/study_sessions", handlers.CreateStudySession(db, launcher))
 	api.GET("/study_sessions/:id", handlers.GetStudySession(db))
-	api.POST("/study_sessions/:session_id/words/:word_id/review", handlers.AddWordReview(db))
+	api.GET("/study_sessions/:id/words", handlers.GetStudySessionWords(db))
+	api.GET("/study_sessions/:id/queue", handlers.GetStudySessionQueue(db))
+
+	// Writes to a session need the token issued when it was created or
+	// launched
+	sessionRoutes := api.Group("/study_sessions/:id", handlers.AuthorizeSessionToken(launcher))
+	{
+		sessionRoutes.POST("/words/:word_id/review", handlers.AddWordReview(db))
+		sessionRoutes.POST("/words/:word_id/grade", handlers.GradeWordReview(db))
+		sessionRoutes.POST("/reviews", handlers.AddWordReviews(db))
+		sessionRoutes.DELETE("/reviews/last", handlers.DeleteLastReview(db))
+		sessionRoutes.POST("/complete", handlers.CompleteStudySession(db))
+		sessionRoutes.POST("/abandon", handlers.AbandonStudySession(db))
+		sessionRoutes.POST("/quizzes/:quiz_id/questions/:position/answer", handlers.AnswerQuizQuestion(db))
+	}
+
+	// Answer grading
+	api.POST("/grade", handlers.GradeAnswer(db))
+
+	// Quiz routes
+	api.POST("/quizzes", handlers.CreateQuiz(db, launcher))
+	api.GET("/quizzes/:id", handlers.GetQuiz(db))
+
+	// Review scheduling routes
+	api.GET("/review/due", handlers.GetDueReviews(db))
+	api.GET("/review/forecast", handlers.GetReviewForecast(db))
 
 	// Reset routes
 	api.POST("/reset_history", handlers.ResetHistory(db))
@@ -2,6 +2,7 @@ package db
 
 import (
 	"database/sql"
+	"fmt"
 	"log"
 	"os"
 	"path/filepath"
@@ -19,9 +20,11 @@ func Initialize() error {
 		return err
 	}
 
-	// Open SQLite database
+	// Open SQLite database. Transactions take the write lock when they begin
+	// and concurrent writers wait for it, so that requests racing to claim
+	// the same row are serialized instead of failing with SQLITE_BUSY.
 	dbPath := filepath.Join(wd, "words.db")
-	db, err := sql.Open("sqlite3", dbPath)
+	db, err := sql.Open("sqlite3", "file:"+dbPath+"?_busy_timeout=5000&_txlock=immediate")
 	if err != nil {
 		return err
 	}
@@ -29,7 +32,7 @@ func Initialize() error {
 	DB = db
 
 	// Run migrations
-	if err := runMigrations(); err != nil {
+	if err := Migrate(DB, "./db/migrations"); err != nil {
 		return err
 	}
 
@@ -37,31 +40,63 @@ func Initialize() error {
 	return nil
 }
 
-// runMigrations executes all migration files in order
-func runMigrations() error {
+// Migrate executes the migration files in dir in order. Applied migrations
+// are recorded in schema_migrations so each file only runs once.
+func Migrate(conn *sql.DB, dir string) error {
+	_, err := conn.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version TEXT PRIMARY KEY,
+			applied_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`)
+	if err != nil {
+		return err
+	}
+
 	// Read migration files
-	migrationsDir := "./db/migrations"
-	files, err := os.ReadDir(migrationsDir)
+	files, err := os.ReadDir(dir)
 	if err != nil {
 		return err
 	}
 
-	// Execute each migration file
+	// Execute each pending migration file
 	for _, file := range files {
 		if filepath.Ext(file.Name()) != ".sql" {
 			continue
 		}
 
-		content, err := os.ReadFile(filepath.Join(migrationsDir, file.Name()))
+		var applied bool
+		err := conn.QueryRow("SELECT EXISTS(SELECT 1 FROM schema_migrations WHERE version = ?)", file.Name()).Scan(&applied)
+		if err != nil {
+			return err
+		}
+		if applied {
+			continue
+		}
+
+		content, err := os.ReadFile(filepath.Join(dir, file.Name()))
 		if err != nil {
 			return err
 		}
 
-		_, err = DB.Exec(string(content))
+		tx, err := conn.Begin()
 		if err != nil {
 			return err
 		}
 
+		if _, err := tx.Exec(string(content)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("error executing migration %s: %v", file.Name(), err)
+		}
+
+		if _, err := tx.Exec("INSERT INTO schema_migrations (version) VALUES (?)", file.Name()); err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+
 		log.Printf("Executed migration: %s\n", file.Name())
 	}
 
@@ -0,0 +1,14 @@
+-- Create word_relations table linking words to related vocabulary
+CREATE TABLE IF NOT EXISTS word_relations (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    word_id INTEGER NOT NULL,
+    related_word_id INTEGER NOT NULL,
+    relation_type TEXT NOT NULL CHECK (relation_type IN ('synonym', 'antonym', 'homophone', 'same_kanji', 'derived_from')),
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (word_id) REFERENCES words(id),
+    FOREIGN KEY (related_word_id) REFERENCES words(id)
+);
+
+-- Add indexes for word_relations
+CREATE UNIQUE INDEX IF NOT EXISTS idx_word_relations_unique ON word_relations(word_id, related_word_id, relation_type);
+CREATE INDEX IF NOT EXISTS idx_word_relations_related ON word_relations(related_word_id);
@@ -0,0 +1,17 @@
+-- Create word_media table for audio and image attachments. Files live in the
+-- content-addressed media store and are referenced by their SHA-256 hash.
+CREATE TABLE IF NOT EXISTS word_media (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    word_id INTEGER NOT NULL,
+    kind TEXT NOT NULL CHECK (kind IN ('audio', 'image')),
+    hash TEXT NOT NULL,
+    mime_type TEXT NOT NULL,
+    size INTEGER NOT NULL,
+    filename TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (word_id) REFERENCES words(id)
+);
+
+-- Add indexes for word_media
+CREATE UNIQUE INDEX IF NOT EXISTS idx_word_media_unique ON word_media(word_id, hash);
+CREATE INDEX IF NOT EXISTS idx_word_media_hash ON word_media(hash);
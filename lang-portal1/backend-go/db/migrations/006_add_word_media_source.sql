@@ -0,0 +1,7 @@
+-- Track where a media file came from so generated speech can be cached
+-- and regenerated when the word changes
+ALTER TABLE word_media ADD COLUMN source TEXT NOT NULL DEFAULT 'upload';
+ALTER TABLE word_media ADD COLUMN voice TEXT NOT NULL DEFAULT '';
+ALTER TABLE word_media ADD COLUMN source_text TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_word_media_word_kind ON word_media(word_id, kind, source);
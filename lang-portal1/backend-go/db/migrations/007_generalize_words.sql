@@ -0,0 +1,22 @@
+-- Generalize words beyond Japanese: a language code, the term in its native
+-- script, an optional romanization and one or more glosses (JSON array) in
+-- the learner's language. Existing rows become Japanese words.
+CREATE TABLE words_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    language TEXT NOT NULL DEFAULT 'ja',
+    term TEXT NOT NULL,
+    romanization TEXT,
+    glosses TEXT NOT NULL DEFAULT '[]', -- JSON string
+    parts TEXT NOT NULL DEFAULT '[]' -- JSON string
+);
+
+INSERT INTO words_new (id, language, term, romanization, glosses, parts)
+SELECT id, 'ja', japanese, NULLIF(romaji, ''), json_array(english), COALESCE(parts, '[]')
+FROM words;
+
+DROP TABLE words;
+ALTER TABLE words_new RENAME TO words;
+
+-- Add indexes for words table
+CREATE INDEX IF NOT EXISTS idx_words_language ON words(language);
+CREATE INDEX IF NOT EXISTS idx_words_term ON words(term);
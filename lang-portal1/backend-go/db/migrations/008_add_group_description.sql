@@ -0,0 +1,3 @@
+-- Groups accepted a description on create and update but had nowhere to
+-- store it
+ALTER TABLE groups ADD COLUMN description TEXT NOT NULL DEFAULT '';
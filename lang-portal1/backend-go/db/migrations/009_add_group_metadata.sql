@@ -0,0 +1,11 @@
+-- Display metadata for groups. SQLite cannot add columns with a
+-- CURRENT_TIMESTAMP default, so existing rows are backfilled instead.
+ALTER TABLE groups ADD COLUMN color TEXT;
+ALTER TABLE groups ADD COLUMN icon TEXT;
+ALTER TABLE groups ADD COLUMN sort_position INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE groups ADD COLUMN created_at DATETIME;
+ALTER TABLE groups ADD COLUMN updated_at DATETIME;
+
+UPDATE groups SET created_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_groups_sort_position ON groups(sort_position, id);
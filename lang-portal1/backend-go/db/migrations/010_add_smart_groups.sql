@@ -0,0 +1,4 @@
+-- Smart groups select their words with a stored filter (JSON) instead of
+-- listing them in words_groups
+ALTER TABLE groups ADD COLUMN kind TEXT NOT NULL DEFAULT 'manual' CHECK (kind IN ('manual', 'smart'));
+ALTER TABLE groups ADD COLUMN filter TEXT;
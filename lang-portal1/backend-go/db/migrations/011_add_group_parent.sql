@@ -0,0 +1,4 @@
+-- Nest groups (course -> unit -> lesson)
+ALTER TABLE groups ADD COLUMN parent_id INTEGER REFERENCES groups(id);
+
+CREATE INDEX IF NOT EXISTS idx_groups_parent_id ON groups(parent_id);
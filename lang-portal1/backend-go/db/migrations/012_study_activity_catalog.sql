@@ -0,0 +1,38 @@
+-- Turn study_activities back into the catalog of launchable activities.
+-- Migration 002 replaced it with a session/group link table that nothing
+-- reads, so its rows carry no information worth keeping.
+DROP INDEX IF EXISTS idx_study_activities_group;
+DROP INDEX IF EXISTS idx_study_activities_session;
+DROP TABLE IF EXISTS study_activities;
+
+CREATE TABLE study_activities (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    thumbnail_url TEXT,
+    launch_url TEXT,
+    enabled BOOLEAN NOT NULL DEFAULT 1,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_study_activities_name ON study_activities(name);
+
+-- Seed the activities of this repository
+INSERT INTO study_activities (id, name, description, thumbnail_url, launch_url, enabled) VALUES
+    (1, 'Flashcards', 'Review the words of a group on flip cards and mark each one as known or not.',
+        '/images/study_activities/flashcards.png', 'http://localhost:5173', 1),
+    (2, 'Writing Practice', 'Write the word for an English prompt by hand and have it graded.',
+        '/images/study_activities/writing-practice.png', 'http://localhost:8501', 1),
+    (3, 'Listening Comprehension', 'Answer questions about short listening exercises built from the group.',
+        '/images/study_activities/listening-comp.png', 'http://localhost:8502', 1),
+    (4, 'Sentence Constructor', 'Build sentences with the help of a teaching assistant chat.',
+        '/images/study_activities/sentence-constructor.png', NULL, 0),
+    (5, 'Song Vocabulary', 'Learn the vocabulary of song lyrics.',
+        '/images/study_activities/song-vocab.png', 'http://localhost:8000', 1);
+
+-- Keep existing sessions pointing at an activity
+INSERT INTO study_activities (id, name, enabled)
+SELECT DISTINCT study_activity_id, 'Study activity ' || study_activity_id, 0
+FROM study_sessions
+WHERE study_activity_id NOT IN (SELECT id FROM study_activities);
@@ -0,0 +1,12 @@
+-- Track the lifecycle of study sessions
+ALTER TABLE study_sessions ADD COLUMN status TEXT NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'completed', 'abandoned'));
+ALTER TABLE study_sessions ADD COLUMN completed_at DATETIME;
+
+-- Sessions from before the lifecycle are over, ending with their last review
+UPDATE study_sessions
+SET status = 'completed',
+    completed_at = COALESCE(
+        (SELECT MAX(created_at) FROM word_review_items WHERE study_session_id = study_sessions.id),
+        created_at);
+
+CREATE INDEX IF NOT EXISTS idx_study_sessions_status ON study_sessions(status);
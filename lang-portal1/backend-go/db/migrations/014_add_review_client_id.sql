@@ -0,0 +1,4 @@
+-- Let offline clients replay reviews without recording them twice
+ALTER TABLE word_review_items ADD COLUMN client_id TEXT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_word_reviews_client ON word_review_items(study_session_id, client_id) WHERE client_id IS NOT NULL;
@@ -0,0 +1,31 @@
+-- Allow several attempts at a word in a session. Reviews get a surrogate key
+-- and an attempt number instead of the (word_id, study_session_id) key.
+CREATE TABLE word_review_attempts (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    word_id INTEGER NOT NULL,
+    study_session_id INTEGER NOT NULL,
+    attempt_number INTEGER NOT NULL DEFAULT 1,
+    correct BOOLEAN NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    client_id TEXT,
+    FOREIGN KEY (word_id) REFERENCES words(id),
+    FOREIGN KEY (study_session_id) REFERENCES study_sessions(id),
+    UNIQUE (study_session_id, word_id, attempt_number)
+);
+
+INSERT INTO word_review_attempts (word_id, study_session_id, attempt_number, correct, created_at, client_id)
+SELECT word_id, study_session_id, 1, correct, created_at, client_id
+FROM word_review_items
+ORDER BY created_at, study_session_id, word_id;
+
+DROP TABLE word_review_items;
+ALTER TABLE word_review_attempts RENAME TO word_review_items;
+
+CREATE INDEX IF NOT EXISTS idx_word_reviews_session ON word_review_items(study_session_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_word_reviews_word ON word_review_items(word_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_word_reviews_stats ON word_review_items(word_id, correct);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_word_reviews_client ON word_review_items(study_session_id, client_id) WHERE client_id IS NOT NULL;
+
+-- Stats that only count the first attempt at a word in a session
+CREATE VIEW IF NOT EXISTS first_word_review_items AS
+SELECT * FROM word_review_items WHERE attempt_number = 1;
@@ -0,0 +1,7 @@
+-- Record how a word was answered, not only whether it was right
+ALTER TABLE word_review_items ADD COLUMN answer TEXT;
+ALTER TABLE word_review_items ADD COLUMN expected_answer TEXT;
+ALTER TABLE word_review_items ADD COLUMN response_time_ms INTEGER CHECK (response_time_ms >= 0);
+ALTER TABLE word_review_items ADD COLUMN hint_used BOOLEAN NOT NULL DEFAULT 0;
+ALTER TABLE word_review_items ADD COLUMN quality INTEGER CHECK (quality BETWEEN 0 AND 5);
+ALTER TABLE word_review_items ADD COLUMN client_app TEXT;
@@ -0,0 +1,17 @@
+-- Spaced repetition scheduling state, one row per reviewed word. Rows are
+-- derived from word_review_items and rebuilt by the server when missing.
+CREATE TABLE IF NOT EXISTS word_srs_state (
+    word_id INTEGER PRIMARY KEY,
+    algorithm TEXT NOT NULL,
+    repetitions INTEGER NOT NULL DEFAULT 0,
+    interval_days REAL NOT NULL DEFAULT 0,
+    ease REAL NOT NULL DEFAULT 0,
+    stability REAL NOT NULL DEFAULT 0,
+    difficulty REAL NOT NULL DEFAULT 0,
+    lapses INTEGER NOT NULL DEFAULT 0,
+    due_at DATETIME NOT NULL,
+    last_reviewed_at DATETIME NOT NULL,
+    FOREIGN KEY (word_id) REFERENCES words(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_word_srs_state_due_at ON word_srs_state(due_at);
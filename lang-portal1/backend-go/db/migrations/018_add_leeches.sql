@@ -0,0 +1,8 @@
+-- Suspended words are left out of study queues until unsuspended
+ALTER TABLE words ADD COLUMN suspended_at DATETIME;
+
+-- Count failed reviews alongside the scheduling state to detect leeches.
+-- States are rebuilt from the reviews, with the counts, on the next start.
+ALTER TABLE word_srs_state ADD COLUMN failures INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE word_srs_state ADD COLUMN consecutive_failures INTEGER NOT NULL DEFAULT 0;
+DELETE FROM word_srs_state;
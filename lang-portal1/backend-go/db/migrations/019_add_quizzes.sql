@@ -0,0 +1,26 @@
+-- Multiple-choice quizzes, answered into a linked study session
+CREATE TABLE IF NOT EXISTS quizzes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    study_session_id INTEGER NOT NULL,
+    group_id INTEGER NOT NULL,
+    direction TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (study_session_id) REFERENCES study_sessions(id),
+    FOREIGN KEY (group_id) REFERENCES groups(id)
+);
+
+CREATE TABLE IF NOT EXISTS quiz_questions (
+    quiz_id INTEGER NOT NULL,
+    position INTEGER NOT NULL,
+    word_id INTEGER NOT NULL,
+    prompt TEXT NOT NULL,
+    options TEXT NOT NULL, -- JSON array of strings
+    correct_option INTEGER NOT NULL,
+    selected_option INTEGER,
+    review_id INTEGER,
+    PRIMARY KEY (quiz_id, position),
+    FOREIGN KEY (quiz_id) REFERENCES quizzes(id),
+    FOREIGN KEY (word_id) REFERENCES words(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_quizzes_study_session_id ON quizzes(study_session_id);
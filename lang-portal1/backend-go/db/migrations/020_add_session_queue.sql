@@ -0,0 +1,12 @@
+-- Study sessions serve an ordered word queue built when they start
+ALTER TABLE study_sessions ADD COLUMN strategy TEXT;
+ALTER TABLE study_sessions ADD COLUMN queue_seed INTEGER;
+
+CREATE TABLE IF NOT EXISTS study_session_queue (
+    study_session_id INTEGER NOT NULL,
+    position INTEGER NOT NULL,
+    word_id INTEGER NOT NULL,
+    PRIMARY KEY (study_session_id, position),
+    FOREIGN KEY (study_session_id) REFERENCES study_sessions(id),
+    FOREIGN KEY (word_id) REFERENCES words(id)
+);
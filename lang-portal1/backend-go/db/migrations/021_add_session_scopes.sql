@@ -0,0 +1,72 @@
+-- Study sessions may cover several groups, a list of words or a search
+-- query instead of a single group. group_id is only set for single-group
+-- sessions, so the table is rebuilt without its NOT NULL constraint.
+CREATE TABLE study_sessions_scoped (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    group_id INTEGER,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    study_activity_id INTEGER NOT NULL,
+    status TEXT NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'completed', 'abandoned')),
+    completed_at DATETIME,
+    strategy TEXT,
+    queue_seed INTEGER,
+    scope TEXT NOT NULL DEFAULT 'group' CHECK (scope IN ('group', 'groups', 'words', 'query')),
+    query TEXT, -- JSON smart group filter of query sessions
+    FOREIGN KEY (group_id) REFERENCES groups(id),
+    FOREIGN KEY (study_activity_id) REFERENCES study_activities(id)
+);
+
+INSERT INTO study_sessions_scoped (id, group_id, created_at, study_activity_id, status, completed_at, strategy, queue_seed)
+SELECT id, group_id, created_at, study_activity_id, status, completed_at, strategy, queue_seed
+FROM study_sessions;
+
+DROP TABLE study_sessions;
+ALTER TABLE study_sessions_scoped RENAME TO study_sessions;
+
+CREATE INDEX IF NOT EXISTS idx_study_sessions_group_id ON study_sessions(group_id);
+CREATE INDEX IF NOT EXISTS idx_study_sessions_created_at ON study_sessions(created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_study_sessions_activity ON study_sessions(study_activity_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_study_sessions_status ON study_sessions(status);
+
+-- The groups a session was started on
+CREATE TABLE IF NOT EXISTS study_session_groups (
+    study_session_id INTEGER NOT NULL,
+    group_id INTEGER NOT NULL,
+    PRIMARY KEY (study_session_id, group_id),
+    FOREIGN KEY (study_session_id) REFERENCES study_sessions(id),
+    FOREIGN KEY (group_id) REFERENCES groups(id)
+);
+
+INSERT INTO study_session_groups (study_session_id, group_id)
+SELECT id, group_id FROM study_sessions;
+
+CREATE INDEX IF NOT EXISTS idx_study_session_groups_group ON study_session_groups(group_id);
+
+-- The words of sessions that are not over a single group, as they were when
+-- the session started. Words of multi-group sessions have a row for each
+-- group they were drawn from; group_id is NULL for word list and query
+-- sessions.
+CREATE TABLE IF NOT EXISTS study_session_words (
+    study_session_id INTEGER NOT NULL,
+    word_id INTEGER NOT NULL,
+    group_id INTEGER,
+    FOREIGN KEY (study_session_id) REFERENCES study_sessions(id),
+    FOREIGN KEY (word_id) REFERENCES words(id),
+    FOREIGN KEY (group_id) REFERENCES groups(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_study_session_words ON study_session_words(study_session_id, word_id);
+
+-- Reviews attributed to the groups they were studied in: every review of a
+-- single-group session counts for its group, and a review in a multi-group
+-- session for the groups the word was drawn from
+CREATE VIEW IF NOT EXISTS group_review_items AS
+SELECT sg.group_id, wri.*
+FROM word_review_items wri
+JOIN study_sessions ss ON ss.id = wri.study_session_id
+JOIN study_session_groups sg ON sg.study_session_id = ss.id
+WHERE ss.scope = 'group'
+    OR EXISTS (
+        SELECT 1 FROM study_session_words sw
+        WHERE sw.study_session_id = ss.id AND sw.word_id = wri.word_id AND sw.group_id = sg.group_id
+    );
@@ -0,0 +1,6 @@
+-- Store the kana reading of Japanese words so that homophones can be looked
+-- up with an index. Readings are derived from the parts in Go: NULL means not
+-- computed yet and is filled in at startup, '' means the word has none.
+ALTER TABLE words ADD COLUMN reading TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_words_reading ON words(language, reading);
@@ -0,0 +1,20 @@
+-- Number the attempts at a word in a session by when they were made rather
+-- than when they were recorded, so that a review synced later from an
+-- offline client takes its place among the others. Numbers are negated
+-- first to keep (study_session_id, word_id, attempt_number) unique while
+-- they are rewritten.
+UPDATE word_review_items SET attempt_number = -attempt_number;
+
+UPDATE word_review_items
+SET attempt_number = (
+    SELECT COUNT(*)
+    FROM word_review_items earlier
+    WHERE earlier.study_session_id = word_review_items.study_session_id
+        AND earlier.word_id = word_review_items.word_id
+        AND (earlier.created_at < word_review_items.created_at
+            OR (earlier.created_at = word_review_items.created_at AND earlier.id <= word_review_items.id))
+);
+
+-- The scheduling state and failure counts follow the first attempts, and are
+-- rebuilt from them on the next start
+DELETE FROM word_srs_state;
@@ -0,0 +1,3 @@
+-- Remember the review that suspended a leech, so that undoing that review
+-- also lifts the suspension
+ALTER TABLE words ADD COLUMN suspended_by_review_id INTEGER REFERENCES word_review_items(id);
@@ -0,0 +1,21 @@
+-- Reviews in word list and query sessions were drawn from no group and so
+-- counted for none. They now count for every group the word belongs to, so
+-- that group success rates cover all the reviews the dashboard does.
+DROP VIEW IF EXISTS group_review_items;
+
+CREATE VIEW group_review_items AS
+SELECT sg.group_id, wri.*
+FROM word_review_items wri
+JOIN study_sessions ss ON ss.id = wri.study_session_id
+JOIN study_session_groups sg ON sg.study_session_id = ss.id
+WHERE ss.scope = 'group'
+    OR EXISTS (
+        SELECT 1 FROM study_session_words sw
+        WHERE sw.study_session_id = ss.id AND sw.word_id = wri.word_id AND sw.group_id = sg.group_id
+    )
+UNION ALL
+SELECT wg.group_id, wri.*
+FROM word_review_items wri
+JOIN study_sessions ss ON ss.id = wri.study_session_id
+JOIN (SELECT DISTINCT word_id, group_id FROM words_groups) wg ON wg.word_id = wri.word_id
+WHERE ss.scope IN ('words', 'query');
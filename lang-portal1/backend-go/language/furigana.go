@@ -0,0 +1,143 @@
+package language
+
+import (
+	"errors"
+	"html"
+	"strings"
+)
+
+// Furigana output formats
+const (
+	FuriganaHTML = "html"
+	FuriganaAnki = "anki"
+	FuriganaJSON = "json"
+)
+
+var ErrUnknownFuriganaFormat = errors.New("unknown furigana format")
+
+// FuriganaToken is a run of text with the reading shown above it. Kana runs
+// have no reading.
+type FuriganaToken struct {
+	Text    string `json:"text"`
+	Reading string `json:"reading,omitempty"`
+}
+
+// AlignFurigana splits text into tokens so that readings only sit above
+// kanji, leaving okurigana and other kana bare, e.g. 払う/はらう becomes
+// 払[はら] + う. If the kana in text cannot be matched against reading the
+// whole text gets the whole reading.
+func AlignFurigana(text, reading string) []FuriganaToken {
+	if text == "" {
+		return nil
+	}
+	reading = KatakanaToHiragana(reading)
+	if reading == "" || IsAllKana(text) {
+		return []FuriganaToken{{Text: text}}
+	}
+
+	if tokens, ok := alignRuns(scriptRuns(text), []rune(reading)); ok {
+		return tokens
+	}
+	return []FuriganaToken{{Text: text, Reading: reading}}
+}
+
+// scriptRuns splits text into alternating kana and non-kana runs
+func scriptRuns(text string) []string {
+	var runs []string
+	var current []rune
+	currentKana := false
+	for _, r := range text {
+		kana := IsKana(r)
+		if len(current) > 0 && kana != currentKana {
+			runs = append(runs, string(current))
+			current = nil
+		}
+		current = append(current, r)
+		currentKana = kana
+	}
+	if len(current) > 0 {
+		runs = append(runs, string(current))
+	}
+	return runs
+}
+
+// alignRuns matches runs against reading, backtracking over how much of the
+// reading each kanji run takes
+func alignRuns(runs []string, reading []rune) ([]FuriganaToken, bool) {
+	if len(runs) == 0 {
+		return nil, len(reading) == 0
+	}
+
+	run := runs[0]
+	if IsAllKana(run) {
+		kana := []rune(KatakanaToHiragana(run))
+		if len(reading) < len(kana) || string(reading[:len(kana)]) != string(kana) {
+			return nil, false
+		}
+		rest, ok := alignRuns(runs[1:], reading[len(kana):])
+		if !ok {
+			return nil, false
+		}
+		return append([]FuriganaToken{{Text: run}}, rest...), true
+	}
+
+	// A kanji run reads as at least one kana
+	for size := 1; size <= len(reading); size++ {
+		if len(runs) == 1 && size != len(reading) {
+			continue
+		}
+		rest, ok := alignRuns(runs[1:], reading[size:])
+		if ok {
+			return append([]FuriganaToken{{Text: run, Reading: string(reading[:size])}}, rest...), true
+		}
+	}
+	return nil, false
+}
+
+// MergeFurigana joins adjacent bare tokens
+func MergeFurigana(tokens []FuriganaToken) []FuriganaToken {
+	var merged []FuriganaToken
+	for _, t := range tokens {
+		if n := len(merged); n > 0 && t.Reading == "" && merged[n-1].Reading == "" {
+			merged[n-1].Text += t.Text
+			continue
+		}
+		merged = append(merged, t)
+	}
+	return merged
+}
+
+// RenderFurigana formats tokens as ruby HTML, Anki bracket notation or the
+// token list itself
+func RenderFurigana(tokens []FuriganaToken, format string) (interface{}, error) {
+	switch format {
+	case FuriganaJSON:
+		return tokens, nil
+	case FuriganaHTML:
+		var out strings.Builder
+		for _, t := range tokens {
+			if t.Reading == "" {
+				out.WriteString(html.EscapeString(t.Text))
+				continue
+			}
+			out.WriteString("<ruby>" + html.EscapeString(t.Text) + "<rt>" + html.EscapeString(t.Reading) + "</rt></ruby>")
+		}
+		return out.String(), nil
+	case FuriganaAnki:
+		// Anki reads the base text back to the previous space, so every
+		// annotated token after the first needs one in front of it
+		var out strings.Builder
+		for i, t := range tokens {
+			if t.Reading == "" {
+				out.WriteString(t.Text)
+				continue
+			}
+			if i > 0 {
+				out.WriteString(" ")
+			}
+			out.WriteString(t.Text + "[" + t.Reading + "]")
+		}
+		return out.String(), nil
+	}
+	return nil, ErrUnknownFuriganaFormat
+}
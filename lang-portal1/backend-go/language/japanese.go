@@ -0,0 +1,97 @@
+package language
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Japanese validates kana/kanji terms and romanizes kana with Hepburn
+type Japanese struct{}
+
+func (Japanese) Code() string { return "ja" }
+func (Japanese) Name() string { return "Japanese" }
+
+func (Japanese) Validate(term, romanization string) error {
+	return validateTerm(term, romanization, unicode.Hiragana, unicode.Katakana, unicode.Han)
+}
+
+func (Japanese) Romanize(text string) (string, bool) {
+	if !IsAllKana(text) {
+		return "", false
+	}
+	return HiraganaToRomaji(KatakanaToHiragana(text)), true
+}
+
+// kanaToRomaji is the Hepburn romanization of each hiragana syllable
+var kanaToRomaji = map[string]string{}
+
+func init() {
+	// Prefer Hepburn spellings when several romaji map to the same kana
+	preferred := map[string]bool{
+		"si": false, "ti": false, "tu": false, "hu": false, "zi": false,
+		"sya": false, "syu": false, "syo": false, "tya": false, "tyu": false, "tyo": false,
+		"zya": false, "zyu": false, "zyo": false, "n'": false, "-": false,
+	}
+	for romaji, kana := range romajiToKana {
+		if _, skip := preferred[romaji]; skip {
+			continue
+		}
+		kanaToRomaji[kana] = romaji
+	}
+	kanaToRomaji["ん"] = "n"
+	kanaToRomaji["ぢ"] = "ji"
+	kanaToRomaji["づ"] = "zu"
+}
+
+// HiraganaToRomaji converts hiragana to Hepburn romaji. Characters that
+// cannot be converted are passed through unchanged.
+func HiraganaToRomaji(kana string) string {
+	runes := []rune(kana)
+	var out strings.Builder
+	for i := 0; i < len(runes); {
+		// Small tsu doubles the next consonant
+		if runes[i] == 'っ' {
+			if i+1 < len(runes) {
+				if next, ok := kanaToRomaji[string(runes[i+1])]; ok {
+					if strings.HasPrefix(next, "ch") {
+						out.WriteByte('t')
+					} else {
+						out.WriteByte(next[0])
+					}
+				}
+			}
+			i++
+			continue
+		}
+
+		// Long vowel mark repeats the previous vowel
+		if runes[i] == 'ー' {
+			if s := out.String(); s != "" {
+				out.WriteByte(s[len(s)-1])
+			}
+			i++
+			continue
+		}
+
+		if i+1 < len(runes) {
+			if romaji, ok := kanaToRomaji[string(runes[i:i+2])]; ok {
+				out.WriteString(romaji)
+				i += 2
+				continue
+			}
+		}
+		if romaji, ok := kanaToRomaji[string(runes[i])]; ok {
+			// Mark syllabic n before a vowel or y, e.g. kin'en
+			if runes[i] == 'ん' && i+1 < len(runes) {
+				if next, ok := kanaToRomaji[string(runes[i+1])]; ok && strings.ContainsRune("aiueoy", rune(next[0])) {
+					romaji = "n'"
+				}
+			}
+			out.WriteString(romaji)
+		} else {
+			out.WriteRune(runes[i])
+		}
+		i++
+	}
+	return out.String()
+}
@@ -0,0 +1,159 @@
+package language
+
+import (
+	"strings"
+	"unicode"
+)
+
+// romajiToKana maps Hepburn (and common Kunrei/Nihon-shiki) syllables to hiragana.
+var romajiToKana = map[string]string{
+	"a": "あ", "i": "い", "u": "う", "e": "え", "o": "お",
+	"ka": "か", "ki": "き", "ku": "く", "ke": "け", "ko": "こ",
+	"sa": "さ", "shi": "し", "si": "し", "su": "す", "se": "せ", "so": "そ",
+	"ta": "た", "chi": "ち", "ti": "ち", "tsu": "つ", "tu": "つ", "te": "て", "to": "と",
+	"na": "な", "ni": "に", "nu": "ぬ", "ne": "ね", "no": "の",
+	"ha": "は", "hi": "ひ", "fu": "ふ", "hu": "ふ", "he": "へ", "ho": "ほ",
+	"ma": "ま", "mi": "み", "mu": "む", "me": "め", "mo": "も",
+	"ya": "や", "yu": "ゆ", "yo": "よ",
+	"ra": "ら", "ri": "り", "ru": "る", "re": "れ", "ro": "ろ",
+	"wa": "わ", "wo": "を", "n'": "ん",
+	"ga": "が", "gi": "ぎ", "gu": "ぐ", "ge": "げ", "go": "ご",
+	"za": "ざ", "ji": "じ", "zi": "じ", "zu": "ず", "ze": "ぜ", "zo": "ぞ",
+	"da": "だ", "di": "ぢ", "du": "づ", "de": "で", "do": "ど",
+	"ba": "ば", "bi": "び", "bu": "ぶ", "be": "べ", "bo": "ぼ",
+	"pa": "ぱ", "pi": "ぴ", "pu": "ぷ", "pe": "ぺ", "po": "ぽ",
+	"kya": "きゃ", "kyu": "きゅ", "kyo": "きょ",
+	"sha": "しゃ", "shu": "しゅ", "sho": "しょ", "sya": "しゃ", "syu": "しゅ", "syo": "しょ",
+	"cha": "ちゃ", "chu": "ちゅ", "cho": "ちょ", "tya": "ちゃ", "tyu": "ちゅ", "tyo": "ちょ",
+	"nya": "にゃ", "nyu": "にゅ", "nyo": "にょ",
+	"hya": "ひゃ", "hyu": "ひゅ", "hyo": "ひょ",
+	"mya": "みゃ", "myu": "みゅ", "myo": "みょ",
+	"rya": "りゃ", "ryu": "りゅ", "ryo": "りょ",
+	"gya": "ぎゃ", "gyu": "ぎゅ", "gyo": "ぎょ",
+	"ja": "じゃ", "ju": "じゅ", "jo": "じょ", "zya": "じゃ", "zyu": "じゅ", "zyo": "じょ",
+	"bya": "びゃ", "byu": "びゅ", "byo": "びょ",
+	"pya": "ぴゃ", "pyu": "ぴゅ", "pyo": "ぴょ",
+	"fa": "ふぁ", "fi": "ふぃ", "fe": "ふぇ", "fo": "ふぉ",
+	"she": "しぇ", "che": "ちぇ", "je": "じぇ",
+	"-": "ー",
+}
+
+// macrons expands long vowels written with a macron or circumflex.
+var macrons = map[rune]string{
+	'ā': "aa", 'ī': "ii", 'ū': "uu", 'ē': "ei", 'ō': "ou",
+	'â': "aa", 'î': "ii", 'û': "uu", 'ê': "ei", 'ô': "ou",
+}
+
+// RomajiToHiragana converts romanized Japanese to hiragana. Characters that
+// cannot be converted are passed through unchanged.
+func RomajiToHiragana(romaji string) string {
+	var expanded strings.Builder
+	for _, r := range strings.ToLower(romaji) {
+		if long, ok := macrons[r]; ok {
+			expanded.WriteString(long)
+			continue
+		}
+		expanded.WriteRune(r)
+	}
+	input := expanded.String()
+
+	var out strings.Builder
+	for i := 0; i < len(input); {
+		c := input[i]
+
+		// Doubled consonant becomes a small tsu (kk -> っk)
+		if i+1 < len(input) && c == input[i+1] && isConsonant(c) && c != 'n' {
+			out.WriteString("っ")
+			i++
+			continue
+		}
+		if c == 't' && strings.HasPrefix(input[i:], "tch") {
+			out.WriteString("っ")
+			i++
+			continue
+		}
+
+		// Syllabic n: "nn" or n before a consonant or at the end of the input
+		if c == 'n' && i+1 < len(input) && input[i+1] == 'n' {
+			out.WriteString("ん")
+			i++
+			if i+1 == len(input) || (isConsonant(input[i+1]) && input[i+1] != 'y') {
+				i++
+			}
+			continue
+		}
+		if c == 'n' && (i+1 == len(input) || (isConsonant(input[i+1]) && input[i+1] != 'y')) {
+			out.WriteString("ん")
+			i++
+			continue
+		}
+
+		matched := false
+		for size := 3; size > 0; size-- {
+			if i+size > len(input) {
+				continue
+			}
+			if kana, ok := romajiToKana[input[i:i+size]]; ok {
+				out.WriteString(kana)
+				i += size
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			out.WriteByte(c)
+			i++
+		}
+	}
+	return out.String()
+}
+
+// KatakanaToHiragana maps katakana characters to their hiragana equivalents.
+func KatakanaToHiragana(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'ァ' && r <= 'ヶ' {
+			return r - 0x60
+		}
+		return r
+	}, s)
+}
+
+// IsKana reports whether r is a hiragana or katakana character.
+func IsKana(r rune) bool {
+	return unicode.In(r, unicode.Hiragana, unicode.Katakana) || r == 'ー'
+}
+
+// IsKanji reports whether r is a CJK ideograph.
+func IsKanji(r rune) bool {
+	return unicode.Is(unicode.Han, r)
+}
+
+// KanjiIn returns the distinct kanji that appear in s, in order.
+func KanjiIn(s string) []string {
+	seen := make(map[rune]bool)
+	var kanji []string
+	for _, r := range s {
+		if IsKanji(r) && !seen[r] {
+			seen[r] = true
+			kanji = append(kanji, string(r))
+		}
+	}
+	return kanji
+}
+
+// IsAllKana reports whether s is non-empty and made up only of kana.
+func IsAllKana(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if !IsKana(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func isConsonant(c byte) bool {
+	return c >= 'a' && c <= 'z' && !strings.ContainsRune("aeiou", rune(c))
+}
@@ -0,0 +1,66 @@
+package language
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Korean validates Hangul terms and romanizes them with the Revised
+// Romanization of Korean
+type Korean struct{}
+
+func (Korean) Code() string { return "ko" }
+func (Korean) Name() string { return "Korean" }
+
+func (Korean) Validate(term, romanization string) error {
+	return validateTerm(term, romanization, unicode.Hangul)
+}
+
+var (
+	hangulInitials = []string{"g", "kk", "n", "d", "tt", "r", "m", "b", "pp", "s", "ss", "", "j", "jj", "ch", "k", "t", "p", "h"}
+	hangulMedials  = []string{"a", "ae", "ya", "yae", "eo", "e", "yeo", "ye", "o", "wa", "wae", "oe", "yo", "u", "wo", "we", "wi", "yu", "eu", "ui", "i"}
+	hangulFinals   = []string{"", "k", "k", "k", "n", "n", "n", "t", "l", "k", "m", "l", "l", "l", "p", "l", "m", "p", "p", "t", "t", "ng", "t", "t", "k", "t", "p", "t"}
+	// finalsLinked is how a final consonant is read when the next syllable
+	// starts with a silent ㅇ
+	finalsLinked = []string{"", "g", "kk", "ks", "n", "nj", "n", "d", "r", "lg", "lm", "lb", "ls", "lt", "lp", "r", "m", "b", "bs", "s", "ss", "ng", "j", "ch", "k", "t", "p", ""}
+)
+
+const (
+	hangulBase    = 0xAC00
+	hangulLast    = 0xD7A3
+	silentInitial = 11
+)
+
+func (Korean) Romanize(text string) (string, bool) {
+	if !containsScript(text, unicode.Hangul) {
+		return "", false
+	}
+
+	runes := []rune(text)
+	var out strings.Builder
+	for i, r := range runes {
+		if r < hangulBase || r > hangulLast {
+			out.WriteRune(r)
+			continue
+		}
+		index := int(r - hangulBase)
+		initial, medial, final := index/(21*28), (index%(21*28))/28, index%28
+
+		out.WriteString(hangulInitials[initial])
+		out.WriteString(hangulMedials[medial])
+
+		if final == 0 {
+			continue
+		}
+		nextSilent := false
+		if i+1 < len(runes) && runes[i+1] >= hangulBase && runes[i+1] <= hangulLast {
+			nextSilent = int(runes[i+1]-hangulBase)/(21*28) == silentInitial
+		}
+		if nextSilent {
+			out.WriteString(finalsLinked[final])
+		} else {
+			out.WriteString(hangulFinals[final])
+		}
+	}
+	return out.String(), true
+}
@@ -0,0 +1,92 @@
+package language
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Default is the language assumed when a request does not name one
+const Default = "ja"
+
+var (
+	ErrEmptyTerm           = errors.New("term is required")
+	ErrInvalidScript       = errors.New("term is not written in the language's script")
+	ErrInvalidRomanization = errors.New("romanization must be written in Latin letters")
+)
+
+// Language holds the per-language rules for vocabulary
+type Language interface {
+	// Code is the ISO 639-1 code, e.g. "ja"
+	Code() string
+	// Name is the English name of the language
+	Name() string
+	// Validate checks a term and its optional romanization
+	Validate(term, romanization string) error
+	// Romanize derives a romanization from native-script text, reporting
+	// false when that is not possible without a dictionary
+	Romanize(text string) (string, bool)
+}
+
+var registry = map[string]Language{}
+
+// Register makes a language available to the portal
+func Register(l Language) {
+	registry[l.Code()] = l
+}
+
+// Get looks up a language by code
+func Get(code string) (Language, bool) {
+	l, ok := registry[strings.ToLower(code)]
+	return l, ok
+}
+
+// All returns the registered languages ordered by code
+func All() []Language {
+	languages := make([]Language, 0, len(registry))
+	for _, l := range registry {
+		languages = append(languages, l)
+	}
+	sort.Slice(languages, func(i, j int) bool { return languages[i].Code() < languages[j].Code() })
+	return languages
+}
+
+func init() {
+	Register(Japanese{})
+	Register(Korean{})
+	Register(Mandarin{})
+}
+
+// containsScript reports whether s contains at least one rune from tables
+func containsScript(s string, tables ...*unicode.RangeTable) bool {
+	for _, r := range s {
+		if unicode.In(r, tables...) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateLatin accepts Latin letters (including accented ones), digits for
+// tone numbers, spaces and common punctuation
+func validateLatin(s string) error {
+	for _, r := range s {
+		if unicode.Is(unicode.Latin, r) || unicode.IsDigit(r) || unicode.IsSpace(r) ||
+			unicode.Is(unicode.Mn, r) || strings.ContainsRune("'-.,!?()~", r) {
+			continue
+		}
+		return ErrInvalidRomanization
+	}
+	return nil
+}
+
+func validateTerm(term, romanization string, tables ...*unicode.RangeTable) error {
+	if strings.TrimSpace(term) == "" {
+		return ErrEmptyTerm
+	}
+	if !containsScript(term, tables...) {
+		return ErrInvalidScript
+	}
+	return validateLatin(romanization)
+}
@@ -0,0 +1,18 @@
+package language
+
+import "unicode"
+
+// Mandarin validates Han character terms. Pinyin has to be supplied since
+// readings cannot be derived without a dictionary.
+type Mandarin struct{}
+
+func (Mandarin) Code() string { return "zh" }
+func (Mandarin) Name() string { return "Mandarin" }
+
+func (Mandarin) Validate(term, romanization string) error {
+	return validateTerm(term, romanization, unicode.Han)
+}
+
+func (Mandarin) Romanize(text string) (string, bool) {
+	return "", false
+}
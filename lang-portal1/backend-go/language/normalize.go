@@ -0,0 +1,124 @@
+package language
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// FoldWidth maps full-width Latin letters and digits to ASCII and half-width
+// katakana to full width (NFKC)
+func FoldWidth(s string) string {
+	return norm.NFKC.String(s)
+}
+
+// smallKanaVowels are the vowels of the small kana that end a syllable
+var smallKanaVowels = map[rune]byte{
+	'ゃ': 'a', 'ゅ': 'u', 'ょ': 'o',
+	'ぁ': 'a', 'ぃ': 'i', 'ぅ': 'u', 'ぇ': 'e', 'ぉ': 'o',
+}
+
+// longVowels are the kana that lengthen a syllable ending in each vowel,
+// e.g. おう and おお both lengthen o
+var longVowels = map[byte]string{
+	'a': "あ", 'i': "い", 'u': "う", 'e': "いえ", 'o': "うお",
+}
+
+// NormalizeKana canonicalizes Japanese for comparing answers: width and case
+// are folded, spaces dropped, romaji and katakana become hiragana and long
+// vowels, whether written ー, う or a macron, become ー
+func NormalizeKana(s string) string {
+	s = strings.ToLower(FoldWidth(s))
+	s = strings.Join(strings.Fields(s), "")
+	if containsScript(s, unicode.Latin) {
+		s = RomajiToHiragana(s)
+	}
+	s = KatakanaToHiragana(s)
+
+	var out strings.Builder
+	var vowel byte
+	for _, r := range s {
+		if r == 'ー' || (vowel != 0 && strings.ContainsRune(longVowels[vowel], r)) {
+			out.WriteRune('ー')
+			continue
+		}
+		out.WriteRune(r)
+		if v, ok := smallKanaVowels[r]; ok {
+			vowel = v
+		} else if romaji, ok := kanaToRomaji[string(r)]; ok && romaji != "n" {
+			vowel = romaji[len(romaji)-1]
+		} else {
+			vowel = 0
+		}
+	}
+	return out.String()
+}
+
+// CollapseLongVowels drops the long vowel marks of normalized kana, to tell
+// answers that only get the vowel length wrong
+func CollapseLongVowels(kana string) string {
+	return strings.ReplaceAll(kana, "ー", "")
+}
+
+// NormalizeLatin canonicalizes romanizations and English answers: width and
+// case are folded, punctuation dropped and spaces collapsed
+func NormalizeLatin(s string) string {
+	s = strings.ToLower(FoldWidth(s))
+	s = strings.Map(func(r rune) rune {
+		if unicode.IsPunct(r) && r != '\'' {
+			return ' '
+		}
+		return r
+	}, s)
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// StripMarks removes diacritics and tone numbers, e.g. "nǐ hǎo" and
+// "ni3 hao3" both become "ni hao"
+func StripMarks(s string) string {
+	var out strings.Builder
+	for _, r := range norm.NFD.String(s) {
+		if unicode.Is(unicode.Mn, r) || unicode.IsDigit(r) {
+			continue
+		}
+		out.WriteRune(r)
+	}
+	return strings.Join(strings.Fields(out.String()), " ")
+}
+
+// glossFillers are dropped from the start of English answers
+var glossFillers = []string{"to ", "a ", "an ", "the "}
+
+// NormalizeGloss canonicalizes an English meaning: notes in parentheses and
+// a leading "to" or article are dropped
+func NormalizeGloss(s string) string {
+	var out strings.Builder
+	depth := 0
+	for _, r := range s {
+		switch {
+		case r == '(':
+			depth++
+		case r == ')' && depth > 0:
+			depth--
+		case depth == 0:
+			out.WriteRune(r)
+		}
+	}
+	s = NormalizeLatin(out.String())
+	for _, filler := range glossFillers {
+		s = strings.TrimPrefix(s, filler)
+	}
+	return s
+}
+
+// SplitGloss splits a gloss listing synonyms, e.g. "to eat; to consume"
+func SplitGloss(gloss string) []string {
+	var meanings []string
+	for _, m := range strings.FieldsFunc(gloss, func(r rune) bool { return r == ';' || r == ',' || r == '/' }) {
+		if m = strings.TrimSpace(m); m != "" {
+			meanings = append(meanings, m)
+		}
+	}
+	return meanings
+}
@@ -0,0 +1,176 @@
+package launch
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid session token")
+	ErrExpiredToken = errors.New("session token has expired")
+)
+
+// Params are handed to an activity when it is launched
+type Params struct {
+	SessionID int
+	// GroupID is 0 for sessions that are not over a single group
+	GroupID    int
+	APIBaseURL string
+	Token      string
+}
+
+// Service issues the short-lived tokens that let a launched activity post
+// back to the one study session it was launched for. Tokens are
+// "<session id>.<expiry>.<signature>" with an HMAC-SHA256 signature.
+type Service struct {
+	secret []byte
+	TTL    time.Duration
+	// APIBaseURL is handed to activities, derived from the request if empty
+	APIBaseURL string
+	// TrustedProxies may set X-Forwarded-Proto when the API base URL is
+	// derived from the request. No proxy is trusted by default.
+	TrustedProxies []netip.Prefix
+}
+
+// NewService signs tokens with secret. A random secret is generated when
+// it is empty, which invalidates outstanding tokens on restart.
+func NewService(secret []byte, ttl time.Duration, apiBaseURL string) (*Service, error) {
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+	}
+	return &Service{secret: secret, TTL: ttl, APIBaseURL: strings.TrimSuffix(apiBaseURL, "/")}, nil
+}
+
+// ParseProxies parses a comma-separated list of proxy addresses and CIDR
+// ranges
+func ParseProxies(list string) ([]netip.Prefix, error) {
+	var proxies []netip.Prefix
+	for _, field := range strings.Split(list, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		if !strings.Contains(field, "/") {
+			addr, err := netip.ParseAddr(field)
+			if err != nil {
+				return nil, err
+			}
+			proxies = append(proxies, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(field)
+		if err != nil {
+			return nil, err
+		}
+		proxies = append(proxies, prefix)
+	}
+	return proxies, nil
+}
+
+// TrustsProxy reports whether a request from ip came through a trusted proxy
+func (s *Service) TrustsProxy(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range s.TrustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Service) sign(payload string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Token issues a token for a study session
+func (s *Service) Token(sessionID int) (string, time.Time) {
+	expires := time.Now().Add(s.TTL).Truncate(time.Second)
+	payload := fmt.Sprintf("%d.%d", sessionID, expires.Unix())
+	return payload + "." + s.sign(payload), expires
+}
+
+// Verify checks a token and returns the study session it was issued for
+func (s *Service) Verify(token string) (int, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return 0, ErrInvalidToken
+	}
+	payload := parts[0] + "." + parts[1]
+	if !hmac.Equal([]byte(s.sign(payload)), []byte(parts[2])) {
+		return 0, ErrInvalidToken
+	}
+
+	sessionID, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, ErrInvalidToken
+	}
+	expires, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return 0, ErrInvalidToken
+	}
+	if time.Now().Unix() > expires {
+		return 0, ErrExpiredToken
+	}
+	return sessionID, nil
+}
+
+// URL fills in an activity's launch URL. The {session_id}, {group_id},
+// {api_base_url} and {token} placeholders are replaced where present, and
+// the remaining parameters are added to the query string. Sessions without
+// a group leave {group_id} empty and have no group_id parameter.
+func URL(launchURL string, p Params) (string, error) {
+	groupID := ""
+	if p.GroupID != 0 {
+		groupID = strconv.Itoa(p.GroupID)
+	}
+	values := []struct {
+		name  string
+		value string
+	}{
+		{"session_id", strconv.Itoa(p.SessionID)},
+		{"group_id", groupID},
+		{"api_base_url", p.APIBaseURL},
+		{"token", p.Token},
+	}
+
+	var missing []int
+	for i, v := range values {
+		placeholder := "{" + v.name + "}"
+		if strings.Contains(launchURL, placeholder) {
+			launchURL = strings.ReplaceAll(launchURL, placeholder, url.QueryEscape(v.value))
+		} else {
+			missing = append(missing, i)
+		}
+	}
+
+	u, err := url.Parse(launchURL)
+	if err != nil {
+		return "", err
+	}
+	query := u.Query()
+	for _, i := range missing {
+		if values[i].value != "" {
+			query.Set(values[i].name, values[i].value)
+		}
+	}
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
@@ -8,9 +8,10 @@ import (
 	"os"
 	"path/filepath"
 
-	_ "github.com/mattn/go-sqlite3"
 	"github.com/magefile/mage/mg"
 	"github.com/magefile/mage/sh"
+	_ "github.com/mattn/go-sqlite3"
+	"lang-portal/backend/db"
 )
 
 type DB mg.Namespace
@@ -18,7 +19,7 @@ type DB mg.Namespace
 // Migrate runs all database migrations
 func (DB) Migrate() error {
 	fmt.Println("Running migrations...")
-	
+
 	// Get current working directory
 	wd, err := os.Getwd()
 	if err != nil {
@@ -27,41 +28,18 @@ func (DB) Migrate() error {
 
 	// Open database connection
 	dbPath := filepath.Join(wd, "words.db")
-	db, err := sql.Open("sqlite3", dbPath)
+	conn, err := sql.Open("sqlite3", dbPath)
 	if err != nil {
 		return err
 	}
-	defer db.Close()
+	defer conn.Close()
 
-	// Read migration files
+	// Execute pending migration files in order
 	migrationsDir := filepath.Join(wd, "db", "migrations")
-	files, err := os.ReadDir(migrationsDir)
-	if err != nil {
+	if err := db.Migrate(conn, migrationsDir); err != nil {
 		return err
 	}
 
-	// Execute each migration file in order
-	for _, file := range files {
-		if filepath.Ext(file.Name()) != ".sql" {
-			continue
-		}
-
-		fmt.Printf("Executing migration: %s\n", file.Name())
-		
-		// Read migration file
-		migrationPath := filepath.Join(migrationsDir, file.Name())
-		migration, err := os.ReadFile(migrationPath)
-		if err != nil {
-			return err
-		}
-
-		// Execute migration
-		_, err = db.Exec(string(migration))
-		if err != nil {
-			return fmt.Errorf("error executing migration %s: %v", file.Name(), err)
-		}
-	}
-
 	fmt.Println("Migrations completed successfully")
 	return nil
 }
@@ -76,7 +54,7 @@ func (DB) Seed() error {
 // Reset removes the database file
 func (DB) Reset() error {
 	fmt.Println("Resetting database...")
-	
+
 	wd, err := os.Getwd()
 	if err != nil {
 		return err
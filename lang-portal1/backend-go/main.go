@@ -1,10 +1,19 @@
 package main
 
 import (
+	"database/sql"
 	"log"
+	"os"
+	"strconv"
+	"time"
 
 	"lang-portal/backend/api"
 	"lang-portal/backend/db"
+	"lang-portal/backend/launch"
+	"lang-portal/backend/media"
+	"lang-portal/backend/models"
+	"lang-portal/backend/srs"
+	"lang-portal/backend/tts"
 
 	"github.com/gin-gonic/gin"
 )
@@ -16,14 +25,91 @@ func main() {
 	}
 	defer db.Close()
 
-	// Initialize Gin router
+	// Store the readings of words added before readings were stored
+	if filled, err := models.FillWordReadings(db.DB); err != nil {
+		log.Fatal("Failed to store word readings:", err)
+	} else if filled > 0 {
+		log.Printf("Stored the readings of %d words\n", filled)
+	}
+
+	// Initialize media store
+	store, err := media.NewStore(getEnv("MEDIA_DIR", "./uploads"), getEnvInt64("MEDIA_MAX_BYTES", 10<<20))
+	if err != nil {
+		log.Fatal("Failed to initialize media store:", err)
+	}
+
+	// Initialize text-to-speech
+	var speech *tts.Service
+	switch provider := getEnv("TTS_PROVIDER", ""); provider {
+	case "http":
+		timeout := time.Duration(getEnvInt64("TTS_TIMEOUT_SECONDS", 30)) * time.Second
+		speech = tts.NewService(tts.NewHTTPProvider(getEnv("TTS_URL", "http://localhost:9088/v1/audio/speech"), timeout), getEnv("TTS_VOICE", "default"))
+	case "fake":
+		speech = tts.NewService(tts.FakeProvider{}, getEnv("TTS_VOICE", "fake"))
+	case "":
+		log.Println("Text-to-speech disabled, set TTS_PROVIDER to enable it")
+	default:
+		log.Fatal("Unknown TTS_PROVIDER: ", provider)
+	}
+
+	// Initialize activity launch tokens
+	secret := getEnv("LAUNCH_TOKEN_SECRET", "")
+	if secret == "" {
+		log.Println("LAUNCH_TOKEN_SECRET not set, launch tokens will not survive a restart")
+	}
+	ttl := time.Duration(getEnvInt64("LAUNCH_TOKEN_TTL_MINUTES", 120)) * time.Minute
+	launcher, err := launch.NewService([]byte(secret), ttl, getEnv("API_BASE_URL", ""))
+	if err != nil {
+		log.Fatal("Failed to initialize launch tokens:", err)
+	}
+	if launcher.TrustedProxies, err = launch.ParseProxies(getEnv("TRUSTED_PROXIES", "")); err != nil {
+		log.Fatal("Invalid TRUSTED_PROXIES:", err)
+	}
+
+	// Initialize review scheduling
+	algorithm := getEnv("SRS_ALGORITHM", srs.Default)
+	scheduler, ok := srs.Get(algorithm)
+	if !ok {
+		log.Fatalf("Unknown SRS_ALGORITHM %q, use one of %v", algorithm, srs.Names())
+	}
+	models.SetScheduler(scheduler)
+	if rescheduled, err := models.RescheduleStaleWords(db.DB); err != nil {
+		log.Fatal("Failed to schedule reviews:", err)
+	} else if rescheduled > 0 {
+		log.Printf("Rescheduled %d words with %s\n", rescheduled, scheduler.Name())
+	}
+
+	// Initialize leech detection
+	models.SetLeechPolicy(models.LeechPolicy{
+		ConsecutiveFailures: int(getEnvInt64("LEECH_CONSECUTIVE_FAILURES", int64(models.DefaultLeechPolicy.ConsecutiveFailures))),
+		TotalFailures:       int(getEnvInt64("LEECH_TOTAL_FAILURES", int64(models.DefaultLeechPolicy.TotalFailures))),
+		AutoSuspend:         getEnv("LEECH_AUTO_SUSPEND", "false") == "true",
+	})
+
+	// Close study sessions left idle, unless SESSION_IDLE_MINUTES is 0
+	if idle := time.Duration(getEnvInt64("SESSION_IDLE_MINUTES", 30)) * time.Minute; idle > 0 {
+		go sweepIdleSessions(db.DB, idle, time.Minute)
+	} else {
+		log.Println("Idle study sessions will not be closed, SESSION_IDLE_MINUTES is 0")
+	}
+
+	// Initialize Gin router. Forwarded headers are only read from the
+	// proxies listed in TRUSTED_PROXIES.
 	r := gin.Default()
+	trusted := make([]string, len(launcher.TrustedProxies))
+	for i, prefix := range launcher.TrustedProxies {
+		trusted[i] = prefix.String()
+	}
+	if err := r.SetTrustedProxies(trusted); err != nil {
+		log.Fatal("Failed to set trusted proxies:", err)
+	}
 
 	// Setup CORS middleware
 	r.Use(func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Range")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "Content-Length, Content-Range, Accept-Ranges, ETag, Content-Disposition")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -37,7 +123,7 @@ func main() {
 	r.StaticFile("/test", "./test.html")
 
 	// Setup API routes
-	api.SetupRoutes(r, db.DB)
+	api.SetupRoutes(r, db.DB, store, speech, launcher)
 
 	// Start server
 	log.Println("Server starting on http://localhost:8080")
@@ -45,3 +131,37 @@ func main() {
 		log.Fatal("Failed to start server:", err)
 	}
 }
+
+// getEnv returns the value of an environment variable or a fallback
+func getEnv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// getEnvInt64 returns the integer value of an environment variable or a fallback
+func getEnvInt64(key string, fallback int64) int64 {
+	value, err := strconv.ParseInt(os.Getenv(key), 10, 64)
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// sweepIdleSessions closes sessions idle for longer than idle, checking
+// every interval
+func sweepIdleSessions(conn *sql.DB, idle, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		closed, err := models.CloseIdleSessions(conn, idle)
+		if err != nil {
+			log.Println("Failed to close idle study sessions:", err)
+			continue
+		}
+		if closed > 0 {
+			log.Printf("Closed %d idle study sessions\n", closed)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package media
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrTooLarge        = errors.New("media file is too large")
+	ErrUnsupportedType = errors.New("unsupported media type")
+	ErrInvalidHash     = errors.New("invalid media hash")
+)
+
+var hashPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// Blob describes a file held in the store
+type Blob struct {
+	Hash     string
+	Size     int64
+	MimeType string
+}
+
+// Hint is what the uploader says about a file, used when its content does
+// not tell its type
+type Hint struct {
+	Filename string
+	MimeType string
+}
+
+// declaredTypes lists the MIME types accepted from a Hint, by the name they
+// are stored under
+var declaredTypes = map[string]string{
+	"audio/mpeg":   "audio/mpeg",
+	"audio/mp3":    "audio/mpeg",
+	"audio/mp4":    "audio/mp4",
+	"audio/x-m4a":  "audio/mp4",
+	"audio/m4a":    "audio/mp4",
+	"audio/aac":    "audio/aac",
+	"audio/x-aac":  "audio/aac",
+	"audio/ogg":    "audio/ogg",
+	"audio/opus":   "audio/ogg",
+	"audio/wav":    "audio/wave",
+	"audio/wave":   "audio/wave",
+	"audio/x-wav":  "audio/wave",
+	"audio/webm":   "audio/webm",
+	"audio/flac":   "audio/flac",
+	"audio/x-flac": "audio/flac",
+}
+
+// extensionTypes maps file extensions to the types of declaredTypes
+var extensionTypes = map[string]string{
+	".mp3":  "audio/mpeg",
+	".m4a":  "audio/mp4",
+	".aac":  "audio/aac",
+	".ogg":  "audio/ogg",
+	".oga":  "audio/ogg",
+	".opus": "audio/ogg",
+	".wav":  "audio/wave",
+	".weba": "audio/webm",
+	".flac": "audio/flac",
+}
+
+// ambiguousTypes are sniffed types that do not rule out a declared audio
+// type: MP3 without an ID3 tag and raw AAC look like any binary, and M4A
+// and WebM audio share their containers with video.
+var ambiguousTypes = map[string]bool{
+	"application/octet-stream": true,
+	"video/mp4":                true,
+	"video/webm":               true,
+}
+
+// Store keeps media files on local disk, addressed by the SHA-256 of their
+// content so identical uploads share a single file.
+type Store struct {
+	Root    string
+	MaxSize int64
+
+	// mu serializes adding references to blobs with removing unreferenced
+	// ones, so a blob is never removed between Put finding it and the
+	// caller referencing it
+	mu sync.Mutex
+}
+
+// NewStore creates the store directory if needed
+func NewStore(root string, maxSize int64) (*Store, error) {
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, err
+	}
+	return &Store{Root: root, MaxSize: maxSize}, nil
+}
+
+// Put writes the content of r to the store and calls attach with the blob
+// to reference it. The MIME type is sniffed from the first bytes, or taken
+// from the hint when sniffing cannot tell; only images and audio are
+// accepted. If attach fails, a blob written by this call is removed again
+// and the error of attach is returned.
+func (s *Store) Put(r io.Reader, hint Hint, attach func(*Blob) error) (*Blob, error) {
+	tmp, err := os.CreateTemp(s.Root, "upload-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash), io.LimitReader(r, s.MaxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if size > s.MaxSize {
+		return nil, ErrTooLarge
+	}
+
+	// Sniff the content type from the start of the file
+	head := make([]byte, 512)
+	n, err := tmp.ReadAt(head, 0)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	mimeType := detectType(head[:n], hint)
+	if mimeType == "" {
+		return nil, ErrUnsupportedType
+	}
+
+	blob := &Blob{
+		Hash:     hex.EncodeToString(hash.Sum(nil)),
+		Size:     size,
+		MimeType: mimeType,
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := s.path(blob.Hash)
+	created := false
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return nil, err
+		}
+		if err := tmp.Close(); err != nil {
+			return nil, err
+		}
+		if err := os.Rename(tmp.Name(), path); err != nil {
+			return nil, err
+		}
+		created = true
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := attach(blob); err != nil {
+		if created {
+			os.Remove(path)
+		}
+		return nil, err
+	}
+	return blob, nil
+}
+
+// detectType returns the MIME type of a file starting with head, or "" if
+// it is not a supported type
+func detectType(head []byte, hint Hint) string {
+	sniffed := baseType(http.DetectContentType(head))
+	if Kind(sniffed) != "" {
+		return sniffed
+	}
+	if !ambiguousTypes[sniffed] {
+		return ""
+	}
+	if mimeType, ok := declaredTypes[baseType(hint.MimeType)]; ok {
+		return mimeType
+	}
+	return extensionTypes[strings.ToLower(filepath.Ext(hint.Filename))]
+}
+
+// baseType strips the parameters from a MIME type and lowercases it
+func baseType(mimeType string) string {
+	if i := strings.Index(mimeType, ";"); i >= 0 {
+		mimeType = mimeType[:i]
+	}
+	return strings.ToLower(strings.TrimSpace(mimeType))
+}
+
+// Open opens the file for a hash
+func (s *Store) Open(hash string) (*os.File, error) {
+	if !hashPattern.MatchString(hash) {
+		return nil, ErrInvalidHash
+	}
+	return os.Open(s.path(hash))
+}
+
+// RemoveOrphans deletes the blobs among hashes that orphaned reports as no
+// longer referenced. Missing files are ignored.
+func (s *Store) RemoveOrphans(hashes []string, orphaned func(hashes []string) ([]string, error)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	unreferenced, err := orphaned(hashes)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, hash := range unreferenced {
+		if !hashPattern.MatchString(hash) {
+			errs = append(errs, ErrInvalidHash)
+			continue
+		}
+		if err := os.Remove(s.path(hash)); err != nil && !os.IsNotExist(err) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// path shards files by the first two hex digits of their hash
+func (s *Store) path(hash string) string {
+	return filepath.Join(s.Root, hash[:2], hash)
+}
+
+// Kind returns "image" or "audio" for supported MIME types and "" otherwise
+func Kind(mimeType string) string {
+	switch {
+	case strings.HasPrefix(mimeType, "image/"):
+		return "image"
+	case strings.HasPrefix(mimeType, "audio/"), mimeType == "application/ogg":
+		return "audio"
+	}
+	return ""
+}
@@ -7,15 +7,18 @@ import (
 )
 
 type LastStudySession struct {
-	ID              int       `json:"id"`
-	GroupID         int       `json:"group_id"`
-	GroupName       string    `json:"group_name"`
-	StudyActivityID int       `json:"study_activity_id"`
-	ActivityName    string    `json:"activity_name"`
-	CreatedAt       time.Time `json:"created_at"`
+	ID int `json:"id"`
+	// GroupID is only set for single-group sessions; Scope tells what the
+	// others were started on
+	GroupID         *int       `json:"group_id"`
+	GroupName       string     `json:"group_name"`
+	Scope           string     `json:"scope"`
+	StudyActivityID int        `json:"study_activity_id"`
+	ActivityName    string     `json:"activity_name"`
+	CreatedAt       time.Time  `json:"created_at"`
 	CompletedAt     *time.Time `json:"completed_at,omitempty"`
-	CorrectCount    int       `json:"correct_count"`
-	TotalCount      int       `json:"total_count"`
+	CorrectCount    int        `json:"correct_count"`
+	TotalCount      int        `json:"total_count"`
 }
 
 type StudyProgress struct {
@@ -25,30 +28,36 @@ type StudyProgress struct {
 }
 
 type QuickStats struct {
-	TotalWords      int     `json:"total_words"`
-	TotalGroups     int     `json:"total_groups"`
-	TotalSessions   int     `json:"total_sessions"`
-	CorrectRate     float64 `json:"correct_rate"`
-	StudiedWords    int     `json:"studied_words"`
-	UnstudiedWords  int     `json:"unstudied_words"`
+	TotalWords     int     `json:"total_words"`
+	TotalGroups    int     `json:"total_groups"`
+	TotalSessions  int     `json:"total_sessions"`
+	CorrectRate    float64 `json:"correct_rate"`
+	StudiedWords   int     `json:"studied_words"`
+	UnstudiedWords int     `json:"unstudied_words"`
+	// AvgResponseTimeMs averages the reviews that recorded a response time
+	AvgResponseTimeMs *float64 `json:"avg_response_time_ms"`
+	// LeechCount are the words failed often enough to be leeches
+	LeechCount int `json:"leech_count"`
 }
 
 // GetLastStudySession retrieves the most recent study session with stats
-func GetLastStudySession(db *sql.DB) (*LastStudySession, error) {
+func GetLastStudySession(db *sql.DB, attempts Attempts) (*LastStudySession, error) {
 	var session LastStudySession
+	var completedAt sqliteTime
 	query := `
 		SELECT 
 			ss.id,
 			ss.group_id,
-			g.name as group_name,
+			` + sessionGroupNames + ` as group_name,
+			ss.scope,
 			ss.study_activity_id,
 			ss.created_at,
+			ss.completed_at,
 			COUNT(CASE WHEN wri.correct THEN 1 END) as correct_count,
 			COUNT(wri.word_id) as total_count
 		FROM study_sessions ss
-		JOIN groups g ON ss.group_id = g.id
 		JOIN study_activities sa ON ss.study_activity_id = sa.id
-		LEFT JOIN word_review_items wri ON ss.id = wri.study_session_id
+		LEFT JOIN ` + attempts.reviews() + ` wri ON ss.id = wri.study_session_id
 		GROUP BY ss.id
 		ORDER BY ss.created_at DESC
 		LIMIT 1
@@ -58,8 +67,10 @@ func GetLastStudySession(db *sql.DB) (*LastStudySession, error) {
 		&session.ID,
 		&session.GroupID,
 		&session.GroupName,
+		&session.Scope,
 		&session.StudyActivityID,
 		&session.CreatedAt,
+		&completedAt,
 		&session.CorrectCount,
 		&session.TotalCount,
 	)
@@ -70,11 +81,12 @@ func GetLastStudySession(db *sql.DB) (*LastStudySession, error) {
 		log.Printf("Error retrieving last study session: %v", err) // Log the error
 		return nil, err
 	}
+	session.CompletedAt = completedAt.Ptr()
 	return &session, nil
 }
 
 // GetStudyProgress retrieves study progress for the last 7 days
-func GetStudyProgress(db *sql.DB) ([]StudyProgress, error) {
+func GetStudyProgress(db *sql.DB, attempts Attempts) ([]StudyProgress, error) {
 	rows, err := db.Query(`
 		WITH RECURSIVE dates(date) AS (
 			SELECT date('now', '-6 days')
@@ -89,7 +101,7 @@ func GetStudyProgress(db *sql.DB) ([]StudyProgress, error) {
 			COUNT(wri.word_id) as total_count
 		FROM dates
 		LEFT JOIN study_sessions ss ON date(ss.created_at) = dates.date
-		LEFT JOIN word_review_items wri ON ss.id = wri.study_session_id
+		LEFT JOIN ` + attempts.reviews() + ` wri ON ss.id = wri.study_session_id
 		GROUP BY dates.date
 		ORDER BY dates.date
 	`)
@@ -110,9 +122,10 @@ func GetStudyProgress(db *sql.DB) ([]StudyProgress, error) {
 }
 
 // GetQuickStats retrieves quick statistics about words and study sessions
-func GetQuickStats(db *sql.DB) (*QuickStats, error) {
+func GetQuickStats(db *sql.DB, attempts Attempts) (*QuickStats, error) {
 	var stats QuickStats
-	
+	var avgResponseTime sql.NullFloat64
+
 	// Get total words and groups
 	err := db.QueryRow(`
 		SELECT 
@@ -129,15 +142,24 @@ func GetQuickStats(db *sql.DB) (*QuickStats, error) {
 		WITH word_stats AS (
 			SELECT 
 				COUNT(DISTINCT word_id) as studied_words,
-				SUM(CASE WHEN correct THEN 1 ELSE 0 END) * 1.0 / COUNT(*) as correct_rate
-			FROM word_review_items
+				SUM(CASE WHEN correct THEN 1 ELSE 0 END) * 1.0 / COUNT(*) as correct_rate,
+				AVG(response_time_ms) as avg_response_time_ms
+			FROM `+attempts.reviews()+`
 		)
 		SELECT 
 			COALESCE(correct_rate, 0),
 			COALESCE(studied_words, 0),
-			(SELECT COUNT(*) FROM words) - COALESCE(studied_words, 0)
+			(SELECT COUNT(*) FROM words) - COALESCE(studied_words, 0),
+			avg_response_time_ms
 		FROM word_stats
-	`).Scan(&stats.CorrectRate, &stats.StudiedWords, &stats.UnstudiedWords)
+	`).Scan(&stats.CorrectRate, &stats.StudiedWords, &stats.UnstudiedWords, &avgResponseTime)
+	if err != nil {
+		return nil, err
+	}
+	stats.AvgResponseTimeMs = nullableFloat(avgResponseTime)
+
+	cond, args := leechPolicy.condition()
+	err = db.QueryRow("SELECT COUNT(*) FROM word_srs_state s WHERE "+cond, args...).Scan(&stats.LeechCount)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,245 @@
+package models
+
+import (
+	"strings"
+
+	"lang-portal/backend/language"
+)
+
+// Grade results
+const (
+	GradeCorrect   = "correct"
+	GradePartial   = "partial"
+	GradeIncorrect = "incorrect"
+)
+
+// What an answer is graded against
+const (
+	GradeAny     = "any"
+	GradeMeaning = "meaning"
+	GradeReading = "reading"
+	GradeTerm    = "term"
+)
+
+// Why a partial answer was not fully correct
+const (
+	PartialVowelLength = "vowel_length"
+	PartialMarks       = "marks"
+	PartialTypo        = "typo"
+)
+
+// Diff operations, from the point of view of the answer
+const (
+	DiffEqual   = "equal"
+	DiffMissing = "missing"
+	DiffExtra   = "extra"
+)
+
+type DiffOp struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+// Grade is the outcome of comparing an answer with a word
+type Grade struct {
+	Result string `json:"result"`
+	// Reason explains partial results
+	Reason string `json:"reason,omitempty"`
+	// Field and Expected are the closest accepted answer, as written on the
+	// word
+	Field              string   `json:"field"`
+	Expected           string   `json:"expected"`
+	NormalizedAnswer   string   `json:"normalized_answer"`
+	NormalizedExpected string   `json:"normalized_expected"`
+	Diff               []DiffOp `json:"diff"`
+}
+
+type gradeCandidate struct {
+	field string
+	text  string
+}
+
+// gradeCandidates lists the accepted answers of a word. Each gloss may list
+// several synonyms, and Japanese terms may be answered in kana.
+func gradeCandidates(w Word, against string) []gradeCandidate {
+	var candidates []gradeCandidate
+	add := func(field, text string) {
+		if text != "" {
+			candidates = append(candidates, gradeCandidate{field, text})
+		}
+	}
+
+	if against == GradeAny || against == GradeMeaning {
+		for _, gloss := range w.Glosses {
+			for _, meaning := range language.SplitGloss(gloss) {
+				add(GradeMeaning, meaning)
+			}
+		}
+	}
+	if against == GradeAny || against == GradeReading || against == GradeTerm {
+		add(GradeReading, w.Reading())
+		add(GradeReading, w.Romanization)
+	}
+	if against == GradeAny || against == GradeTerm {
+		add(GradeTerm, w.Term)
+	}
+	return candidates
+}
+
+// normalizeAnswer canonicalizes text for comparison as a field of a word,
+// along with a looser form that tells partial answers
+func normalizeAnswer(w Word, field, text string) (string, string, string) {
+	switch {
+	case field == GradeMeaning:
+		normalized := language.NormalizeGloss(text)
+		return normalized, normalized, ""
+	case w.Language == "ja":
+		normalized := language.NormalizeKana(text)
+		return normalized, language.CollapseLongVowels(normalized), PartialVowelLength
+	case field == GradeReading:
+		normalized := language.NormalizeLatin(text)
+		return normalized, language.StripMarks(normalized), PartialMarks
+	default:
+		normalized := strings.Join(strings.Fields(strings.ToLower(language.FoldWidth(text))), "")
+		return normalized, normalized, ""
+	}
+}
+
+// typoAllowance is the edit distance tolerated as a typo for an answer of n
+// characters
+func typoAllowance(n int) int {
+	switch {
+	case n >= 8:
+		return 2
+	case n >= 4:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// GradeAnswer compares an answer with the meaning, reading or term of a
+// word, or with any of them. Answers matching after normalization are
+// correct; answers off by vowel length, diacritics or a typo are partial.
+func GradeAnswer(w Word, answer, against string) (*Grade, error) {
+	if against == "" {
+		against = GradeAny
+	}
+	switch against {
+	case GradeAny, GradeMeaning, GradeReading, GradeTerm:
+	default:
+		return nil, &ValidationError{Field: "expect", Message: "must be any, meaning, reading or term"}
+	}
+	if len(answer) > maxAnswerLength {
+		return nil, &ValidationError{Field: "answer", Message: "is too long"}
+	}
+	candidates := gradeCandidates(w, against)
+	if len(candidates) == 0 {
+		return nil, &ValidationError{Field: "expect", Message: "word has no " + against + " to grade against"}
+	}
+
+	var best *Grade
+	bestRank, bestDistance := 0, 0
+	for _, c := range candidates {
+		normalized, loose, looseReason := normalizeAnswer(w, c.field, answer)
+		expected, looseExpected, _ := normalizeAnswer(w, c.field, c.text)
+		grade := &Grade{
+			Field:              c.field,
+			Expected:           c.text,
+			NormalizedAnswer:   normalized,
+			NormalizedExpected: expected,
+		}
+
+		distance := editDistance(normalized, expected)
+		rank := 2
+		switch {
+		case expected == "":
+			continue
+		case normalized == expected:
+			rank, grade.Result = 0, GradeCorrect
+		case loose == looseExpected:
+			rank, grade.Result, grade.Reason = 1, GradePartial, looseReason
+		case distance <= typoAllowance(len([]rune(expected))):
+			rank, grade.Result, grade.Reason = 1, GradePartial, PartialTypo
+		default:
+			grade.Result = GradeIncorrect
+		}
+
+		if best == nil || rank < bestRank || (rank == bestRank && distance < bestDistance) {
+			best, bestRank, bestDistance = grade, rank, distance
+		}
+	}
+	if best == nil {
+		return nil, &ValidationError{Field: "expect", Message: "word has no " + against + " to grade against"}
+	}
+
+	best.Diff = diffAnswer(best.NormalizedAnswer, best.NormalizedExpected)
+	return best, nil
+}
+
+// editDistance is the Levenshtein distance between a and b in characters
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+// diffAnswer is a character-level diff turning the answer into the
+// expected text, built from their longest common subsequence
+func diffAnswer(answer, expected string) []DiffOp {
+	a, b := []rune(answer), []rune(expected)
+	// lcs[i][j] is the LCS length of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	ops := []DiffOp{}
+	emit := func(op string, r rune) {
+		if n := len(ops); n > 0 && ops[n-1].Op == op {
+			ops[n-1].Text += string(r)
+			return
+		}
+		ops = append(ops, DiffOp{Op: op, Text: string(r)})
+	}
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			emit(DiffEqual, a[i])
+			i++
+			j++
+		case j < len(b) && (i == len(a) || lcs[i][j+1] >= lcs[i+1][j]):
+			emit(DiffMissing, b[j])
+			j++
+		default:
+			emit(DiffExtra, a[i])
+			i++
+		}
+	}
+	return ops
+}
@@ -2,71 +2,265 @@ package models
 
 import (
 	"database/sql"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
 )
 
 type Group struct {
-	ID          int    `json:"id"`
-	Name        string `json:"name"`
-	Description string `json:"description"`
+	ID           int       `json:"id"`
+	ParentID     *int      `json:"parent_id"`
+	Name         string    `json:"name"`
+	Description  string    `json:"description"`
+	Color        string    `json:"color,omitempty"`
+	Icon         string    `json:"icon,omitempty"`
+	SortPosition int       `json:"sort_position"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+
+	// Kind is manual or smart; smart groups select their words with Filter
+	Kind   string       `json:"kind"`
+	Filter *SmartFilter `json:"filter,omitempty"`
 }
 
+// GroupWithStats is a group with stats rolled up over its whole subtree
 type GroupWithStats struct {
 	Group
-	WordCount        int     `json:"word_count"`
+	WordCount         int     `json:"word_count"`
 	StudySessionCount int     `json:"study_session_count"`
-	SuccessRate      float64 `json:"success_rate"`
+	SuccessRate       float64 `json:"success_rate"`
+	ChildCount        int     `json:"child_count"`
+	Words             []Word  `json:"words,omitempty"`
+}
+
+// GroupSummary is a group as shown in the group list
+type GroupSummary struct {
+	Group
+	WordCount         int        `json:"word_count"`
+	StudySessionCount int        `json:"study_session_count"`
+	SuccessRate       float64    `json:"success_rate"`
+	LastStudiedAt     *time.Time `json:"last_studied_at"`
+}
+
+var (
+	ErrInvalidSort  = errors.New("invalid sort field")
+	ErrInvalidColor = errors.New("color must be a hex code like #1e90ff")
+)
+
+// groupSortColumns maps the sort fields accepted by GetGroups to the
+// columns of its query
+var groupSortColumns = map[string]string{
+	"name":                "g.name COLLATE NOCASE",
+	"sort_position":       "g.sort_position",
+	"created_at":          "g.created_at",
+	"updated_at":          "g.updated_at",
+	"word_count":          "word_count",
+	"study_session_count": "study_session_count",
+	"success_rate":        "success_rate",
+	"last_studied_at":     "last_studied_at",
+}
+
+var hexColor = regexp.MustCompile(`^#(?:[0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// GroupFilter narrows down and orders group lists
+type GroupFilter struct {
+	// Language only keeps groups that contain words in that language
+	Language string
+
+	// Sort is one of the groupSortColumns keys, sort_position by default
+	Sort string
+	Desc bool
+
+	// Attempts selects the reviews counted in the success rate
+	Attempts Attempts
+}
+
+// conditions builds the WHERE clause for groups aliased as g
+func (f GroupFilter) conditions() (string, []interface{}) {
+	if f.Language == "" {
+		return "1 = 1", nil
+	}
+	return groupLanguageCondition("g.id"), []interface{}{f.Language}
+}
+
+// orderBy builds the ORDER BY clause for GetGroups
+func (f GroupFilter) orderBy() (string, error) {
+	field := f.Sort
+	if field == "" {
+		field = "sort_position"
+	}
+	column, ok := groupSortColumns[field]
+	if !ok {
+		return "", ErrInvalidSort
+	}
+	if f.Desc {
+		return column + " DESC, g.id DESC", nil
+	}
+	return column + ", g.id", nil
 }
 
-// GetGroups retrieves a paginated list of groups
-func GetGroups(db *sql.DB, page, perPage int) ([]Group, int, error) {
+// groupLanguageCondition matches groups holding at least one word in the
+// language bound to the placeholder. Smart groups match when their filter
+// has that language or none at all.
+func groupLanguageCondition(groupIDColumn string) string {
+	return `EXISTS (
+			SELECT 1 FROM (SELECT ? AS language) lp
+			WHERE EXISTS (
+				SELECT 1 FROM words_groups lwg
+				JOIN words lw ON lw.id = lwg.word_id
+				WHERE lwg.group_id = ` + groupIDColumn + ` AND lw.language = lp.language
+			) OR EXISTS (
+				SELECT 1 FROM groups lg
+				WHERE lg.id = ` + groupIDColumn + ` AND lg.kind = 'smart'
+					AND COALESCE(json_extract(lg.filter, '$.language'), lp.language) = lp.language
+			)
+		)`
+}
+
+// groupColumns lists the columns scanned by scanGroup, for queries that
+// alias groups as g
+const groupColumns = "g.id, g.parent_id, g.name, g.description, g.color, g.icon, g.sort_position, g.created_at, g.updated_at, g.kind, g.filter"
+
+// scanGroup scans a group selected with groupColumns followed by extra
+func scanGroup(row rowScanner, g *Group, extra ...interface{}) error {
+	var parentID sql.NullInt64
+	var color, icon, filter sql.NullString
+	var createdAt, updatedAt sqliteTime
+	dest := []interface{}{&g.ID, &parentID, &g.Name, &g.Description, &color, &icon, &g.SortPosition, &createdAt, &updatedAt, &g.Kind, &filter}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return err
+	}
+	g.ParentID = nil
+	if parentID.Valid {
+		id := int(parentID.Int64)
+		g.ParentID = &id
+	}
+	g.Color, g.Icon = color.String, icon.String
+	g.CreatedAt, g.UpdatedAt = createdAt.Time, updatedAt.Time
+	g.Filter = nil
+	if filter.Valid {
+		g.Filter = &SmartFilter{}
+		if err := json.Unmarshal([]byte(filter.String), g.Filter); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetGroups retrieves a paginated list of groups with their word counts
+// and study stats
+func GetGroups(db *sql.DB, filter GroupFilter, page, perPage int) ([]GroupSummary, int, error) {
 	offset := (page - 1) * perPage
+	where, args := filter.conditions()
+	orderBy, err := filter.orderBy()
+	if err != nil {
+		return nil, 0, err
+	}
 
 	// Get total count
 	var total int
-	err := db.QueryRow("SELECT COUNT(*) FROM groups").Scan(&total)
+	err = db.QueryRow("SELECT COUNT(*) FROM groups g WHERE "+where, args...).Scan(&total)
 	if err != nil {
 		return nil, 0, err
 	}
 
-	// Get paginated groups
+	// Smart groups have no words_groups rows to count, so their filters are
+	// counted up front for sorting by word_count
+	smartCounts, smartArgs, err := smartGroupCounts(db)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Get paginated groups, aggregating each table once rather than per group
 	rows, err := db.Query(`
-		SELECT id, name 
-		FROM groups 
+		SELECT `+groupColumns+`,
+			COALESCE(wc.word_count, sc.word_count, 0) AS word_count,
+			COALESCE(ss.study_session_count, 0) AS study_session_count,
+			COALESCE(rv.success_rate, 0) AS success_rate,
+			ss.last_studied_at AS last_studied_at
+		FROM groups g
+		LEFT JOIN (
+			SELECT group_id, COUNT(*) AS word_count
+			FROM words_groups
+			GROUP BY group_id
+		) wc ON wc.group_id = g.id
+		LEFT JOIN (`+smartCounts+`) sc ON sc.group_id = g.id
+		LEFT JOIN (
+			SELECT sg.group_id, COUNT(*) AS study_session_count, MAX(s.created_at) AS last_studied_at
+			FROM study_session_groups sg
+			JOIN study_sessions s ON s.id = sg.study_session_id
+			GROUP BY sg.group_id
+		) ss ON ss.group_id = g.id
+		LEFT JOIN (
+			SELECT gr.group_id, AVG(CASE WHEN wri.correct THEN 1.0 ELSE 0.0 END) * 100 AS success_rate
+			FROM `+filter.Attempts.reviews()+` wri
+			JOIN group_review_items gr ON gr.id = wri.id
+			GROUP BY gr.group_id
+		) rv ON rv.group_id = g.id
+		WHERE `+where+`
+		ORDER BY `+orderBy+`
 		LIMIT ? OFFSET ?`,
-		perPage, offset)
+		append(append(smartArgs, args...), perPage, offset)...)
 	if err != nil {
 		return nil, 0, err
 	}
 	defer rows.Close()
 
-	var groups []Group
+	groups := []GroupSummary{}
 	for rows.Next() {
-		var g Group
-		if err := rows.Scan(&g.ID, &g.Name); err != nil {
+		var g GroupSummary
+		var lastStudied sqliteTime
+		if err := scanGroup(rows, &g.Group, &g.WordCount, &g.StudySessionCount, &g.SuccessRate, &lastStudied); err != nil {
 			return nil, 0, err
 		}
+		g.LastStudiedAt = lastStudied.Ptr()
 		groups = append(groups, g)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
 
 	return groups, total, nil
 }
 
-// GetGroup retrieves a single group with stats
-func GetGroup(db *sql.DB, id int) (*GroupWithStats, error) {
+// GetGroup retrieves a single group with stats covering the group and all
+// of its descendants
+func GetGroup(db *sql.DB, id int, attempts Attempts) (*GroupWithStats, error) {
+	subtree, err := groupSubtree(db, id)
+	if err != nil {
+		return nil, err
+	}
+	members, args, err := subtreeMembers(db, subtree)
+	if err != nil {
+		return nil, err
+	}
+	inSubtree := placeholders(len(subtree))
+	for _, groupID := range subtree {
+		args = append(args, groupID)
+	}
+	for _, groupID := range subtree {
+		args = append(args, groupID)
+	}
+
 	var g GroupWithStats
-	err := db.QueryRow(`
-		SELECT g.id, g.name,
-			COUNT(DISTINCT w.id) as word_count,
-			COUNT(DISTINCT ss.id) as study_session_count,
-			COALESCE(AVG(CASE WHEN wri.correct THEN 1.0 ELSE 0.0 END) * 100, 0) as success_rate
+	err = scanGroup(db.QueryRow(`
+		SELECT `+groupColumns+`,
+			(SELECT COUNT(*) FROM words w WHERE w.id IN (`+members+`)) as word_count,
+			(
+				SELECT COUNT(DISTINCT study_session_id) FROM study_session_groups
+				WHERE group_id IN (`+inSubtree+`)
+			) as study_session_count,
+			(
+				SELECT COALESCE(AVG(CASE WHEN wri.correct THEN 1.0 ELSE 0.0 END) * 100, 0)
+				FROM `+attempts.reviews()+` wri
+				WHERE wri.id IN (SELECT id FROM group_review_items WHERE group_id IN (`+inSubtree+`))
+			) as success_rate,
+			(SELECT COUNT(*) FROM groups c WHERE c.parent_id = g.id) as child_count
 		FROM groups g
-		LEFT JOIN words_groups wg ON g.id = wg.group_id
-		LEFT JOIN words w ON wg.word_id = w.id
-		LEFT JOIN study_sessions ss ON g.id = ss.group_id
-		LEFT JOIN word_review_items wri ON ss.id = wri.study_session_id
-		WHERE g.id = ?
-		GROUP BY g.id`,
-		id).Scan(&g.ID, &g.Name, &g.WordCount, &g.StudySessionCount, &g.SuccessRate)
+		WHERE g.id = ?`,
+		append(args, id)...), &g.Group, &g.WordCount, &g.StudySessionCount, &g.SuccessRate, &g.ChildCount)
 	if err != nil {
 		return nil, err
 	}
@@ -75,36 +269,289 @@ func GetGroup(db *sql.DB, id int) (*GroupWithStats, error) {
 }
 
 // GetGroupWords retrieves all words in a group
-func GetGroupWords(db *sql.DB, groupID int) ([]Word, error) {
+func GetGroupWords(db *sql.DB, groupID int, filter WordFilter) ([]Word, error) {
+	members, args, err := groupMembers(db, groupID)
+	if err != nil {
+		return nil, err
+	}
+	return queryGroupWords(db, members, args, filter)
+}
+
+// GetGroupTreeWords retrieves the words in a group and all of its
+// descendants, each word once
+func GetGroupTreeWords(db *sql.DB, groupID int, filter WordFilter) ([]Word, error) {
+	subtree, err := groupSubtree(db, groupID)
+	if err != nil {
+		return nil, err
+	}
+	members, args, err := subtreeMembers(db, subtree)
+	if err != nil {
+		return nil, err
+	}
+	return queryGroupWords(db, members, args, filter)
+}
+
+func queryGroupWords(db *sql.DB, members string, args []interface{}, filter WordFilter) ([]Word, error) {
+	where, filterArgs := filter.conditions()
 	rows, err := db.Query(`
-		SELECT w.id, w.japanese, w.romaji, w.english, w.parts
+		SELECT `+wordColumns+`
 		FROM words w
-		JOIN words_groups wg ON w.id = wg.word_id
-		WHERE wg.group_id = ?`,
-		groupID)
+		WHERE w.id IN (`+members+`) AND `+where+`
+		ORDER BY w.id`,
+		append(args, filterArgs...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanWords(rows)
+}
+
+// GetGroupChildren retrieves the direct children of a group
+func GetGroupChildren(db *sql.DB, id int) ([]Group, error) {
+	if _, err := LookupGroup(db, id); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(`
+		SELECT `+groupColumns+`
+		FROM groups g
+		WHERE g.parent_id = ?
+		ORDER BY g.sort_position, g.id`,
+		id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	children := []Group{}
+	for rows.Next() {
+		var g Group
+		if err := scanGroup(rows, &g); err != nil {
+			return nil, err
+		}
+		children = append(children, g)
+	}
+	return children, rows.Err()
+}
+
+// groupSubtree returns the IDs of a group and all of its descendants,
+// starting with the group itself. It returns sql.ErrNoRows for unknown
+// groups.
+func groupSubtree(db *sql.DB, id int) ([]int, error) {
+	rows, err := db.Query(`
+		WITH RECURSIVE subtree(id) AS (
+			SELECT id FROM groups WHERE id = ?
+			UNION
+			SELECT g.id FROM groups g JOIN subtree s ON g.parent_id = s.id
+		)
+		SELECT id FROM subtree`,
+		id)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var words []Word
+	var ids []int
 	for rows.Next() {
-		var w Word
-		if err := rows.Scan(&w.ID, &w.Japanese, &w.Romaji, &w.English, &w.Parts); err != nil {
+		var groupID int
+		if err := rows.Scan(&groupID); err != nil {
 			return nil, err
 		}
-		words = append(words, w)
+		ids = append(ids, groupID)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return ids, nil
+}
 
-	return words, nil
+// subtreeMembers combines the member queries of several groups
+func subtreeMembers(db *sql.DB, groupIDs []int) (string, []interface{}, error) {
+	var queries []string
+	var args []interface{}
+	for _, id := range groupIDs {
+		members, memberArgs, err := groupMembers(db, id)
+		if err != nil {
+			return "", nil, err
+		}
+		queries = append(queries, members)
+		args = append(args, memberArgs...)
+	}
+	return strings.Join(queries, " UNION "), args, nil
 }
 
-// CreateGroup creates a new group
+// placeholders returns n comma separated query placeholders
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// checkParent makes sure a group's parent exists and is not the group
+// itself or one of its descendants
+func checkParent(db *sql.DB, group *Group) error {
+	if group.ParentID == nil {
+		return nil
+	}
+	if *group.ParentID == group.ID {
+		return &ValidationError{Field: "parent_id", Message: "a group cannot be its own parent"}
+	}
+
+	var exists bool
+	err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM groups WHERE id = ?)", *group.ParentID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return &ValidationError{Field: "parent_id", Message: "parent group not found"}
+	}
+
+	if group.ID == 0 {
+		return nil
+	}
+	descendants, err := groupSubtree(db, group.ID)
+	if err != nil {
+		return err
+	}
+	for _, id := range descendants {
+		if id == *group.ParentID {
+			return &ValidationError{Field: "parent_id", Message: "a group cannot be nested inside its own descendant"}
+		}
+	}
+	return nil
+}
+
+// Normalize trims and validates a group before it is stored
+func (g *Group) Normalize() error {
+	g.Name = strings.TrimSpace(g.Name)
+	g.Description = strings.TrimSpace(g.Description)
+	g.Color = strings.ToLower(strings.TrimSpace(g.Color))
+	g.Icon = strings.TrimSpace(g.Icon)
+	if g.Name == "" {
+		return &ValidationError{Field: "name", Message: "is required"}
+	}
+
+	if g.Kind == "" {
+		g.Kind = GroupKindManual
+		if g.Filter != nil {
+			g.Kind = GroupKindSmart
+		}
+	}
+	switch g.Kind {
+	case GroupKindManual:
+		if g.Filter != nil {
+			return &ValidationError{Field: "filter", Message: "only smart groups have a filter"}
+		}
+	case GroupKindSmart:
+		if g.Filter == nil {
+			g.Filter = &SmartFilter{}
+		}
+		if err := g.Filter.validate(); err != nil {
+			return err
+		}
+	default:
+		return &ValidationError{Field: "kind", Message: "must be manual or smart"}
+	}
+	if g.Color != "" && !hexColor.MatchString(g.Color) {
+		return &ValidationError{Field: "color", Message: ErrInvalidColor.Error()}
+	}
+	if len(g.Icon) > 64 {
+		return &ValidationError{Field: "icon", Message: "must be at most 64 characters"}
+	}
+	return nil
+}
+
+// ApplyPatch merges a JSON Merge Patch into the group. Null clears the
+// description, color and icon; the name cannot be removed. The filter of a
+// smart group is merged field by field.
+func (g *Group) ApplyPatch(patch Patch) error {
+	if err := patch.checkFields("name", "description", "color", "icon", "sort_position", "kind", "filter", "parent_id"); err != nil {
+		return err
+	}
+	if ok, err := patch.decode("parent_id", &g.ParentID, true); err != nil {
+		return err
+	} else if ok && patch.isNull("parent_id") {
+		g.ParentID = nil
+	}
+	kind := g.Kind
+	if _, err := patch.decode("kind", &kind, false); err != nil {
+		return err
+	}
+	if kind != g.Kind {
+		return &ValidationError{Field: "kind", Message: "cannot be changed"}
+	}
+	if raw, ok := patch["filter"]; ok {
+		if patch.isNull("filter") {
+			g.Filter = nil
+		} else {
+			current, err := json.Marshal(g.Filter)
+			if err != nil {
+				return err
+			}
+			merged, err := mergePatch(current, raw)
+			if err != nil {
+				return &ValidationError{Field: "filter", Message: "must be an object"}
+			}
+			if g.Filter, err = ParseSmartFilter(merged); err != nil {
+				return err
+			}
+		}
+	}
+	if _, err := patch.decode("name", &g.Name, false); err != nil {
+		return err
+	}
+	if _, err := patch.decode("sort_position", &g.SortPosition, false); err != nil {
+		return err
+	}
+	for field, dest := range map[string]*string{"description": &g.Description, "color": &g.Color, "icon": &g.Icon} {
+		if ok, err := patch.decode(field, dest, true); err != nil {
+			return err
+		} else if ok && patch.isNull(field) {
+			*dest = ""
+		}
+	}
+	return g.Normalize()
+}
+
+// filterJSON encodes the filter of a smart group for storage
+func (g *Group) filterJSON() (interface{}, error) {
+	if g.Filter == nil {
+		return nil, nil
+	}
+	filter, err := json.Marshal(g.Filter)
+	if err != nil {
+		return nil, err
+	}
+	return string(filter), nil
+}
+
+// LookupGroup retrieves a single group without its stats
+func LookupGroup(db *sql.DB, id int) (*Group, error) {
+	var g Group
+	err := scanGroup(db.QueryRow("SELECT "+groupColumns+" FROM groups g WHERE g.id = ?", id), &g)
+	if err != nil {
+		return nil, err
+	}
+	return &g, nil
+}
+
+// CreateGroup creates a new group. The group must have been normalized.
 func CreateGroup(db *sql.DB, group *Group) error {
+	if err := checkParent(db, group); err != nil {
+		return err
+	}
+	filter, err := group.filterJSON()
+	if err != nil {
+		return err
+	}
+
+	now := nowUTC()
 	result, err := db.Exec(`
-		INSERT INTO groups (name)
-		VALUES (?)`,
-		group.Name)
+		INSERT INTO groups (parent_id, name, description, color, icon, sort_position, created_at, updated_at, kind, filter)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		group.ParentID, group.Name, group.Description, nullableString(group.Color), nullableString(group.Icon), group.SortPosition, now, now,
+		group.Kind, filter)
 	if err != nil {
 		return err
 	}
@@ -114,32 +561,62 @@ func CreateGroup(db *sql.DB, group *Group) error {
 		return err
 	}
 	group.ID = int(id)
+	group.CreatedAt, group.UpdatedAt = now, now
 	return nil
 }
 
-// UpdateGroup updates an existing group
+// UpdateGroup updates an existing group. The group must have been
+// normalized; its kind cannot change.
 func UpdateGroup(db *sql.DB, group *Group) error {
-	_, err := db.Exec(`
+	var kind string
+	err := db.QueryRow("SELECT kind FROM groups WHERE id = ?", group.ID).Scan(&kind)
+	if err != nil {
+		return err
+	}
+	if kind != group.Kind {
+		return &ValidationError{Field: "kind", Message: "cannot be changed"}
+	}
+	if err := checkParent(db, group); err != nil {
+		return err
+	}
+
+	filter, err := group.filterJSON()
+	if err != nil {
+		return err
+	}
+
+	now := nowUTC()
+	result, err := db.Exec(`
 		UPDATE groups 
-		SET name = ?
+		SET parent_id = ?, name = ?, description = ?, color = ?, icon = ?, sort_position = ?, updated_at = ?, filter = ?
 		WHERE id = ?`,
-		group.Name, group.ID)
-	return err
+		group.ParentID, group.Name, group.Description, nullableString(group.Color), nullableString(group.Icon), group.SortPosition, now,
+		filter, group.ID)
+	if err != nil {
+		return err
+	}
+	if err := requireAffected(result); err != nil {
+		return err
+	}
+
+	// Reload so that fields not part of the update are returned as stored
+	updated, err := LookupGroup(db, group.ID)
+	if err != nil {
+		return err
+	}
+	*group = *updated
+	return nil
 }
 
-// AddWordToGroup adds a word to a group
+// AddWordToGroup adds a word to a manual group
 func AddWordToGroup(db *sql.DB, groupID, wordID int) error {
 	// Check if group and word exist
-	var exists bool
-	err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM groups WHERE id = ?)", groupID).Scan(&exists)
-	if err != nil {
+	if err := requireManualGroup(db, groupID); err != nil {
 		return err
 	}
-	if !exists {
-		return sql.ErrNoRows
-	}
 
-	err = db.QueryRow("SELECT EXISTS(SELECT 1 FROM words WHERE id = ?)", wordID).Scan(&exists)
+	var exists bool
+	err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM words WHERE id = ?)", wordID).Scan(&exists)
 	if err != nil {
 		return err
 	}
@@ -147,24 +624,20 @@ func AddWordToGroup(db *sql.DB, groupID, wordID int) error {
 		return sql.ErrNoRows
 	}
 
-	// Add word to group
-	_, err = db.Exec("INSERT INTO words_groups (group_id, word_id) VALUES (?, ?)", groupID, wordID)
+	// Add word to group, doing nothing if it is already there
+	_, err = db.Exec("INSERT OR IGNORE INTO words_groups (group_id, word_id) VALUES (?, ?)", groupID, wordID)
 	return err
 }
 
-// RemoveWordFromGroup removes a word from a group
+// RemoveWordFromGroup removes a word from a manual group
 func RemoveWordFromGroup(db *sql.DB, groupID, wordID int) error {
 	// Check if group and word exist
-	var exists bool
-	err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM groups WHERE id = ?)", groupID).Scan(&exists)
-	if err != nil {
+	if err := requireManualGroup(db, groupID); err != nil {
 		return err
 	}
-	if !exists {
-		return sql.ErrNoRows
-	}
 
-	err = db.QueryRow("SELECT EXISTS(SELECT 1 FROM words WHERE id = ?)", wordID).Scan(&exists)
+	var exists bool
+	err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM words WHERE id = ?)", wordID).Scan(&exists)
 	if err != nil {
 		return err
 	}
@@ -177,13 +650,25 @@ func RemoveWordFromGroup(db *sql.DB, groupID, wordID int) error {
 	return err
 }
 
-// DeleteGroup deletes a group and its word associations
+// DeleteGroup deletes a group with its word associations and quizzes. Its
+// children move up to the group's parent, and smart group filters stop
+// naming it.
 func DeleteGroup(db *sql.DB, id int) error {
 	tx, err := db.Begin()
 	if err != nil {
 		return err
 	}
 
+	_, err = tx.Exec(`
+		UPDATE groups
+		SET parent_id = (SELECT parent_id FROM groups WHERE id = ?)
+		WHERE parent_id = ?`,
+		id, id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
 	// Delete from word_groups first (foreign key constraint)
 	_, err = tx.Exec("DELETE FROM words_groups WHERE group_id = ?", id)
 	if err != nil {
@@ -191,6 +676,22 @@ func DeleteGroup(db *sql.DB, id int) error {
 		return err
 	}
 
+	_, err = tx.Exec("DELETE FROM quiz_questions WHERE quiz_id IN (SELECT id FROM quizzes WHERE group_id = ?)", id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	_, err = tx.Exec("DELETE FROM quizzes WHERE group_id = ?", id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := replaceFilterGroups(tx, []int{id}, nil); err != nil {
+		tx.Rollback()
+		return err
+	}
+
 	// Delete the group
 	_, err = tx.Exec("DELETE FROM groups WHERE id = ?", id)
 	if err != nil {
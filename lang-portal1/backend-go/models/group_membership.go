@@ -0,0 +1,434 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Set operations for CombineGroups
+const (
+	SetUnion        = "union"
+	SetIntersection = "intersection"
+	SetDifference   = "difference"
+)
+
+var ErrMergeIntoSelf = errors.New("a group cannot be merged into itself")
+
+// MembershipChange reports the outcome of a bulk membership change
+type MembershipChange struct {
+	GroupID   int `json:"group_id"`
+	Added     int `json:"added"`
+	Removed   int `json:"removed"`
+	WordCount int `json:"word_count"`
+}
+
+// uniqueIDs drops duplicate IDs, keeping the first occurrence
+func uniqueIDs(ids []int) []int {
+	seen := make(map[int]bool, len(ids))
+	unique := make([]int, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
+
+func idArgs(ids []int) []interface{} {
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	return args
+}
+
+// checkWordsExist fails with a validation error naming any unknown word IDs
+func checkWordsExist(db *sql.DB, wordIDs []int) error {
+	if len(wordIDs) == 0 {
+		return nil
+	}
+
+	rows, err := db.Query("SELECT id FROM words WHERE id IN ("+placeholders(len(wordIDs))+")", idArgs(wordIDs)...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	found := make(map[int]bool, len(wordIDs))
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return err
+		}
+		found[id] = true
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	var missing []string
+	for _, id := range wordIDs {
+		if !found[id] {
+			missing = append(missing, fmt.Sprint(id))
+		}
+	}
+	if len(missing) > 0 {
+		return &ValidationError{Field: "word_ids", Message: "unknown words " + strings.Join(missing, ", ")}
+	}
+	return nil
+}
+
+// changeGroupWords runs fn in a transaction after checking that the group
+// is manual and the words exist, and reports the resulting word count
+func changeGroupWords(db *sql.DB, groupID int, wordIDs []int, fn func(tx *sql.Tx, change *MembershipChange) error) (*MembershipChange, error) {
+	if err := requireManualGroup(db, groupID); err != nil {
+		return nil, err
+	}
+	if err := checkWordsExist(db, wordIDs); err != nil {
+		return nil, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	change := &MembershipChange{GroupID: groupID}
+	if err := fn(tx, change); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	err = tx.QueryRow("SELECT COUNT(*) FROM words_groups WHERE group_id = ?", groupID).Scan(&change.WordCount)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	return change, tx.Commit()
+}
+
+// insertGroupWords adds words to a group, skipping those already in it, and
+// returns how many were added
+func insertGroupWords(tx *sql.Tx, groupID int, wordIDs []int) (int, error) {
+	added := 0
+	for _, wordID := range wordIDs {
+		result, err := tx.Exec("INSERT OR IGNORE INTO words_groups (group_id, word_id) VALUES (?, ?)", groupID, wordID)
+		if err != nil {
+			return 0, err
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		added += int(n)
+	}
+	return added, nil
+}
+
+// AddWordsToGroup adds several words to a manual group. Words already in
+// the group are skipped.
+func AddWordsToGroup(db *sql.DB, groupID int, wordIDs []int) (*MembershipChange, error) {
+	wordIDs = uniqueIDs(wordIDs)
+	return changeGroupWords(db, groupID, wordIDs, func(tx *sql.Tx, change *MembershipChange) error {
+		var err error
+		change.Added, err = insertGroupWords(tx, groupID, wordIDs)
+		return err
+	})
+}
+
+// RemoveWordsFromGroup removes several words from a manual group. Words
+// that are not in the group are skipped.
+func RemoveWordsFromGroup(db *sql.DB, groupID int, wordIDs []int) (*MembershipChange, error) {
+	wordIDs = uniqueIDs(wordIDs)
+	return changeGroupWords(db, groupID, wordIDs, func(tx *sql.Tx, change *MembershipChange) error {
+		if len(wordIDs) == 0 {
+			return nil
+		}
+		result, err := tx.Exec(`
+			DELETE FROM words_groups
+			WHERE group_id = ? AND word_id IN (`+placeholders(len(wordIDs))+`)`,
+			append([]interface{}{groupID}, idArgs(wordIDs)...)...)
+		if err != nil {
+			return err
+		}
+		n, err := result.RowsAffected()
+		change.Removed = int(n)
+		return err
+	})
+}
+
+// ReplaceGroupWords makes wordIDs the exact word list of a manual group
+func ReplaceGroupWords(db *sql.DB, groupID int, wordIDs []int) (*MembershipChange, error) {
+	wordIDs = uniqueIDs(wordIDs)
+	return changeGroupWords(db, groupID, wordIDs, func(tx *sql.Tx, change *MembershipChange) error {
+		query := "DELETE FROM words_groups WHERE group_id = ?"
+		args := []interface{}{groupID}
+		if len(wordIDs) > 0 {
+			query += " AND word_id NOT IN (" + placeholders(len(wordIDs)) + ")"
+			args = append(args, idArgs(wordIDs)...)
+		}
+		result, err := tx.Exec(query, args...)
+		if err != nil {
+			return err
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		change.Removed = int(n)
+
+		change.Added, err = insertGroupWords(tx, groupID, wordIDs)
+		return err
+	})
+}
+
+// groupWordIDs evaluates the members of a group
+func groupWordIDs(db *sql.DB, groupID int) ([]int, error) {
+	members, args, err := groupMembers(db, groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query("SELECT w.id FROM words w WHERE w.id IN ("+members+") ORDER BY w.id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// createGroupWithWords stores a normalized group and its words in one
+// transaction
+func createGroupWithWords(db *sql.DB, group *Group, wordIDs []int) error {
+	if err := checkParent(db, group); err != nil {
+		return err
+	}
+	filter, err := group.filterJSON()
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	now := nowUTC()
+	result, err := tx.Exec(`
+		INSERT INTO groups (parent_id, name, description, color, icon, sort_position, created_at, updated_at, kind, filter)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		group.ParentID, group.Name, group.Description, nullableString(group.Color), nullableString(group.Icon), group.SortPosition, now, now,
+		group.Kind, filter)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	group.ID = int(id)
+	group.CreatedAt, group.UpdatedAt = now, now
+
+	if _, err := insertGroupWords(tx, group.ID, wordIDs); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// CloneGroup copies a group, its metadata and its words. Smart groups are
+// cloned with their filter. An empty name becomes "<name> (copy)".
+func CloneGroup(db *sql.DB, id int, name string) (*Group, error) {
+	source, err := LookupGroup(db, id)
+	if err != nil {
+		return nil, err
+	}
+
+	clone := *source
+	clone.ID = 0
+	clone.Name = name
+	if strings.TrimSpace(clone.Name) == "" {
+		clone.Name = source.Name + " (copy)"
+	}
+	if err := clone.Normalize(); err != nil {
+		return nil, err
+	}
+
+	var wordIDs []int
+	if source.Kind == GroupKindManual {
+		if wordIDs, err = groupWordIDs(db, id); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := createGroupWithWords(db, &clone, wordIDs); err != nil {
+		return nil, err
+	}
+	return &clone, nil
+}
+
+// MergeGroups moves the words of the source groups into a manual target.
+// Unless keepSources is set the sources are removed afterwards: their study
+// sessions, quizzes and child groups move to the target as well, and smart
+// group filters naming a source name the target instead.
+func MergeGroups(db *sql.DB, targetID int, sourceIDs []int, keepSources bool) (*MembershipChange, error) {
+	sourceIDs = uniqueIDs(sourceIDs)
+	if err := requireManualGroup(db, targetID); err != nil {
+		return nil, err
+	}
+
+	isSource := make(map[int]bool, len(sourceIDs))
+	var wordIDs []int
+	for _, id := range sourceIDs {
+		if id == targetID {
+			return nil, ErrMergeIntoSelf
+		}
+		isSource[id] = true
+
+		ids, err := groupWordIDs(db, id)
+		if err != nil {
+			return nil, err
+		}
+		wordIDs = append(wordIDs, ids...)
+	}
+	wordIDs = uniqueIDs(wordIDs)
+
+	// If the target sits below a source, hang it from the closest ancestor
+	// that survives the merge
+	target, err := LookupGroup(db, targetID)
+	if err != nil {
+		return nil, err
+	}
+	parentID := target.ParentID
+	for parentID != nil && isSource[*parentID] && !keepSources {
+		parent, err := LookupGroup(db, *parentID)
+		if err != nil {
+			return nil, err
+		}
+		parentID = parent.ParentID
+	}
+
+	return changeGroupWords(db, targetID, nil, func(tx *sql.Tx, change *MembershipChange) error {
+		var err error
+		if change.Added, err = insertGroupWords(tx, targetID, wordIDs); err != nil {
+			return err
+		}
+		if keepSources || len(sourceIDs) == 0 {
+			return nil
+		}
+
+		in := placeholders(len(sourceIDs))
+		args := idArgs(sourceIDs)
+		if _, err := tx.Exec("UPDATE groups SET parent_id = ? WHERE id = ?", parentID, targetID); err != nil {
+			return err
+		}
+		_, err = tx.Exec("UPDATE groups SET parent_id = ? WHERE parent_id IN ("+in+") AND id != ?",
+			append(append([]interface{}{targetID}, args...), targetID)...)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec("UPDATE study_sessions SET group_id = ? WHERE group_id IN ("+in+")",
+			append([]interface{}{targetID}, args...)...)
+		if err != nil {
+			return err
+		}
+		// Sessions that already covered the target keep a single row for it
+		_, err = tx.Exec("UPDATE OR IGNORE study_session_groups SET group_id = ? WHERE group_id IN ("+in+")",
+			append([]interface{}{targetID}, args...)...)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec("DELETE FROM study_session_groups WHERE group_id IN ("+in+")", args...); err != nil {
+			return err
+		}
+		_, err = tx.Exec("UPDATE study_session_words SET group_id = ? WHERE group_id IN ("+in+")",
+			append([]interface{}{targetID}, args...)...)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec("UPDATE quizzes SET group_id = ? WHERE group_id IN ("+in+")",
+			append([]interface{}{targetID}, args...)...)
+		if err != nil {
+			return err
+		}
+		if err := replaceFilterGroups(tx, sourceIDs, &targetID); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("DELETE FROM words_groups WHERE group_id IN ("+in+")", args...); err != nil {
+			return err
+		}
+		_, err = tx.Exec("DELETE FROM groups WHERE id IN ("+in+")", args...)
+		return err
+	})
+}
+
+// CombineGroups creates a manual group holding the union, intersection or
+// difference of the words in groupIDs. The difference keeps the words of
+// the first group that are in none of the others.
+func CombineGroups(db *sql.DB, operation string, groupIDs []int, group *Group) error {
+	groupIDs = uniqueIDs(groupIDs)
+	if len(groupIDs) < 2 {
+		return &ValidationError{Field: "group_ids", Message: "at least two groups are required"}
+	}
+	group.Kind, group.Filter = GroupKindManual, nil
+	if err := group.Normalize(); err != nil {
+		return err
+	}
+
+	sets := make([][]int, len(groupIDs))
+	for i, id := range groupIDs {
+		ids, err := groupWordIDs(db, id)
+		if err != nil {
+			return err
+		}
+		sets[i] = ids
+	}
+
+	// Count how many of the other groups hold each word
+	inOthers := make(map[int]int)
+	for _, ids := range sets[1:] {
+		for _, id := range ids {
+			inOthers[id]++
+		}
+	}
+
+	var wordIDs []int
+	switch operation {
+	case SetUnion:
+		for _, ids := range sets {
+			wordIDs = append(wordIDs, ids...)
+		}
+		wordIDs = uniqueIDs(wordIDs)
+	case SetIntersection:
+		for _, id := range sets[0] {
+			if inOthers[id] == len(sets)-1 {
+				wordIDs = append(wordIDs, id)
+			}
+		}
+	case SetDifference:
+		for _, id := range sets[0] {
+			if inOthers[id] == 0 {
+				wordIDs = append(wordIDs, id)
+			}
+		}
+	default:
+		return &ValidationError{Field: "operation", Message: "must be union, intersection or difference"}
+	}
+
+	return createGroupWithWords(db, group, wordIDs)
+}
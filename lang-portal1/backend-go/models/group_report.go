@@ -0,0 +1,247 @@
+package models
+
+import (
+	"database/sql"
+	"sort"
+	"time"
+)
+
+// Outcomes of a word in one session of a group report
+const (
+	OutcomeCorrect   = "correct"
+	OutcomeIncorrect = "incorrect"
+	OutcomeMixed     = "mixed"
+)
+
+// Trends of a word's accuracy between its earlier and later reviews
+const (
+	TrendImproving = "improving"
+	TrendDeclining = "declining"
+	TrendSteady    = "steady"
+)
+
+// masteryAccuracy is the accuracy a word needs, along with a correct last
+// review, to count as mastered
+const masteryAccuracy = 80.0
+
+// trendThreshold is the change in accuracy, in percentage points, below
+// which a word is considered steady
+const trendThreshold = 10.0
+
+type ReportSession struct {
+	ID        int       `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ReportWord is a row of the mastery matrix. Results has one entry per
+// report session, nil where the word was not reviewed.
+type ReportWord struct {
+	WordID       int       `json:"word_id"`
+	Term         string    `json:"term"`
+	Romanization string    `json:"romanization,omitempty"`
+	Results      []*string `json:"results"`
+	CorrectCount int       `json:"correct_count"`
+	WrongCount   int       `json:"wrong_count"`
+	Accuracy     *float64  `json:"accuracy"`
+	Trend        string    `json:"trend,omitempty"`
+	TrendDelta   *float64  `json:"trend_delta,omitempty"`
+	Mastered     bool      `json:"mastered"`
+}
+
+// GroupReport is the word by session mastery matrix of a group
+type GroupReport struct {
+	GroupID   int             `json:"group_id"`
+	GroupName string          `json:"group_name"`
+	Sessions  []ReportSession `json:"sessions"`
+	Words     []ReportWord    `json:"words"`
+	Weakest   []ReportWord    `json:"weakest"`
+	// Mastery is the percentage of the group's words that are mastered
+	Mastery float64 `json:"mastery"`
+}
+
+// GetGroupReport builds the mastery report of a group from its sessions.
+// Weakest lists up to weakest studied words with the lowest accuracy.
+func GetGroupReport(db *sql.DB, groupID int, weakest int) (*GroupReport, error) {
+	group, err := LookupGroup(db, groupID)
+	if err != nil {
+		return nil, err
+	}
+	words, err := GetGroupWords(db, groupID, WordFilter{})
+	if err != nil {
+		return nil, err
+	}
+
+	report := &GroupReport{
+		GroupID:   group.ID,
+		GroupName: group.Name,
+		Sessions:  []ReportSession{},
+		Words:     make([]ReportWord, len(words)),
+		Weakest:   []ReportWord{},
+	}
+
+	// The columns are the sessions started on the group, and the word list
+	// and query sessions that reviewed its words
+	rows, err := db.Query(`
+		SELECT ss.id, ss.created_at
+		FROM study_sessions ss
+		WHERE ss.id IN (
+			SELECT study_session_id FROM study_session_groups WHERE group_id = ?
+			UNION
+			SELECT study_session_id FROM group_review_items WHERE group_id = ?
+		)
+		ORDER BY ss.created_at, ss.id`,
+		groupID, groupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	column := make(map[int]int)
+	for rows.Next() {
+		var s ReportSession
+		var createdAt sqliteTime
+		if err := rows.Scan(&s.ID, &createdAt); err != nil {
+			return nil, err
+		}
+		s.CreatedAt = createdAt.Time
+		column[s.ID] = len(report.Sessions)
+		report.Sessions = append(report.Sessions, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	row := make(map[int]int, len(words))
+	for i, w := range words {
+		row[w.ID] = i
+		report.Words[i] = ReportWord{
+			WordID:       w.ID,
+			Term:         w.Term,
+			Romanization: w.Romanization,
+			Results:      make([]*string, len(report.Sessions)),
+		}
+	}
+
+	rows, err = db.Query(`
+		SELECT wri.word_id, wri.study_session_id, wri.correct
+		FROM group_review_items wri
+		WHERE wri.group_id = ?
+		ORDER BY wri.created_at, wri.study_session_id`,
+		groupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := make(map[int][]bool)
+	for rows.Next() {
+		var wordID, sessionID int
+		var correct bool
+		if err := rows.Scan(&wordID, &sessionID, &correct); err != nil {
+			return nil, err
+		}
+		i, ok := row[wordID]
+		if !ok {
+			// The word has since left the group
+			continue
+		}
+		j, ok := column[sessionID]
+		if !ok {
+			// Every session with reviews of the group has a column
+			continue
+		}
+		history[wordID] = append(history[wordID], correct)
+
+		outcome := OutcomeIncorrect
+		if correct {
+			outcome = OutcomeCorrect
+		}
+		cell := &report.Words[i].Results[j]
+		if *cell != nil && **cell != outcome {
+			outcome = OutcomeMixed
+		}
+		*cell = &outcome
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	mastered := 0
+	for i := range report.Words {
+		w := &report.Words[i]
+		w.summarize(history[w.WordID])
+		if w.Mastered {
+			mastered++
+		}
+	}
+	if len(report.Words) > 0 {
+		report.Mastery = 100 * float64(mastered) / float64(len(report.Words))
+	}
+
+	report.Weakest = weakestWords(report.Words, weakest)
+	return report, nil
+}
+
+// summarize fills in the counts, accuracy, trend and mastery of a word
+// from its reviews, oldest first
+func (w *ReportWord) summarize(reviews []bool) {
+	if len(reviews) == 0 {
+		return
+	}
+	for _, correct := range reviews {
+		if correct {
+			w.CorrectCount++
+		} else {
+			w.WrongCount++
+		}
+	}
+	accuracy := reviewAccuracy(reviews)
+	w.Accuracy = &accuracy
+	w.Mastered = accuracy >= masteryAccuracy && reviews[len(reviews)-1]
+
+	if len(reviews) < 2 {
+		return
+	}
+	half := len(reviews) / 2
+	delta := reviewAccuracy(reviews[half:]) - reviewAccuracy(reviews[:half])
+	w.TrendDelta = &delta
+	switch {
+	case delta >= trendThreshold:
+		w.Trend = TrendImproving
+	case delta <= -trendThreshold:
+		w.Trend = TrendDeclining
+	default:
+		w.Trend = TrendSteady
+	}
+}
+
+func reviewAccuracy(reviews []bool) float64 {
+	correct := 0
+	for _, c := range reviews {
+		if c {
+			correct++
+		}
+	}
+	return 100 * float64(correct) / float64(len(reviews))
+}
+
+// weakestWords returns up to n studied words, lowest accuracy first
+func weakestWords(words []ReportWord, n int) []ReportWord {
+	weakest := []ReportWord{}
+	for _, w := range words {
+		if w.Accuracy != nil {
+			weakest = append(weakest, w)
+		}
+	}
+	sort.SliceStable(weakest, func(i, j int) bool {
+		if *weakest[i].Accuracy != *weakest[j].Accuracy {
+			return *weakest[i].Accuracy < *weakest[j].Accuracy
+		}
+		return weakest[i].WrongCount > weakest[j].WrongCount
+	})
+	if len(weakest) > n {
+		weakest = weakest[:n]
+	}
+	return weakest
+}
@@ -0,0 +1,141 @@
+package models
+
+import (
+	"database/sql"
+)
+
+// GroupWord is a word as listed in a group, with its results in the
+// group's study sessions
+type GroupWord struct {
+	Word
+	CorrectCount      int      `json:"correct_count"`
+	WrongCount        int      `json:"wrong_count"`
+	Accuracy          *float64 `json:"accuracy"`
+	AvgResponseTimeMs *float64 `json:"avg_response_time_ms"`
+	HintCount         int      `json:"hint_count"`
+}
+
+// GroupWordsQuery narrows down and orders a page of group words
+type GroupWordsQuery struct {
+	WordFilter
+
+	// IncludeDescendants also lists the words of nested groups and counts
+	// their sessions
+	IncludeDescendants bool
+
+	// Sort is one of the groupWordSortColumns keys, id by default
+	Sort string
+	Desc bool
+
+	// Attempts selects the reviews counted in each word's results
+	Attempts Attempts
+}
+
+// groupWordAccuracy is NULL for words without reviews in the group
+const groupWordAccuracy = "CASE WHEN gs.reviews > 0 THEN 100.0 * gs.correct / gs.reviews END"
+
+var groupWordSortColumns = map[string]string{
+	"id":                   "w.id",
+	"term":                 "w.term",
+	"romanization":         "w.romanization COLLATE NOCASE",
+	"correct_count":        "correct_count",
+	"wrong_count":          "wrong_count",
+	"accuracy":             "accuracy",
+	"avg_response_time_ms": "avg_response_time_ms",
+}
+
+func (q GroupWordsQuery) orderBy() (string, error) {
+	field := q.Sort
+	if field == "" {
+		field = "id"
+	}
+	column, ok := groupWordSortColumns[field]
+	if !ok {
+		return "", ErrInvalidSort
+	}
+	if q.Desc {
+		return column + " DESC, w.id DESC", nil
+	}
+	return column + ", w.id", nil
+}
+
+// GetGroupWordsPage retrieves a page of the words in a group along with
+// how each word did in the group's sessions. It returns sql.ErrNoRows for
+// unknown groups.
+func GetGroupWordsPage(db *sql.DB, groupID int, query GroupWordsQuery, page, perPage int) ([]GroupWord, int, error) {
+	offset := (page - 1) * perPage
+	orderBy, err := query.orderBy()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	groupIDs := []int{groupID}
+	if query.IncludeDescendants {
+		if groupIDs, err = groupSubtree(db, groupID); err != nil {
+			return nil, 0, err
+		}
+	}
+	members, memberArgs, err := subtreeMembers(db, groupIDs)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	where, filterArgs := query.conditions()
+	where = "w.id IN (" + members + ") AND " + where
+	whereArgs := append(memberArgs, filterArgs...)
+
+	// Get total count
+	var total int
+	err = db.QueryRow("SELECT COUNT(*) FROM words w WHERE "+where, whereArgs...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	args := append(idArgs(groupIDs), whereArgs...)
+	rows, err := db.Query(`
+		SELECT `+wordColumns+`,
+			COALESCE(gs.correct, 0) AS correct_count,
+			COALESCE(gs.reviews - gs.correct, 0) AS wrong_count,
+			`+groupWordAccuracy+` AS accuracy,
+			gs.avg_response_time_ms,
+			COALESCE(gs.hints, 0) AS hint_count
+		FROM words w
+		LEFT JOIN (
+			SELECT wri.word_id,
+				COUNT(*) AS reviews,
+				SUM(CASE WHEN wri.correct THEN 1 ELSE 0 END) AS correct,
+				AVG(wri.response_time_ms) AS avg_response_time_ms,
+				SUM(CASE WHEN wri.hint_used THEN 1 ELSE 0 END) AS hints
+			FROM `+query.Attempts.reviews()+` wri
+			WHERE wri.id IN (
+				SELECT id FROM group_review_items WHERE group_id IN (`+placeholders(len(groupIDs))+`)
+			)
+			GROUP BY wri.word_id
+		) gs ON gs.word_id = w.id
+		WHERE `+where+`
+		ORDER BY `+orderBy+`
+		LIMIT ? OFFSET ?`,
+		append(args, perPage, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	words := []GroupWord{}
+	for rows.Next() {
+		var gw GroupWord
+		var accuracy, avgResponseTime sql.NullFloat64
+		word, err := scanWord(rowFunc(func(dest ...interface{}) error {
+			return rows.Scan(append(dest, &gw.CorrectCount, &gw.WrongCount, &accuracy, &avgResponseTime, &gw.HintCount)...)
+		}))
+		if err != nil {
+			return nil, 0, err
+		}
+		gw.Word = *word
+		gw.Accuracy = nullableFloat(accuracy)
+		gw.AvgResponseTimeMs = nullableFloat(avgResponseTime)
+		words = append(words, gw)
+	}
+
+	return words, total, rows.Err()
+}
@@ -0,0 +1,142 @@
+package models
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// LeechPolicy decides when a word failed over and over becomes a leech.
+// Failures are counted over first attempts, like scheduling.
+type LeechPolicy struct {
+	// ConsecutiveFailures and TotalFailures are the thresholds, 0 to
+	// disable one
+	ConsecutiveFailures int
+	TotalFailures       int
+	// AutoSuspend suspends words as they become leeches
+	AutoSuspend bool
+}
+
+var DefaultLeechPolicy = LeechPolicy{ConsecutiveFailures: 4, TotalFailures: 8}
+
+var leechPolicy = DefaultLeechPolicy
+
+// SetLeechPolicy changes how leeches are detected
+func SetLeechPolicy(p LeechPolicy) {
+	leechPolicy = p
+}
+
+// condition is the SQL condition on word_srs_state s for leeches
+func (p LeechPolicy) condition() (string, []interface{}) {
+	var where []string
+	var args []interface{}
+	if p.ConsecutiveFailures > 0 {
+		where = append(where, "s.consecutive_failures >= ?")
+		args = append(args, p.ConsecutiveFailures)
+	}
+	if p.TotalFailures > 0 {
+		where = append(where, "s.failures >= ?")
+		args = append(args, p.TotalFailures)
+	}
+	if len(where) == 0 {
+		return "0", nil
+	}
+	return "(" + strings.Join(where, " OR ") + ")", args
+}
+
+// Leech is a word failed often enough to need attention
+type Leech struct {
+	Word
+	Failures            int       `json:"failures"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	Lapses              int       `json:"lapses"`
+	LastReviewedAt      time.Time `json:"last_reviewed_at"`
+}
+
+// GetLeeches retrieves a page of the leeches, the longest failing streaks
+// first
+func GetLeeches(db *sql.DB, page, perPage int) ([]Leech, int, error) {
+	offset := (page - 1) * perPage
+	cond, args := leechPolicy.condition()
+
+	var total int
+	err := db.QueryRow("SELECT COUNT(*) FROM word_srs_state s JOIN words w ON w.id = s.word_id WHERE "+cond, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := db.Query(`
+		SELECT `+wordColumns+`, s.failures, s.consecutive_failures, s.lapses, s.last_reviewed_at
+		FROM word_srs_state s
+		JOIN words w ON w.id = s.word_id
+		WHERE `+cond+`
+		ORDER BY s.consecutive_failures DESC, s.failures DESC, w.id
+		LIMIT ? OFFSET ?`,
+		append(args, perPage, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	leeches := []Leech{}
+	for rows.Next() {
+		var l Leech
+		var lastReview sqliteTime
+		word, err := scanWord(rowFunc(func(dest ...interface{}) error {
+			return rows.Scan(append(dest, &l.Failures, &l.ConsecutiveFailures, &l.Lapses, &lastReview)...)
+		}))
+		if err != nil {
+			return nil, 0, err
+		}
+		l.Word = *word
+		l.LastReviewedAt = lastReview.Time
+		leeches = append(leeches, l)
+	}
+	return leeches, total, rows.Err()
+}
+
+// isLeech reports whether a word is currently a leech
+func isLeech(db querier, wordID int) (bool, error) {
+	cond, args := leechPolicy.condition()
+	var leech bool
+	err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM word_srs_state s WHERE s.word_id = ? AND "+cond+")",
+		append([]interface{}{wordID}, args...)...).Scan(&leech)
+	return leech, err
+}
+
+// suspendLeech suspends a word if it is a leech and not suspended yet,
+// recording the review that made it one
+func suspendLeech(db querier, wordID int, reviewID int64, now time.Time) error {
+	cond, args := leechPolicy.condition()
+	_, err := db.Exec(`
+		UPDATE words
+		SET suspended_at = ?, suspended_by_review_id = ?
+		WHERE id = ? AND suspended_at IS NULL
+			AND EXISTS(SELECT 1 FROM word_srs_state s WHERE s.word_id = words.id AND `+cond+`)`,
+		append([]interface{}{now.Format(reviewTimeFormat), reviewID, wordID}, args...)...)
+	return err
+}
+
+// unsuspendLeech lifts the suspension of a word if the given review caused
+// it
+func unsuspendLeech(db execer, wordID int, reviewID int) error {
+	_, err := db.Exec(`
+		UPDATE words
+		SET suspended_at = NULL, suspended_by_review_id = NULL
+		WHERE id = ? AND suspended_by_review_id = ?`,
+		wordID, reviewID)
+	return err
+}
+
+// UnsuspendWord puts a suspended word back into study queues. A leech is
+// suspended again only if it stops being a leech and becomes one anew.
+func UnsuspendWord(db *sql.DB, id int) (*Word, error) {
+	result, err := db.Exec("UPDATE words SET suspended_at = NULL, suspended_by_review_id = NULL WHERE id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	if err := requireAffected(result); err != nil {
+		return nil, err
+	}
+	return LookupWord(db, id)
+}
@@ -0,0 +1,199 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Media sources
+const (
+	MediaSourceUpload = "upload"
+	MediaSourceTTS    = "tts"
+)
+
+type WordMedia struct {
+	ID         int       `json:"id"`
+	WordID     int       `json:"word_id"`
+	Kind       string    `json:"kind"`
+	Hash       string    `json:"hash"`
+	MimeType   string    `json:"mime_type"`
+	Size       int64     `json:"size"`
+	Filename   string    `json:"filename"`
+	Source     string    `json:"source"`
+	Voice      string    `json:"voice,omitempty"`
+	SourceText string    `json:"source_text,omitempty"`
+	URL        string    `json:"url"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+const wordMediaColumns = "id, word_id, kind, hash, mime_type, size, filename, source, voice, source_text, created_at"
+
+func scanWordMedia(row rowScanner) (*WordMedia, error) {
+	var m WordMedia
+	if err := row.Scan(&m.ID, &m.WordID, &m.Kind, &m.Hash, &m.MimeType, &m.Size,
+		&m.Filename, &m.Source, &m.Voice, &m.SourceText, &m.CreatedAt); err != nil {
+		return nil, err
+	}
+	m.URL = MediaURL(m.Hash)
+	return &m, nil
+}
+
+// MediaURL returns the path a blob is served from
+func MediaURL(hash string) string {
+	return "/api/media/" + hash
+}
+
+// GetWordMedia retrieves all media attached to a word
+func GetWordMedia(db *sql.DB, wordID int) ([]WordMedia, error) {
+	rows, err := db.Query(`
+		SELECT `+wordMediaColumns+`
+		FROM word_media
+		WHERE word_id = ?
+		ORDER BY created_at DESC, id DESC`,
+		wordID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var media []WordMedia
+	for rows.Next() {
+		m, err := scanWordMedia(rows)
+		if err != nil {
+			return nil, err
+		}
+		media = append(media, *m)
+	}
+
+	return media, rows.Err()
+}
+
+// CreateWordMedia attaches a stored blob to a word. Attaching the same blob
+// to the same word twice returns the existing attachment.
+func CreateWordMedia(db *sql.DB, m *WordMedia) error {
+	// Check if word exists
+	var exists bool
+	err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM words WHERE id = ?)", m.WordID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return sql.ErrNoRows
+	}
+
+	if m.Source == "" {
+		m.Source = MediaSourceUpload
+	}
+
+	_, err = db.Exec(`
+		INSERT OR IGNORE INTO word_media (word_id, kind, hash, mime_type, size, filename, source, voice, source_text, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)`,
+		m.WordID, m.Kind, m.Hash, m.MimeType, m.Size, m.Filename, m.Source, m.Voice, m.SourceText)
+	if err != nil {
+		return err
+	}
+
+	existing, err := scanWordMedia(db.QueryRow(`
+		SELECT `+wordMediaColumns+`
+		FROM word_media
+		WHERE word_id = ? AND hash = ?`,
+		m.WordID, m.Hash))
+	if err != nil {
+		return err
+	}
+	*m = *existing
+	return nil
+}
+
+// GetWordAudio retrieves the audio to play for a word, preferring uploaded
+// recordings over generated speech
+func GetWordAudio(db *sql.DB, wordID int) (*WordMedia, error) {
+	return scanWordMedia(db.QueryRow(`
+		SELECT `+wordMediaColumns+`
+		FROM word_media
+		WHERE word_id = ? AND kind = 'audio'
+		ORDER BY source = 'upload' DESC, created_at DESC, id DESC
+		LIMIT 1`,
+		wordID))
+}
+
+// GetCachedSpeech retrieves generated speech for a word that matches the
+// given voice and text
+func GetCachedSpeech(db *sql.DB, wordID int, voice, text string) (*WordMedia, error) {
+	return scanWordMedia(db.QueryRow(`
+		SELECT `+wordMediaColumns+`
+		FROM word_media
+		WHERE word_id = ? AND source = 'tts' AND voice = ? AND source_text = ?
+		ORDER BY created_at DESC, id DESC
+		LIMIT 1`,
+		wordID, voice, text))
+}
+
+// DeleteStaleSpeech removes generated speech for a word other than keepID
+// and returns the hashes of the blobs it referenced
+func DeleteStaleSpeech(db *sql.DB, wordID, keepID int) ([]string, error) {
+	rows, err := db.Query(`
+		SELECT hash FROM word_media
+		WHERE word_id = ? AND source = 'tts' AND id != ?`,
+		wordID, keepID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hashes []string
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			return nil, err
+		}
+		hashes = append(hashes, hash)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	_, err = db.Exec("DELETE FROM word_media WHERE word_id = ? AND source = 'tts' AND id != ?", wordID, keepID)
+	return hashes, err
+}
+
+// GetMediaByHash retrieves the newest attachment that references a blob
+func GetMediaByHash(db *sql.DB, hash string) (*WordMedia, error) {
+	return scanWordMedia(db.QueryRow(`
+		SELECT `+wordMediaColumns+`
+		FROM word_media
+		WHERE hash = ?
+		ORDER BY created_at DESC
+		LIMIT 1`,
+		hash))
+}
+
+// DeleteWordMedia removes an attachment from a word and returns the hash of
+// the blob it referenced
+func DeleteWordMedia(db *sql.DB, wordID, mediaID int) (string, error) {
+	var hash string
+	err := db.QueryRow("SELECT hash FROM word_media WHERE id = ? AND word_id = ?", mediaID, wordID).Scan(&hash)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = db.Exec("DELETE FROM word_media WHERE id = ?", mediaID)
+	return hash, err
+}
+
+// GetOrphanedMediaHashes filters hashes down to the blobs that are no longer
+// referenced by any word
+func GetOrphanedMediaHashes(db *sql.DB, hashes []string) ([]string, error) {
+	var orphaned []string
+	for _, hash := range hashes {
+		var exists bool
+		err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM word_media WHERE hash = ?)", hash).Scan(&exists)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			orphaned = append(orphaned, hash)
+		}
+	}
+	return orphaned, nil
+}
@@ -0,0 +1,85 @@
+package models
+
+import (
+	"encoding/json"
+)
+
+// Patch is a JSON Merge Patch (RFC 7396) document. Fields that are absent
+// are left alone and fields set to null are cleared.
+type Patch map[string]json.RawMessage
+
+// isNull reports whether a patch field was explicitly set to null
+func (p Patch) isNull(field string) bool {
+	return string(p[field]) == "null"
+}
+
+// has reports whether any of the fields are present in the patch
+func (p Patch) has(fields ...string) bool {
+	for _, field := range fields {
+		if _, ok := p[field]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// decode unmarshals a present field into dest. It reports whether the field
+// was present and fails for null values unless nullable is set.
+func (p Patch) decode(field string, dest interface{}, nullable bool) (bool, error) {
+	raw, ok := p[field]
+	if !ok {
+		return false, nil
+	}
+	if p.isNull(field) {
+		if !nullable {
+			return false, &ValidationError{Field: field, Message: "cannot be null"}
+		}
+		return true, nil
+	}
+	if err := json.Unmarshal(raw, dest); err != nil {
+		return false, &ValidationError{Field: field, Message: "has the wrong type"}
+	}
+	return true, nil
+}
+
+// checkFields rejects fields that the patched resource does not have
+func (p Patch) checkFields(allowed ...string) error {
+	known := make(map[string]bool, len(allowed))
+	for _, field := range allowed {
+		known[field] = true
+	}
+	for field := range p {
+		if !known[field] {
+			return &ValidationError{Field: field, Message: "unknown field"}
+		}
+	}
+	return nil
+}
+
+// mergePatch applies a JSON Merge Patch to a JSON document, recursing into
+// nested objects
+func mergePatch(target, patch json.RawMessage) (json.RawMessage, error) {
+	var patchObject map[string]json.RawMessage
+	if err := json.Unmarshal(patch, &patchObject); err != nil || patchObject == nil {
+		// Anything but an object replaces the target
+		return patch, nil
+	}
+
+	var targetObject map[string]json.RawMessage
+	if err := json.Unmarshal(target, &targetObject); err != nil || targetObject == nil {
+		targetObject = make(map[string]json.RawMessage)
+	}
+
+	for field, value := range patchObject {
+		if string(value) == "null" {
+			delete(targetObject, field)
+			continue
+		}
+		merged, err := mergePatch(targetObject[field], value)
+		if err != nil {
+			return nil, err
+		}
+		targetObject[field] = merged
+	}
+	return json.Marshal(targetObject)
+}
@@ -0,0 +1,452 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"time"
+
+	"lang-portal/backend/language"
+)
+
+// Quiz directions
+const (
+	// QuizMeaning asks for the meaning of a term (jp→en)
+	QuizMeaning = "jp_en"
+	// QuizTerm asks for the term with a meaning (en→jp)
+	QuizTerm = "en_jp"
+	// QuizReading asks for the reading of a term
+	QuizReading = "reading"
+)
+
+const (
+	DefaultQuizQuestions = 10
+	MaxQuizQuestions     = 50
+	DefaultQuizOptions   = 4
+	MaxQuizOptions       = 6
+)
+
+// distractorSample is how many words outside the group are drawn as
+// candidate distractors
+const distractorSample = 200
+
+var ErrQuestionAnswered = errors.New("quiz question has already been answered")
+
+// QuizSpec describes the quiz to generate
+type QuizSpec struct {
+	GroupID   int
+	Direction string
+	Questions int
+	Options   int
+}
+
+// Normalize fills in the defaults and validates the spec
+func (s *QuizSpec) Normalize() error {
+	if s.Direction == "" {
+		s.Direction = QuizMeaning
+	}
+	switch s.Direction {
+	case QuizMeaning, QuizTerm, QuizReading:
+	default:
+		return &ValidationError{Field: "direction", Message: "must be jp_en, en_jp or reading"}
+	}
+	if s.Questions == 0 {
+		s.Questions = DefaultQuizQuestions
+	}
+	if s.Questions < 1 || s.Questions > MaxQuizQuestions {
+		return &ValidationError{Field: "count", Message: fmt.Sprintf("must be between 1 and %d", MaxQuizQuestions)}
+	}
+	if s.Options == 0 {
+		s.Options = DefaultQuizOptions
+	}
+	if s.Options < 2 || s.Options > MaxQuizOptions {
+		return &ValidationError{Field: "options", Message: fmt.Sprintf("must be between 2 and %d", MaxQuizOptions)}
+	}
+	return nil
+}
+
+type QuizQuestion struct {
+	Position int      `json:"position"`
+	WordID   int      `json:"word_id"`
+	Prompt   string   `json:"prompt"`
+	Options  []string `json:"options"`
+	// CorrectOption and SelectedOption are revealed once the question is
+	// answered
+	CorrectOption  *int `json:"correct_option,omitempty"`
+	SelectedOption *int `json:"selected_option,omitempty"`
+	ReviewID       *int `json:"review_id,omitempty"`
+
+	correct int
+}
+
+type Quiz struct {
+	ID             int            `json:"id"`
+	StudySessionID int            `json:"study_session_id"`
+	GroupID        int            `json:"group_id"`
+	Direction      string         `json:"direction"`
+	CreatedAt      time.Time      `json:"created_at"`
+	Questions      []QuizQuestion `json:"questions"`
+}
+
+// quizPrompt returns the prompt and answer of a word for a direction, or
+// empty strings if the word cannot be asked that way
+func quizPrompt(w Word, direction string) (string, string) {
+	switch direction {
+	case QuizMeaning:
+		if len(w.Glosses) > 0 {
+			return w.Term, w.Glosses[0]
+		}
+	case QuizTerm:
+		if len(w.Glosses) > 0 {
+			return w.Glosses[0], w.Term
+		}
+	case QuizReading:
+		reading := w.Reading()
+		if reading == "" {
+			reading = w.Romanization
+		}
+		if reading != "" && reading != w.Term {
+			return w.Term, reading
+		}
+	}
+	return "", ""
+}
+
+// distractorScore rates how well a candidate passes for the target word:
+// words of the same group, sharing kanji, with a similar reading or the
+// same part of speech make harder distractors
+func distractorScore(target, candidate Word, sameGroup bool) int {
+	score := 0
+	if sameGroup {
+		score += 3
+	}
+
+	kanji := make(map[string]bool)
+	for _, k := range language.KanjiIn(target.Term) {
+		kanji[k] = true
+	}
+	for _, k := range language.KanjiIn(candidate.Term) {
+		if kanji[k] {
+			score += 2
+		}
+	}
+
+	tr, cr := []rune(target.Reading()), []rune(candidate.Reading())
+	if len(tr) > 0 && len(cr) > 0 {
+		if len(tr) == len(cr) {
+			score++
+		}
+		if tr[0] == cr[0] {
+			score++
+		}
+		if tr[len(tr)-1] == cr[len(cr)-1] {
+			score++
+		}
+	}
+
+	pos := make(map[string]bool)
+	for _, p := range target.PartsOfSpeech() {
+		pos[p] = true
+	}
+	for _, p := range candidate.PartsOfSpeech() {
+		if pos[p] {
+			score += 2
+			break
+		}
+	}
+	return score
+}
+
+// NewQuiz generates the questions of a quiz on a group's words, leaving out
+// suspended words. It returns sql.ErrNoRows for unknown groups. The quiz is
+// stored with SaveQuiz.
+func NewQuiz(db *sql.DB, spec QuizSpec) (*Quiz, error) {
+	if err := spec.Normalize(); err != nil {
+		return nil, err
+	}
+	members, args, err := groupMembers(db, spec.GroupID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(`
+		SELECT `+wordColumns+`
+		FROM words w
+		WHERE w.id IN (`+members+`) AND w.suspended_at IS NULL
+		ORDER BY w.id`,
+		args...)
+	if err != nil {
+		return nil, err
+	}
+	groupWords, err := scanWords(rows)
+	if err != nil {
+		return nil, err
+	}
+	inGroup := make(map[int]bool)
+	for _, w := range groupWords {
+		inGroup[w.ID] = true
+	}
+
+	// Distractors come from the group and a random sample of the other
+	// words of the same language
+	pools := make(map[string][]Word)
+	for _, w := range groupWords {
+		if _, ok := pools[w.Language]; ok {
+			continue
+		}
+		rows, err := db.Query(`
+			SELECT `+wordColumns+`
+			FROM words w
+			WHERE w.language = ? AND w.id NOT IN (`+members+`)
+			ORDER BY random()
+			LIMIT ?`,
+			append(append([]interface{}{w.Language}, args...), distractorSample)...)
+		if err != nil {
+			return nil, err
+		}
+		sample, err := scanWords(rows)
+		if err != nil {
+			return nil, err
+		}
+		pools[w.Language] = sample
+	}
+	for _, w := range groupWords {
+		pools[w.Language] = append(pools[w.Language], w)
+	}
+
+	quiz := &Quiz{GroupID: spec.GroupID, Direction: spec.Direction, Questions: []QuizQuestion{}}
+	rand.Shuffle(len(groupWords), func(i, j int) { groupWords[i], groupWords[j] = groupWords[j], groupWords[i] })
+	for _, word := range groupWords {
+		if len(quiz.Questions) == spec.Questions {
+			break
+		}
+		prompt, answer := quizPrompt(word, spec.Direction)
+		if answer == "" {
+			continue
+		}
+
+		type candidate struct {
+			answer string
+			score  int
+		}
+		var candidates []candidate
+		for _, w := range pools[word.Language] {
+			if w.ID == word.ID {
+				continue
+			}
+			if _, a := quizPrompt(w, spec.Direction); a != "" && a != answer {
+				candidates = append(candidates, candidate{a, distractorScore(word, w, inGroup[w.ID])})
+			}
+		}
+		rand.Shuffle(len(candidates), func(i, j int) { candidates[i], candidates[j] = candidates[j], candidates[i] })
+		sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].score > candidates[j].score })
+
+		options := []string{answer}
+		seen := map[string]bool{answer: true}
+		for _, c := range candidates {
+			if len(options) == spec.Options {
+				break
+			}
+			if !seen[c.answer] {
+				seen[c.answer] = true
+				options = append(options, c.answer)
+			}
+		}
+		if len(options) < 2 {
+			continue
+		}
+
+		rand.Shuffle(len(options), func(i, j int) { options[i], options[j] = options[j], options[i] })
+		question := QuizQuestion{Position: len(quiz.Questions) + 1, WordID: word.ID, Prompt: prompt, Options: options}
+		for i, option := range options {
+			if option == answer {
+				question.correct = i
+			}
+		}
+		quiz.Questions = append(quiz.Questions, question)
+	}
+
+	if len(quiz.Questions) == 0 {
+		return nil, &ValidationError{Field: "group_id", Message: "group has too few words for a quiz"}
+	}
+	return quiz, nil
+}
+
+// SaveQuiz stores a generated quiz, linked to the study session its answers
+// are recorded in. The session must cover the quiz's group.
+func SaveQuiz(db *sql.DB, quiz *Quiz, sessionID int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var covered bool
+	err = tx.QueryRow(`
+		SELECT EXISTS(SELECT 1 FROM study_session_groups WHERE study_session_id = ? AND group_id = ?)`,
+		sessionID, quiz.GroupID).Scan(&covered)
+	if err != nil {
+		return err
+	}
+	if !covered {
+		return &ValidationError{Field: "study_session_id", Message: "is not a session of the quiz's group"}
+	}
+
+	quiz.StudySessionID = sessionID
+	quiz.CreatedAt = nowUTC()
+	result, err := tx.Exec(`
+		INSERT INTO quizzes (study_session_id, group_id, direction, created_at)
+		VALUES (?, ?, ?, ?)`,
+		sessionID, quiz.GroupID, quiz.Direction, quiz.CreatedAt)
+	if err != nil {
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	quiz.ID = int(id)
+
+	for _, q := range quiz.Questions {
+		options, err := json.Marshal(q.Options)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`
+			INSERT INTO quiz_questions (quiz_id, position, word_id, prompt, options, correct_option)
+			VALUES (?, ?, ?, ?, ?, ?)`,
+			quiz.ID, q.Position, q.WordID, q.Prompt, string(options), q.correct)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// quizQuestionColumns lists the columns scanned by scanQuizQuestion
+const quizQuestionColumns = "position, word_id, prompt, options, correct_option, selected_option, review_id"
+
+func scanQuizQuestion(row rowScanner) (*QuizQuestion, error) {
+	var q QuizQuestion
+	var options string
+	var selected, reviewID sql.NullInt64
+	if err := row.Scan(&q.Position, &q.WordID, &q.Prompt, &options, &q.correct, &selected, &reviewID); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(options), &q.Options); err != nil {
+		return nil, err
+	}
+	q.SelectedOption = nullableInt(selected)
+	q.ReviewID = nullableInt(reviewID)
+	if q.SelectedOption != nil {
+		correct := q.correct
+		q.CorrectOption = &correct
+	}
+	return &q, nil
+}
+
+// GetQuiz retrieves a quiz with its questions. Answers are only revealed
+// for the questions already answered.
+func GetQuiz(db *sql.DB, id int) (*Quiz, error) {
+	var quiz Quiz
+	err := db.QueryRow(`
+		SELECT id, study_session_id, group_id, direction, created_at
+		FROM quizzes
+		WHERE id = ?`,
+		id).Scan(&quiz.ID, &quiz.StudySessionID, &quiz.GroupID, &quiz.Direction, &quiz.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query("SELECT "+quizQuestionColumns+" FROM quiz_questions WHERE quiz_id = ? ORDER BY position", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	quiz.Questions = []QuizQuestion{}
+	for rows.Next() {
+		q, err := scanQuizQuestion(rows)
+		if err != nil {
+			return nil, err
+		}
+		quiz.Questions = append(quiz.Questions, *q)
+	}
+	return &quiz, rows.Err()
+}
+
+// AnswerQuizQuestion records the option selected for a question of a quiz
+// in a study session as a review in that session. The question is claimed
+// and the review recorded in one transaction, so a question submitted twice
+// is only reviewed once. It returns sql.ErrNoRows for unknown questions and
+// quizzes of other sessions, ErrQuestionAnswered if the question was
+// answered before and ErrSessionClosed once the session is over.
+func AnswerQuizQuestion(db *sql.DB, sessionID, quizID, position, option int, details ReviewDetails) (*QuizQuestion, *WordReviewItem, error) {
+	if err := requireActiveSession(db, sessionID); err != nil {
+		return nil, nil, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	q, err := scanQuizQuestion(tx.QueryRow(`
+		SELECT `+quizQuestionColumns+`
+		FROM quiz_questions
+		WHERE quiz_id = (SELECT id FROM quizzes WHERE id = ? AND study_session_id = ?) AND position = ?`,
+		quizID, sessionID, position))
+	if err != nil {
+		return nil, nil, err
+	}
+	if q.SelectedOption != nil {
+		return nil, nil, ErrQuestionAnswered
+	}
+	if option < 0 || option >= len(q.Options) {
+		return nil, nil, &ValidationError{Field: "option", Message: fmt.Sprintf("must be between 0 and %d", len(q.Options)-1)}
+	}
+
+	// Claim the question before reviewing so a concurrent answer loses
+	result, err := tx.Exec(`
+		UPDATE quiz_questions
+		SET selected_option = ?
+		WHERE quiz_id = ? AND position = ? AND selected_option IS NULL`,
+		option, quizID, position)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := requireAffected(result); err != nil {
+		return nil, nil, ErrQuestionAnswered
+	}
+
+	correct := option == q.correct
+	details.Answer, details.ExpectedAnswer = q.Options[option], q.Options[q.correct]
+	review := ReviewInput{WordID: q.WordID, Correct: &correct, ReviewDetails: details}
+	now := time.Now().UTC()
+	if err := review.normalize(now); err != nil {
+		return nil, nil, err
+	}
+	reviewID, err := insertReview(tx, sessionID, review, now)
+	if err != nil {
+		return nil, nil, err
+	}
+	_, err = tx.Exec("UPDATE quiz_questions SET review_id = ? WHERE quiz_id = ? AND position = ?", reviewID, quizID, position)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, nil, err
+	}
+
+	stored, err := scanReview(db.QueryRow("SELECT "+reviewColumns+" FROM word_review_items WHERE id = ?", reviewID))
+	if err != nil {
+		return nil, nil, err
+	}
+	q.SelectedOption, q.ReviewID = &option, &stored.ID
+	q.CorrectOption = &q.correct
+	return q, stored, nil
+}
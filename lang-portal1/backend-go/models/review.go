@@ -0,0 +1,287 @@
+package models
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// passingQuality is the lowest quality grade counted as a correct answer
+const passingQuality = 3
+
+// maxAnswerLength bounds the answers stored with a review
+const maxAnswerLength = 1000
+
+// ReviewDetails describe how a word was answered
+type ReviewDetails struct {
+	// Answer is what the learner submitted, ExpectedAnswer what was asked for
+	Answer         string `json:"answer,omitempty"`
+	ExpectedAnswer string `json:"expected_answer,omitempty"`
+	ResponseTimeMs *int   `json:"response_time_ms,omitempty"`
+	HintUsed       bool   `json:"hint_used"`
+	// Quality grades the answer from 0 (blackout) to 5 (perfect)
+	Quality *int `json:"quality,omitempty"`
+	// ClientApp identifies the study app that recorded the review
+	ClientApp string `json:"client_app,omitempty"`
+}
+
+type WordReviewItem struct {
+	ID             int       `json:"id"`
+	WordID         int       `json:"word_id"`
+	StudySessionID int       `json:"study_session_id"`
+	AttemptNumber  int       `json:"attempt_number"`
+	Correct        bool      `json:"correct"`
+	CreatedAt      time.Time `json:"created_at"`
+	ClientID       string    `json:"client_id,omitempty"`
+	ReviewDetails
+}
+
+// ReviewInput is a review recorded by a client, possibly while offline
+type ReviewInput struct {
+	WordID int
+	// Correct may be left out when a Quality is given
+	Correct *bool
+	// ReviewedAt defaults to the time the review is received
+	ReviewedAt *time.Time
+	// ClientID identifies the review on the client so that replays are
+	// recorded once
+	ClientID string
+	ReviewDetails
+}
+
+// normalize validates a review, deriving whether it was correct from its
+// quality when needed
+func (r *ReviewInput) normalize(now time.Time) error {
+	r.Answer = strings.TrimSpace(r.Answer)
+	r.ExpectedAnswer = strings.TrimSpace(r.ExpectedAnswer)
+	r.ClientApp = strings.TrimSpace(r.ClientApp)
+
+	if r.Quality != nil && (*r.Quality < 0 || *r.Quality > 5) {
+		return &ValidationError{Field: "quality", Message: "must be between 0 and 5"}
+	}
+	if r.Correct == nil {
+		if r.Quality == nil {
+			return &ValidationError{Field: "correct", Message: "is required without a quality"}
+		}
+		correct := *r.Quality >= passingQuality
+		r.Correct = &correct
+	}
+	if r.ResponseTimeMs != nil && *r.ResponseTimeMs < 0 {
+		return &ValidationError{Field: "response_time_ms", Message: "must not be negative"}
+	}
+	if len(r.Answer) > maxAnswerLength || len(r.ExpectedAnswer) > maxAnswerLength {
+		return &ValidationError{Field: "answer", Message: "is too long"}
+	}
+	if len(r.ClientApp) > 64 {
+		return &ValidationError{Field: "client_app", Message: "must be at most 64 characters"}
+	}
+	if r.ReviewedAt != nil && r.ReviewedAt.After(now.Add(reviewClockSkew)) {
+		return &ValidationError{Field: "reviewed_at", Message: "is in the future"}
+	}
+	return nil
+}
+
+// reviewColumns lists the columns scanned by scanReview
+const reviewColumns = `id, word_id, study_session_id, attempt_number, correct, created_at, client_id,
+	answer, expected_answer, response_time_ms, hint_used, quality, client_app`
+
+func scanReview(row rowScanner) (*WordReviewItem, error) {
+	var r WordReviewItem
+	var createdAt sqliteTime
+	var clientID, answer, expected, clientApp sql.NullString
+	var responseTime, quality sql.NullInt64
+	err := row.Scan(&r.ID, &r.WordID, &r.StudySessionID, &r.AttemptNumber, &r.Correct, &createdAt, &clientID,
+		&answer, &expected, &responseTime, &r.HintUsed, &quality, &clientApp)
+	if err != nil {
+		return nil, err
+	}
+	r.CreatedAt = createdAt.Time
+	r.ClientID = clientID.String
+	r.Answer, r.ExpectedAnswer, r.ClientApp = answer.String, expected.String, clientApp.String
+	r.ResponseTimeMs = nullableInt(responseTime)
+	r.Quality = nullableInt(quality)
+	return &r, nil
+}
+
+func nullableInt(n sql.NullInt64) *int {
+	if !n.Valid {
+		return nil
+	}
+	v := int(n.Int64)
+	return &v
+}
+
+func nullableFloat(f sql.NullFloat64) *float64 {
+	if !f.Valid {
+		return nil
+	}
+	return &f.Float64
+}
+
+// AddWordReview adds a word review to an active study session and returns
+// it as stored. Repeated reviews of a word are recorded as further
+// attempts.
+func AddWordReview(db *sql.DB, sessionID int, review ReviewInput) (*WordReviewItem, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := requireActiveSession(tx, sessionID); err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	if err := review.normalize(now); err != nil {
+		return nil, err
+	}
+	var known bool
+	if err := tx.QueryRow("SELECT EXISTS(SELECT 1 FROM words WHERE id = ?)", review.WordID).Scan(&known); err != nil {
+		return nil, err
+	}
+	if !known {
+		return nil, &ValidationError{Field: "word_id", Message: "unknown word"}
+	}
+
+	id, err := insertReview(tx, sessionID, review, now)
+	if err != nil {
+		return nil, err
+	}
+	stored, err := scanReview(tx.QueryRow("SELECT "+reviewColumns+" FROM word_review_items WHERE id = ?", id))
+	if err != nil {
+		return nil, err
+	}
+	return stored, tx.Commit()
+}
+
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// insertReview records a normalized review as the next attempt at its word
+// in a session, stamped now unless the client sent an earlier time, and
+// reschedules the word. Words becoming leeches are suspended if the policy
+// says so.
+func insertReview(db querier, sessionID int, review ReviewInput, now time.Time) (int64, error) {
+	wasLeech, err := isLeech(db, review.WordID)
+	if err != nil {
+		return 0, err
+	}
+
+	// Client times within the clock skew of now are not recorded as future
+	reviewedAt := now
+	if review.ReviewedAt != nil && review.ReviewedAt.Before(now) {
+		reviewedAt = review.ReviewedAt.UTC()
+	}
+	// Attempts are numbered by when they were made, so a review made before
+	// others already recorded moves them one attempt later
+	reviewedAtText := reviewedAt.Format(reviewTimeFormat)
+	if err := shiftAttempts(db, sessionID, review.WordID, reviewedAtText, 1); err != nil {
+		return 0, err
+	}
+	result, err := db.Exec(`
+		INSERT INTO word_review_items (
+			word_id, study_session_id, attempt_number, correct, created_at, client_id,
+			answer, expected_answer, response_time_ms, hint_used, quality, client_app
+		)
+		VALUES (?, ?, (
+			SELECT COUNT(*) + 1
+			FROM word_review_items
+			WHERE word_id = ? AND study_session_id = ? AND created_at <= ?
+		), ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		review.WordID, sessionID, review.WordID, sessionID, reviewedAtText, *review.Correct, reviewedAtText,
+		nullableString(review.ClientID), nullableString(review.Answer), nullableString(review.ExpectedAnswer),
+		review.ResponseTimeMs, review.HintUsed, review.Quality, nullableString(review.ClientApp))
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	if err := scheduleReview(db, id); err != nil {
+		return 0, err
+	}
+	if leechPolicy.AutoSuspend && !wasLeech {
+		if err := suspendLeech(db, review.WordID, id, now); err != nil {
+			return 0, err
+		}
+	}
+	return id, nil
+}
+
+// shiftAttempts renumbers the attempts at a word in a session made after
+// reviewedAt by delta
+func shiftAttempts(db execer, sessionID, wordID int, reviewedAt string, delta int) error {
+	// Negate the numbers while they are rewritten to keep them unique
+	_, err := db.Exec(`
+		UPDATE word_review_items
+		SET attempt_number = -(attempt_number + ?)
+		WHERE study_session_id = ? AND word_id = ? AND created_at > ?`,
+		delta, sessionID, wordID, reviewedAt)
+	if err != nil {
+		return err
+	}
+	return flipAttempts(db, sessionID, wordID)
+}
+
+// flipAttempts restores the attempt numbers negated by a renumbering
+func flipAttempts(db execer, sessionID, wordID int) error {
+	_, err := db.Exec(`
+		UPDATE word_review_items
+		SET attempt_number = -attempt_number
+		WHERE study_session_id = ? AND word_id = ? AND attempt_number < 0`,
+		sessionID, wordID)
+	return err
+}
+
+// DeleteLastReview undoes the most recently recorded review of an active
+// session and returns it, along with its effects on the word's schedule,
+// failure counts and leech suspension. It returns sql.ErrNoRows if there is
+// none.
+func DeleteLastReview(db *sql.DB, sessionID int) (*WordReviewItem, error) {
+	if err := requireActiveSession(db, sessionID); err != nil {
+		return nil, err
+	}
+
+	review, err := scanReview(db.QueryRow(`
+		SELECT `+reviewColumns+`
+		FROM word_review_items
+		WHERE study_session_id = ?
+		ORDER BY id DESC
+		LIMIT 1`,
+		sessionID))
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM word_review_items WHERE id = ?", review.ID); err != nil {
+		return nil, err
+	}
+	// Attempts made after the undone one, recorded before it, move up
+	if _, err := tx.Exec(`
+		UPDATE word_review_items
+		SET attempt_number = -(attempt_number - 1)
+		WHERE study_session_id = ? AND word_id = ? AND attempt_number > ?`,
+		sessionID, review.WordID, review.AttemptNumber); err != nil {
+		return nil, err
+	}
+	if err := flipAttempts(tx, sessionID, review.WordID); err != nil {
+		return nil, err
+	}
+	// Replaying the history restores the failure counts, and a suspension
+	// the review caused is lifted with it
+	if err := rescheduleWord(tx, review.WordID); err != nil {
+		return nil, err
+	}
+	if err := unsuspendLeech(tx, review.WordID, review.ID); err != nil {
+		return nil, err
+	}
+	return review, tx.Commit()
+}
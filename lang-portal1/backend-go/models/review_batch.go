@@ -0,0 +1,161 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Statuses of the items of a review batch
+const (
+	ReviewCreated   = "created"
+	ReviewDuplicate = "duplicate"
+	ReviewInvalid   = "invalid"
+	// ReviewSkipped items were valid but not recorded because others were not
+	ReviewSkipped = "skipped"
+)
+
+// MaxReviewBatch is the largest number of reviews accepted in one batch
+const MaxReviewBatch = 500
+
+// reviewClockSkew is how far in the future a client timestamp may be
+const reviewClockSkew = 5 * time.Minute
+
+// reviewTimeFormat matches CURRENT_TIMESTAMP so that client and server
+// timestamps sort together
+const reviewTimeFormat = "2006-01-02 15:04:05"
+
+var ErrInvalidBatch = errors.New("review batch has invalid items")
+
+type ReviewResult struct {
+	Index    int    `json:"index"`
+	ClientID string `json:"client_id,omitempty"`
+	WordID   int    `json:"word_id"`
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+}
+
+type ReviewBatchResult struct {
+	SessionID  int            `json:"session_id"`
+	Created    int            `json:"created"`
+	Duplicates int            `json:"duplicates"`
+	Invalid    int            `json:"invalid"`
+	Results    []ReviewResult `json:"results"`
+}
+
+// AddWordReviews records a batch of reviews in a study session. Batches are
+// often sent after studying offline, so they are accepted for sessions that
+// were completed in the meantime, as long as every review carries the time
+// it was made; abandoned sessions return ErrSessionClosed. Review times may
+// not be before the session started nor in the future. The batch is applied
+// atomically: when an item is invalid nothing is recorded and
+// ErrInvalidBatch is returned along with the per-item results. Items whose
+// client ID was already recorded are reported as duplicates.
+func AddWordReviews(db *sql.DB, sessionID int, reviews []ReviewInput) (*ReviewBatchResult, error) {
+	if len(reviews) == 0 {
+		return nil, &ValidationError{Field: "reviews", Message: "must not be empty"}
+	}
+	if len(reviews) > MaxReviewBatch {
+		return nil, &ValidationError{Field: "reviews", Message: fmt.Sprintf("must have at most %d items", MaxReviewBatch)}
+	}
+	var status string
+	var started sqliteTime
+	err := db.QueryRow("SELECT status, created_at FROM study_sessions WHERE id = ?", sessionID).Scan(&status, &started)
+	if err != nil {
+		return nil, err
+	}
+	if status == SessionAbandoned {
+		return nil, ErrSessionClosed
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	batch := &ReviewBatchResult{SessionID: sessionID, Results: make([]ReviewResult, len(reviews))}
+	now := time.Now().UTC()
+	clientIDs := make(map[string]bool)
+	for i := range reviews {
+		review := &reviews[i]
+		result := &batch.Results[i]
+		*result = ReviewResult{Index: i, ClientID: review.ClientID, WordID: review.WordID, Status: ReviewCreated}
+
+		if review.ClientID != "" {
+			duplicate := clientIDs[review.ClientID]
+			if !duplicate {
+				err := tx.QueryRow(`
+					SELECT EXISTS(SELECT 1 FROM word_review_items WHERE study_session_id = ? AND client_id = ?)`,
+					sessionID, review.ClientID).Scan(&duplicate)
+				if err != nil {
+					return nil, err
+				}
+			}
+			if duplicate {
+				result.Status = ReviewDuplicate
+				batch.Duplicates++
+				continue
+			}
+			clientIDs[review.ClientID] = true
+		}
+
+		if message, err := checkReview(tx, review, status, started.Time, now); err != nil {
+			return nil, err
+		} else if message != "" {
+			result.Status, result.Error = ReviewInvalid, message
+			batch.Invalid++
+			continue
+		}
+
+		if _, err := insertReview(tx, sessionID, *review, now); err != nil {
+			return nil, err
+		}
+		batch.Created++
+	}
+
+	if batch.Invalid > 0 {
+		batch.Created = 0
+		for i := range batch.Results {
+			if batch.Results[i].Status == ReviewCreated {
+				batch.Results[i].Status = ReviewSkipped
+			}
+		}
+		return batch, ErrInvalidBatch
+	}
+	return batch, tx.Commit()
+}
+
+// checkReview normalizes a review for a session in the given status,
+// started at started, and returns why it cannot be recorded, or "" if it
+// can. Times within the clock skew before the session started are moved to
+// its start.
+func checkReview(tx *sql.Tx, review *ReviewInput, status string, started, now time.Time) (string, error) {
+	if err := review.normalize(now); err != nil {
+		var validationErr *ValidationError
+		if errors.As(err, &validationErr) {
+			return validationErr.Error(), nil
+		}
+		return "", err
+	}
+	if review.ReviewedAt == nil && status != SessionActive {
+		return (&ValidationError{Field: "reviewed_at", Message: "is required once the session is over"}).Error(), nil
+	}
+	if review.ReviewedAt != nil && review.ReviewedAt.Before(started) {
+		if review.ReviewedAt.Before(started.Add(-reviewClockSkew)) {
+			return (&ValidationError{Field: "reviewed_at", Message: "is before the session started"}).Error(), nil
+		}
+		review.ReviewedAt = &started
+	}
+
+	var exists bool
+	err := tx.QueryRow("SELECT EXISTS(SELECT 1 FROM words WHERE id = ?)", review.WordID).Scan(&exists)
+	if err != nil {
+		return "", err
+	}
+	if !exists {
+		return "unknown word", nil
+	}
+	return "", nil
+}
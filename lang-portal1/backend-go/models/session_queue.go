@@ -0,0 +1,282 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// Word queue strategies
+const (
+	// QueueGroupOrder lists the words in the order they were added
+	QueueGroupOrder   = "group"
+	QueueNewFirst     = "new_first"
+	QueueWeakestFirst = "weakest_first"
+	QueueDueFirst     = "due_first"
+	// QueueMixed interleaves due, new and weak words by QueueMix weights
+	QueueMixed  = "mixed"
+	QueueRandom = "random"
+)
+
+// MaxQueueSize is the longest queue stored for a session
+const MaxQueueSize = 500
+
+// QueueMix weighs the due, new and weak (reviewed, not yet due) words of a
+// mixed queue
+type QueueMix struct {
+	Due  float64 `json:"due"`
+	New  float64 `json:"new"`
+	Weak float64 `json:"weak"`
+}
+
+var DefaultQueueMix = QueueMix{Due: 0.5, New: 0.3, Weak: 0.2}
+
+// QueueSpec describes how the word queue of a session is built
+type QueueSpec struct {
+	Strategy string
+	// Size caps the queue, 0 for all the words of the session
+	Size int
+	// Seed breaks ties and shuffles, so that a queue can be rebuilt. A
+	// random seed is picked when it is nil.
+	Seed *int64
+	Mix  QueueMix
+}
+
+// Normalize fills in the defaults and validates the spec. A size of 0, or
+// no size, queues the whole target up to MaxQueueSize words; larger targets
+// are cut to the first MaxQueueSize words of the strategy's order.
+func (s *QueueSpec) Normalize() error {
+	if s.Strategy == "" {
+		s.Strategy = QueueGroupOrder
+	}
+	switch s.Strategy {
+	case QueueGroupOrder, QueueNewFirst, QueueWeakestFirst, QueueDueFirst, QueueMixed, QueueRandom:
+	default:
+		return &ValidationError{Field: "strategy", Message: "must be group, new_first, weakest_first, due_first, mixed or random"}
+	}
+	if s.Size < 0 || s.Size > MaxQueueSize {
+		return &ValidationError{Field: "size", Message: fmt.Sprintf("must be between 0 and %d", MaxQueueSize)}
+	}
+	if s.Size == 0 {
+		s.Size = MaxQueueSize
+	}
+	if s.Mix.Due < 0 || s.Mix.New < 0 || s.Mix.Weak < 0 {
+		return &ValidationError{Field: "mix", Message: "weights must not be negative"}
+	}
+	if s.Mix == (QueueMix{}) {
+		s.Mix = DefaultQueueMix
+	}
+	if s.Seed == nil {
+		seed := rand.Int63()
+		s.Seed = &seed
+	}
+	return nil
+}
+
+type QueueItem struct {
+	Position int `json:"position"`
+	// Reviewed tells whether the word was reviewed in the session yet
+	Reviewed bool `json:"reviewed"`
+	Word
+}
+
+type SessionQueue struct {
+	SessionID int         `json:"session_id"`
+	Strategy  *string     `json:"strategy"`
+	Seed      *int64      `json:"seed"`
+	Size      int         `json:"size"`
+	Remaining int         `json:"remaining"`
+	Items     []QueueItem `json:"items"`
+}
+
+// queueWord is what the strategies know about a word
+type queueWord struct {
+	id       int
+	reviews  int
+	correct  int
+	due      time.Time
+	tiebreak float64
+}
+
+func (w queueWord) accuracy() float64 {
+	return float64(w.correct) / float64(w.reviews)
+}
+
+func sortQueueWords(words []queueWord, less func(a, b queueWord) bool) []queueWord {
+	sort.SliceStable(words, func(i, j int) bool { return less(words[i], words[j]) })
+	return words
+}
+
+func byTiebreak(a, b queueWord) bool { return a.tiebreak < b.tiebreak }
+
+func byDue(a, b queueWord) bool {
+	if !a.due.Equal(b.due) {
+		return a.due.Before(b.due)
+	}
+	return a.tiebreak < b.tiebreak
+}
+
+// byWeakness puts the lowest first-attempt accuracy first, then the most
+// failures
+func byWeakness(a, b queueWord) bool {
+	if a.accuracy() != b.accuracy() {
+		return a.accuracy() < b.accuracy()
+	}
+	if fa, fb := a.reviews-a.correct, b.reviews-b.correct; fa != fb {
+		return fa > fb
+	}
+	return a.tiebreak < b.tiebreak
+}
+
+// interleave takes words from the pools in proportion to their weights,
+// always from the pool furthest behind its share
+func interleave(pools [][]queueWord, weights []float64) []queueWord {
+	var queue []queueWord
+	taken := make([]int, len(pools))
+	for {
+		pick := -1
+		var bestDeficit float64
+		for i, pool := range pools {
+			if taken[i] == len(pool) {
+				continue
+			}
+			deficit := weights[i]*float64(len(queue)+1) - float64(taken[i])
+			if pick == -1 || deficit > bestDeficit {
+				pick, bestDeficit = i, deficit
+			}
+		}
+		if pick == -1 {
+			return queue
+		}
+		queue = append(queue, pools[pick][taken[pick]])
+		taken[pick]++
+	}
+}
+
+// buildQueue orders the words selected by members that are not suspended
+// by a strategy. Due words are reviewed words scheduled by now, new words
+// have never been reviewed and weak words are the other reviewed ones.
+func buildQueue(db querier, members string, args []interface{}, spec QueueSpec, now time.Time) ([]int, error) {
+	rows, err := db.Query(`
+		SELECT w.id, s.due_at, COALESCE(r.reviews, 0), COALESCE(r.correct, 0)
+		FROM words w
+		LEFT JOIN word_srs_state s ON s.word_id = w.id
+		LEFT JOIN (
+			SELECT word_id, COUNT(*) AS reviews, SUM(CASE WHEN correct THEN 1 ELSE 0 END) AS correct
+			FROM first_word_review_items
+			GROUP BY word_id
+		) r ON r.word_id = w.id
+		WHERE w.id IN (`+members+`) AND w.suspended_at IS NULL
+		ORDER BY w.id`,
+		args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rng := rand.New(rand.NewSource(*spec.Seed))
+	var all, due, fresh, weak []queueWord
+	for rows.Next() {
+		var w queueWord
+		var dueAt sqliteTime
+		if err := rows.Scan(&w.id, &dueAt, &w.reviews, &w.correct); err != nil {
+			return nil, err
+		}
+		w.due = dueAt.Time
+		w.tiebreak = rng.Float64()
+		all = append(all, w)
+		switch {
+		case w.reviews == 0:
+			fresh = append(fresh, w)
+		case dueAt.Valid && !w.due.After(now):
+			due = append(due, w)
+		default:
+			weak = append(weak, w)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var queue []queueWord
+	switch spec.Strategy {
+	case QueueGroupOrder:
+		queue = all
+	case QueueRandom:
+		queue = sortQueueWords(all, byTiebreak)
+	case QueueNewFirst:
+		queue = append(fresh, sortQueueWords(due, byDue)...)
+		queue = append(queue, sortQueueWords(weak, byWeakness)...)
+	case QueueWeakestFirst:
+		queue = sortQueueWords(append(due, weak...), byWeakness)
+		queue = append(queue, fresh...)
+	case QueueDueFirst:
+		queue = append(sortQueueWords(due, byDue), fresh...)
+		queue = append(queue, sortQueueWords(weak, byDue)...)
+	case QueueMixed:
+		queue = interleave(
+			[][]queueWord{sortQueueWords(due, byDue), fresh, sortQueueWords(weak, byWeakness)},
+			[]float64{spec.Mix.Due, spec.Mix.New, spec.Mix.Weak})
+	}
+
+	if len(queue) > spec.Size {
+		queue = queue[:spec.Size]
+	}
+	wordIDs := make([]int, len(queue))
+	for i, w := range queue {
+		wordIDs[i] = w.id
+	}
+	return wordIDs, nil
+}
+
+// GetSessionQueue retrieves the word queue of a study session. Sessions
+// started before queues were stored have an empty queue and no strategy. It
+// returns sql.ErrNoRows for unknown sessions.
+func GetSessionQueue(db *sql.DB, sessionID int) (*SessionQueue, error) {
+	queue := &SessionQueue{SessionID: sessionID, Items: []QueueItem{}}
+	var strategy sql.NullString
+	var seed sql.NullInt64
+	err := db.QueryRow("SELECT strategy, queue_seed FROM study_sessions WHERE id = ?", sessionID).Scan(&strategy, &seed)
+	if err != nil {
+		return nil, err
+	}
+	if strategy.Valid {
+		queue.Strategy = &strategy.String
+	}
+	if seed.Valid {
+		queue.Seed = &seed.Int64
+	}
+
+	rows, err := db.Query(`
+		SELECT q.position,
+			EXISTS(SELECT 1 FROM word_review_items WHERE study_session_id = q.study_session_id AND word_id = q.word_id),
+			`+wordColumns+`
+		FROM study_session_queue q
+		JOIN words w ON w.id = q.word_id
+		WHERE q.study_session_id = ?
+		ORDER BY q.position`,
+		sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item QueueItem
+		word, err := scanWord(rowFunc(func(dest ...interface{}) error {
+			return rows.Scan(append([]interface{}{&item.Position, &item.Reviewed}, dest...)...)
+		}))
+		if err != nil {
+			return nil, err
+		}
+		item.Word = *word
+		queue.Items = append(queue.Items, item)
+		if !item.Reviewed {
+			queue.Remaining++
+		}
+	}
+	queue.Size = len(queue.Items)
+	return queue, rows.Err()
+}
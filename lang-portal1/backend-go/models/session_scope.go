@@ -0,0 +1,205 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// Session scopes, what the words of a study session are drawn from
+const (
+	ScopeGroup  = "group"
+	ScopeGroups = "groups"
+	ScopeWords  = "words"
+	ScopeQuery  = "query"
+)
+
+// SessionTarget picks the words of a new study session: one or more
+// groups, a list of words or a search query. Exactly one of them is set.
+type SessionTarget struct {
+	GroupIDs []int
+	WordIDs  []int
+	Query    *SmartFilter
+}
+
+// SessionScope describes what a study session covers. GroupIDs lists the
+// groups of group sessions and Query the filter of query sessions.
+type SessionScope struct {
+	Scope    string       `json:"scope"`
+	GroupIDs []int        `json:"group_ids"`
+	Query    *SmartFilter `json:"query,omitempty"`
+}
+
+// sessionScopeColumns lists the columns scanned into a sessionScopeDest, for
+// sessions aliased as ss
+const sessionScopeColumns = `ss.scope,
+			(SELECT GROUP_CONCAT(group_id) FROM study_session_groups WHERE study_session_id = ss.id),
+			ss.query`
+
+// sessionGroupNames names the groups of a session aliased as ss, joined by
+// commas, or empty for word list and query sessions
+const sessionGroupNames = `COALESCE((
+				SELECT GROUP_CONCAT(gn.name, ', ')
+				FROM study_session_groups sgn
+				JOIN groups gn ON gn.id = sgn.group_id
+				WHERE sgn.study_session_id = ss.id
+			), '')`
+
+// sessionScopeDest holds the columns of sessionScopeColumns until they are
+// decoded into a SessionScope
+type sessionScopeDest struct {
+	scope    string
+	groupIDs sql.NullString
+	query    sql.NullString
+}
+
+func (d *sessionScopeDest) fields() []interface{} {
+	return []interface{}{&d.scope, &d.groupIDs, &d.query}
+}
+
+func (d *sessionScopeDest) decode() (SessionScope, error) {
+	scope := SessionScope{Scope: d.scope, GroupIDs: []int{}}
+	if d.groupIDs.Valid {
+		for _, s := range strings.Split(d.groupIDs.String, ",") {
+			id, err := strconv.Atoi(s)
+			if err != nil {
+				return scope, err
+			}
+			scope.GroupIDs = append(scope.GroupIDs, id)
+		}
+	}
+	if d.query.Valid {
+		scope.Query = &SmartFilter{}
+		if err := json.Unmarshal([]byte(d.query.String), scope.Query); err != nil {
+			return scope, err
+		}
+	}
+	return scope, nil
+}
+
+// sessionSource selects the words of a new session drawn from one group,
+// or from no group for word list and query sessions
+type sessionSource struct {
+	groupID *int
+	members string
+	args    []interface{}
+}
+
+// resolvedTarget is a validated SessionTarget
+type resolvedTarget struct {
+	scope    string
+	groupIDs []int
+	// members selects every word of the target, for use as `w.id IN (...)`
+	members string
+	args    []interface{}
+	// sources are recorded in study_session_words; single-group sessions
+	// read their group instead
+	sources []sessionSource
+}
+
+// resolve validates the target and compiles the queries selecting its
+// words. It returns sql.ErrNoRows for unknown groups.
+func (t SessionTarget) resolve(db *sql.DB) (*resolvedTarget, error) {
+	set := 0
+	for _, ok := range []bool{len(t.GroupIDs) > 0, len(t.WordIDs) > 0, t.Query != nil} {
+		if ok {
+			set++
+		}
+	}
+	if set != 1 {
+		return nil, &ValidationError{Field: "group_ids", Message: "exactly one of group_id, group_ids, word_ids or query is required"}
+	}
+
+	switch {
+	case len(t.GroupIDs) > 0:
+		groupIDs := uniqueIDs(t.GroupIDs)
+		target := &resolvedTarget{scope: ScopeGroup, groupIDs: groupIDs}
+		if len(groupIDs) > 1 {
+			target.scope = ScopeGroups
+		}
+		var queries []string
+		for i := range groupIDs {
+			members, args, err := groupMembers(db, groupIDs[i])
+			if err != nil {
+				return nil, err
+			}
+			queries = append(queries, members)
+			target.args = append(target.args, args...)
+			target.sources = append(target.sources, sessionSource{groupID: &groupIDs[i], members: members, args: args})
+		}
+		target.members = strings.Join(queries, " UNION ")
+		if target.scope == ScopeGroup {
+			target.sources = nil
+		}
+		return target, nil
+
+	case len(t.WordIDs) > 0:
+		wordIDs := uniqueIDs(t.WordIDs)
+		if err := checkWordsExist(db, wordIDs); err != nil {
+			return nil, err
+		}
+		members := "SELECT id FROM words WHERE id IN (" + placeholders(len(wordIDs)) + ")"
+		args := idArgs(wordIDs)
+		return &resolvedTarget{
+			scope:   ScopeWords,
+			members: members,
+			args:    args,
+			sources: []sessionSource{{members: members, args: args}},
+		}, nil
+
+	default:
+		if err := t.Query.validate(); err != nil {
+			return nil, err
+		}
+		members, args := t.Query.query()
+		return &resolvedTarget{
+			scope:   ScopeQuery,
+			members: members,
+			args:    args,
+			sources: []sessionSource{{members: members, args: args}},
+		}, nil
+	}
+}
+
+// recordSessionWords stores the words of a new session by the group they
+// are drawn from
+func recordSessionWords(tx *sql.Tx, sessionID int64, sources []sessionSource) error {
+	for _, source := range sources {
+		var groupID interface{}
+		if source.groupID != nil {
+			groupID = *source.groupID
+		}
+		_, err := tx.Exec(`
+			INSERT INTO study_session_words (study_session_id, word_id, group_id)
+			SELECT ?, w.id, ? FROM words w WHERE w.id IN (`+source.members+`)`,
+			append([]interface{}{sessionID, groupID}, source.args...)...)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sessionMembers returns a query selecting the IDs of the words of a
+// session, for use as `w.id IN (...)`. Single-group sessions read their
+// group and the others the words recorded when they started. The query is
+// empty if the group of the session has been deleted since. It returns
+// sql.ErrNoRows for unknown sessions.
+func sessionMembers(db *sql.DB, sessionID int) (string, []interface{}, error) {
+	var scope string
+	var groupID sql.NullInt64
+	err := db.QueryRow("SELECT scope, group_id FROM study_sessions WHERE id = ?", sessionID).Scan(&scope, &groupID)
+	if err != nil {
+		return "", nil, err
+	}
+	if scope != ScopeGroup {
+		return "SELECT word_id FROM study_session_words WHERE study_session_id = ?", []interface{}{sessionID}, nil
+	}
+
+	members, args, err := groupMembers(db, int(groupID.Int64))
+	if err == sql.ErrNoRows {
+		return "", nil, nil
+	}
+	return members, args, err
+}
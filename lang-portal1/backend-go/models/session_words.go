@@ -0,0 +1,145 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// SessionWord is a review of a word in a study session
+type SessionWord struct {
+	Word
+	AttemptNumber int  `json:"attempt_number"`
+	Correct       bool `json:"correct"`
+	// Result is OutcomeCorrect or OutcomeIncorrect
+	Result     string    `json:"result"`
+	ReviewedAt time.Time `json:"reviewed_at"`
+	ReviewDetails
+}
+
+// SessionSummary totals the reviews of a study session
+type SessionSummary struct {
+	CorrectCount      int      `json:"correct_count"`
+	WrongCount        int      `json:"wrong_count"`
+	Accuracy          *float64 `json:"accuracy"`
+	AvgResponseTimeMs *float64 `json:"avg_response_time_ms"`
+	AvgQuality        *float64 `json:"avg_quality"`
+	HintCount         int      `json:"hint_count"`
+	// UnreviewedWords are the words of the session's groups, word list or
+	// query without a review in the session, leaving out suspended words
+	UnreviewedWords []Word `json:"unreviewed_words"`
+}
+
+// GetSessionWords retrieves a page of the reviews of a study session, oldest
+// first. It returns sql.ErrNoRows for unknown sessions.
+func GetSessionWords(db *sql.DB, sessionID int, page, perPage int) ([]SessionWord, int, error) {
+	offset := (page - 1) * perPage
+
+	var total int
+	err := db.QueryRow(`
+		SELECT COUNT(wri.word_id)
+		FROM study_sessions ss
+		LEFT JOIN word_review_items wri ON wri.study_session_id = ss.id
+		WHERE ss.id = ?
+		GROUP BY ss.id`,
+		sessionID).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := db.Query(`
+		SELECT `+wordColumns+`, wri.attempt_number, wri.correct, wri.created_at,
+			wri.answer, wri.expected_answer, wri.response_time_ms, wri.hint_used, wri.quality, wri.client_app
+		FROM word_review_items wri
+		JOIN words w ON w.id = wri.word_id
+		WHERE wri.study_session_id = ?
+		ORDER BY wri.created_at, wri.id
+		LIMIT ? OFFSET ?`,
+		sessionID, perPage, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	words := []SessionWord{}
+	for rows.Next() {
+		var sw SessionWord
+		var reviewedAt sqliteTime
+		var answer, expected, clientApp sql.NullString
+		var responseTime, quality sql.NullInt64
+		word, err := scanWord(rowFunc(func(dest ...interface{}) error {
+			return rows.Scan(append(dest, &sw.AttemptNumber, &sw.Correct, &reviewedAt,
+				&answer, &expected, &responseTime, &sw.HintUsed, &quality, &clientApp)...)
+		}))
+		if err != nil {
+			return nil, 0, err
+		}
+		sw.Word = *word
+		sw.ReviewedAt = reviewedAt.Time
+		sw.Answer, sw.ExpectedAnswer, sw.ClientApp = answer.String, expected.String, clientApp.String
+		sw.ResponseTimeMs = nullableInt(responseTime)
+		sw.Quality = nullableInt(quality)
+		sw.Result = OutcomeIncorrect
+		if sw.Correct {
+			sw.Result = OutcomeCorrect
+		}
+		words = append(words, sw)
+	}
+
+	return words, total, rows.Err()
+}
+
+// GetSessionSummary totals the reviews of a study session, counting every
+// attempt or only the first at each word. It returns sql.ErrNoRows for
+// unknown sessions.
+func GetSessionSummary(db *sql.DB, sessionID int, attempts Attempts) (*SessionSummary, error) {
+	members, args, err := sessionMembers(db, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &SessionSummary{UnreviewedWords: []Word{}}
+	var avgResponseTime, avgQuality sql.NullFloat64
+	err = db.QueryRow(`
+		SELECT
+			COALESCE(SUM(CASE WHEN correct THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN correct THEN 0 ELSE 1 END), 0),
+			AVG(response_time_ms),
+			AVG(quality),
+			COALESCE(SUM(CASE WHEN hint_used THEN 1 ELSE 0 END), 0)
+		FROM `+attempts.reviews()+`
+		WHERE study_session_id = ?`,
+		sessionID).Scan(&summary.CorrectCount, &summary.WrongCount, &avgResponseTime, &avgQuality, &summary.HintCount)
+	if err != nil {
+		return nil, err
+	}
+	summary.AvgResponseTimeMs = nullableFloat(avgResponseTime)
+	summary.AvgQuality = nullableFloat(avgQuality)
+	if reviews := summary.CorrectCount + summary.WrongCount; reviews > 0 {
+		accuracy := 100 * float64(summary.CorrectCount) / float64(reviews)
+		summary.Accuracy = &accuracy
+	}
+
+	if members == "" {
+		// The group has been deleted since
+		return summary, nil
+	}
+	rows, err := db.Query(`
+		SELECT `+wordColumns+`
+		FROM words w
+		WHERE w.id IN (`+members+`)
+			AND w.id NOT IN (SELECT word_id FROM word_review_items WHERE study_session_id = ?)
+			AND w.suspended_at IS NULL
+		ORDER BY w.id`,
+		append(args, sessionID)...)
+	if err != nil {
+		return nil, err
+	}
+	words, err := scanWords(rows)
+	if err != nil {
+		return nil, err
+	}
+	if words != nil {
+		summary.UnreviewedWords = words
+	}
+	return summary, nil
+}
@@ -0,0 +1,322 @@
+package models
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Group kinds. Manual groups list their words in words_groups, smart groups
+// select them with a SmartFilter each time the group is read.
+const (
+	GroupKindManual = "manual"
+	GroupKindSmart  = "smart"
+)
+
+var ErrSmartGroup = errors.New("smart group membership is defined by its filter")
+
+// SmartFilter selects the words of a smart group. Every field that is set
+// must match; an empty filter matches every word.
+type SmartFilter struct {
+	Language string `json:"language,omitempty"`
+	// Search matches the term, romanization or glosses
+	Search string `json:"search,omitempty"`
+
+	// PartOfSpeech matches words listing it among their parts of speech,
+	// as returned by Word.PartsOfSpeech
+	PartOfSpeech string `json:"part_of_speech,omitempty"`
+
+	// InGroups requires membership of all of these manual groups,
+	// NotInGroups of none of them
+	InGroups    []int `json:"in_groups,omitempty"`
+	NotInGroups []int `json:"not_in_groups,omitempty"`
+
+	// Studied matches words with (true) or without (false) any review
+	Studied *bool `json:"studied,omitempty"`
+
+	MinReviews  *int     `json:"min_reviews,omitempty"`
+	MaxReviews  *int     `json:"max_reviews,omitempty"`
+	MinCorrect  *int     `json:"min_correct,omitempty"`
+	MaxCorrect  *int     `json:"max_correct,omitempty"`
+	MinWrong    *int     `json:"min_wrong,omitempty"`
+	MaxWrong    *int     `json:"max_wrong,omitempty"`
+	MinAccuracy *float64 `json:"min_accuracy,omitempty"`
+	MaxAccuracy *float64 `json:"max_accuracy,omitempty"`
+
+	// ReviewedWithinDays matches words last reviewed in the past n days,
+	// NotReviewedWithinDays words that were not (including unstudied ones)
+	ReviewedWithinDays    *int `json:"reviewed_within_days,omitempty"`
+	NotReviewedWithinDays *int `json:"not_reviewed_within_days,omitempty"`
+}
+
+// ParseSmartFilter decodes a filter, rejecting unknown fields so that typos
+// do not silently widen the group
+func ParseSmartFilter(raw json.RawMessage) (*SmartFilter, error) {
+	var f SmartFilter
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&f); err != nil {
+		return nil, &ValidationError{Field: "filter", Message: err.Error()}
+	}
+	if err := f.validate(); err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+func (f *SmartFilter) validate() error {
+	f.Language = strings.ToLower(strings.TrimSpace(f.Language))
+	f.Search = strings.TrimSpace(f.Search)
+	f.PartOfSpeech = strings.ToLower(strings.TrimSpace(f.PartOfSpeech))
+
+	counts := map[string]*int{
+		"min_reviews": f.MinReviews, "max_reviews": f.MaxReviews,
+		"min_correct": f.MinCorrect, "max_correct": f.MaxCorrect,
+		"min_wrong": f.MinWrong, "max_wrong": f.MaxWrong,
+	}
+	for field, n := range counts {
+		if n != nil && *n < 0 {
+			return &ValidationError{Field: "filter." + field, Message: "must not be negative"}
+		}
+	}
+	for field, n := range map[string]*int{"reviewed_within_days": f.ReviewedWithinDays, "not_reviewed_within_days": f.NotReviewedWithinDays} {
+		if n != nil && *n < 1 {
+			return &ValidationError{Field: "filter." + field, Message: "must be at least 1"}
+		}
+	}
+	for field, p := range map[string]*float64{"min_accuracy": f.MinAccuracy, "max_accuracy": f.MaxAccuracy} {
+		if p != nil && (*p < 0 || *p > 100) {
+			return &ValidationError{Field: "filter." + field, Message: "must be between 0 and 100"}
+		}
+	}
+	return nil
+}
+
+// wordStatsJoin aggregates the reviews of every word, for joining as st
+const wordStatsJoin = `LEFT JOIN (
+			SELECT word_id,
+				COUNT(*) AS reviews,
+				SUM(CASE WHEN correct THEN 1 ELSE 0 END) AS correct,
+				MAX(created_at) AS last_reviewed_at
+			FROM word_review_items
+			GROUP BY word_id
+		) st ON st.word_id = sw.id`
+
+// query compiles the filter into a query selecting the matching word IDs
+func (f *SmartFilter) query() (string, []interface{}) {
+	var where []string
+	var args []interface{}
+	add := func(condition string, values ...interface{}) {
+		where = append(where, condition)
+		args = append(args, values...)
+	}
+
+	if f.Language != "" {
+		add("sw.language = ?", f.Language)
+	}
+	if f.Search != "" {
+		condition, searchArgs := searchCondition("sw", f.Search)
+		add(condition, searchArgs...)
+	}
+	if f.PartOfSpeech != "" {
+		// Part objects of kanji breakdowns name no part of speech
+		add(`EXISTS (
+			SELECT 1 FROM json_each(sw.parts) sp
+			WHERE sp.type = 'text' AND LOWER(TRIM(sp.value)) = ?
+		)`, f.PartOfSpeech)
+	}
+	for _, id := range f.InGroups {
+		add("EXISTS (SELECT 1 FROM words_groups sg WHERE sg.word_id = sw.id AND sg.group_id = ?)", id)
+	}
+	for _, id := range f.NotInGroups {
+		add("NOT EXISTS (SELECT 1 FROM words_groups sg WHERE sg.word_id = sw.id AND sg.group_id = ?)", id)
+	}
+	if f.Studied != nil {
+		if *f.Studied {
+			add("st.reviews > 0")
+		} else {
+			add("st.reviews IS NULL")
+		}
+	}
+
+	bounds := []struct {
+		expr string
+		op   string
+		n    *int
+	}{
+		{"COALESCE(st.reviews, 0)", ">=", f.MinReviews},
+		{"COALESCE(st.reviews, 0)", "<=", f.MaxReviews},
+		{"COALESCE(st.correct, 0)", ">=", f.MinCorrect},
+		{"COALESCE(st.correct, 0)", "<=", f.MaxCorrect},
+		{"COALESCE(st.reviews - st.correct, 0)", ">=", f.MinWrong},
+		{"COALESCE(st.reviews - st.correct, 0)", "<=", f.MaxWrong},
+	}
+	for _, b := range bounds {
+		if b.n != nil {
+			add(b.expr+" "+b.op+" ?", *b.n)
+		}
+	}
+
+	// Words without reviews have no accuracy and never match these
+	if f.MinAccuracy != nil {
+		add("100.0 * st.correct / st.reviews >= ?", *f.MinAccuracy)
+	}
+	if f.MaxAccuracy != nil {
+		add("100.0 * st.correct / st.reviews <= ?", *f.MaxAccuracy)
+	}
+
+	if f.ReviewedWithinDays != nil {
+		add("datetime(st.last_reviewed_at) >= datetime('now', ?)", fmt.Sprintf("-%d days", *f.ReviewedWithinDays))
+	}
+	if f.NotReviewedWithinDays != nil {
+		add("(st.last_reviewed_at IS NULL OR datetime(st.last_reviewed_at) < datetime('now', ?))", fmt.Sprintf("-%d days", *f.NotReviewedWithinDays))
+	}
+
+	if len(where) == 0 {
+		where = append(where, "1 = 1")
+	}
+	return `SELECT sw.id FROM words sw
+		` + wordStatsJoin + `
+		WHERE ` + strings.Join(where, " AND "), args
+}
+
+// groupMembers returns a query selecting the IDs of the words in a group,
+// for use as `w.id IN (...)`. Manual groups read words_groups and smart
+// groups run their filter. It returns sql.ErrNoRows for unknown groups.
+func groupMembers(db *sql.DB, groupID int) (string, []interface{}, error) {
+	var kind string
+	var filter sql.NullString
+	err := db.QueryRow("SELECT kind, filter FROM groups WHERE id = ?", groupID).Scan(&kind, &filter)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if kind != GroupKindSmart {
+		return "SELECT word_id FROM words_groups WHERE group_id = ?", []interface{}{groupID}, nil
+	}
+
+	var f SmartFilter
+	if filter.Valid {
+		if err := json.Unmarshal([]byte(filter.String), &f); err != nil {
+			return "", nil, err
+		}
+	}
+	query, args := f.query()
+	return query, args, nil
+}
+
+// smartGroupCounts returns a query counting the words of every smart group
+// as rows of (group_id, word_count), for joining in group lists
+func smartGroupCounts(db *sql.DB) (string, []interface{}, error) {
+	rows, err := db.Query("SELECT id, filter FROM groups WHERE kind = ?", GroupKindSmart)
+	if err != nil {
+		return "", nil, err
+	}
+	defer rows.Close()
+
+	queries := []string{"SELECT NULL AS group_id, 0 AS word_count WHERE 0"}
+	var args []interface{}
+	for rows.Next() {
+		var id int
+		var filter sql.NullString
+		if err := rows.Scan(&id, &filter); err != nil {
+			return "", nil, err
+		}
+		var f SmartFilter
+		if filter.Valid {
+			if err := json.Unmarshal([]byte(filter.String), &f); err != nil {
+				return "", nil, err
+			}
+		}
+		members, memberArgs := f.query()
+		queries = append(queries, "SELECT ?, COUNT(*) FROM ("+members+")")
+		args = append(append(args, id), memberArgs...)
+	}
+	if err := rows.Err(); err != nil {
+		return "", nil, err
+	}
+	return strings.Join(queries, " UNION ALL "), args, nil
+}
+
+// requireManualGroup fails with ErrSmartGroup for smart groups and with
+// sql.ErrNoRows for unknown groups
+func requireManualGroup(db *sql.DB, groupID int) error {
+	var kind string
+	err := db.QueryRow("SELECT kind FROM groups WHERE id = ?", groupID).Scan(&kind)
+	if err != nil {
+		return err
+	}
+	if kind == GroupKindSmart {
+		return ErrSmartGroup
+	}
+	return nil
+}
+
+// replaceFilterGroups rewrites the in_groups and not_in_groups of every smart
+// group filter that names one of the given groups, swapping in replacement or
+// dropping the ID when replacement is nil
+func replaceFilterGroups(tx *sql.Tx, groupIDs []int, replacement *int) error {
+	rows, err := tx.Query("SELECT id, filter FROM groups WHERE kind = ? AND filter IS NOT NULL", GroupKindSmart)
+	if err != nil {
+		return err
+	}
+	filters := make(map[int]*SmartFilter)
+	for rows.Next() {
+		var id int
+		var raw string
+		if err := rows.Scan(&id, &raw); err != nil {
+			rows.Close()
+			return err
+		}
+		var f SmartFilter
+		if err := json.Unmarshal([]byte(raw), &f); err != nil {
+			rows.Close()
+			return err
+		}
+		filters[id] = &f
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	replaced := make(map[int]bool, len(groupIDs))
+	for _, id := range groupIDs {
+		replaced[id] = true
+	}
+	replace := func(ids []int) ([]int, bool) {
+		changed := false
+		kept := make([]int, 0, len(ids))
+		for _, id := range ids {
+			if !replaced[id] {
+				kept = append(kept, id)
+				continue
+			}
+			changed = true
+			if replacement != nil {
+				kept = append(kept, *replacement)
+			}
+		}
+		return uniqueIDs(kept), changed
+	}
+
+	for id, f := range filters {
+		var inChanged, notInChanged bool
+		f.InGroups, inChanged = replace(f.InGroups)
+		f.NotInGroups, notInChanged = replace(f.NotInGroups)
+		if !inChanged && !notInChanged {
+			continue
+		}
+		filter, err := json.Marshal(f)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec("UPDATE groups SET filter = ? WHERE id = ?", string(filter), id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
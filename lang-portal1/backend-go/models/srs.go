@@ -0,0 +1,380 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+
+	"lang-portal/backend/srs"
+)
+
+// scheduler schedules the reviews of words
+var scheduler srs.Scheduler = srs.SM2{}
+
+// SetScheduler selects the algorithm words are scheduled with. States
+// stored by another algorithm are rebuilt by RescheduleStaleWords.
+func SetScheduler(s srs.Scheduler) {
+	scheduler = s
+}
+
+// WordSRS is the scheduling state of a word
+type WordSRS struct {
+	WordID    int    `json:"word_id"`
+	Algorithm string `json:"algorithm"`
+	srs.State
+}
+
+// DueWord is a word to review, with its scheduling state. New words have
+// none.
+type DueWord struct {
+	Word
+	SRS *WordSRS `json:"srs"`
+}
+
+type DueQuery struct {
+	// GroupID limits the queue to a group's words, 0 for all words
+	GroupID int
+	Limit   int
+	// New is how many never reviewed words may follow the due ones
+	New int
+}
+
+type DueReviews struct {
+	Items []DueWord `json:"items"`
+	// DueCount and NewCount are the totals, beyond the limit
+	DueCount int `json:"due_count"`
+	NewCount int `json:"new_count"`
+}
+
+type ForecastDay struct {
+	Date     string `json:"date"`
+	DueCount int    `json:"due_count"`
+}
+
+type ReviewForecast struct {
+	// OverdueCount are the words already due, counted in the first day
+	OverdueCount int           `json:"overdue_count"`
+	Days         []ForecastDay `json:"days"`
+}
+
+// reviewGrade is the 0-5 quality a review is scheduled by. Reviews without
+// a quality count as 4 when correct, 3 with a hint and 1 when wrong.
+func reviewGrade(correct bool, quality sql.NullInt64, hintUsed bool) int {
+	switch {
+	case quality.Valid:
+		return int(quality.Int64)
+	case !correct:
+		return 1
+	case hintUsed:
+		return passingQuality
+	default:
+		return 4
+	}
+}
+
+type querier interface {
+	execer
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// rescheduleWord replays the reviews of a word through the scheduler and
+// stores the resulting state along with the failure counts, so that reviews
+// recorded out of order or undone are accounted for. Only first attempts
+// count: retrying a word in the same session does not move its schedule.
+// New reviews usually go through the cheaper scheduleReview.
+func rescheduleWord(db querier, wordID int) error {
+	rows, err := db.Query(`
+		SELECT correct, quality, hint_used, created_at
+		FROM first_word_review_items
+		WHERE word_id = ?
+		ORDER BY created_at, id`,
+		wordID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var state srs.State
+	reviews, failures, consecutiveFailures := 0, 0, 0
+	for rows.Next() {
+		var correct, hintUsed bool
+		var quality sql.NullInt64
+		var reviewedAt sqliteTime
+		if err := rows.Scan(&correct, &quality, &hintUsed, &reviewedAt); err != nil {
+			return err
+		}
+		state = scheduler.Next(state, reviewGrade(correct, quality, hintUsed), reviewedAt.Time)
+		reviews++
+		if correct {
+			consecutiveFailures = 0
+		} else {
+			failures++
+			consecutiveFailures++
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	if reviews == 0 {
+		_, err := db.Exec("DELETE FROM word_srs_state WHERE word_id = ?", wordID)
+		return err
+	}
+	return storeSRSState(db, wordID, state, failures, consecutiveFailures)
+}
+
+// scheduleReview moves the stored state of a word forward by a newly
+// recorded review instead of replaying its whole history. Retries leave the
+// schedule alone. The history is replayed when the review is not simply the
+// latest first attempt: when it displaces the first attempt of its session,
+// is older than the last review scheduled, or the state is missing or was
+// stored by another algorithm.
+func scheduleReview(db querier, reviewID int64) error {
+	var wordID, sessionID, attempt int
+	var correct, hintUsed bool
+	var quality sql.NullInt64
+	var reviewedAt sqliteTime
+	err := db.QueryRow(`
+		SELECT word_id, study_session_id, attempt_number, correct, quality, hint_used, created_at
+		FROM word_review_items
+		WHERE id = ?`,
+		reviewID).Scan(&wordID, &sessionID, &attempt, &correct, &quality, &hintUsed, &reviewedAt)
+	if err != nil {
+		return err
+	}
+	if attempt != 1 {
+		return nil
+	}
+
+	var displaced bool
+	err = db.QueryRow(`
+		SELECT EXISTS(SELECT 1 FROM word_review_items WHERE study_session_id = ? AND word_id = ? AND id != ?)`,
+		sessionID, wordID, reviewID).Scan(&displaced)
+	if err != nil {
+		return err
+	}
+	if displaced {
+		return rescheduleWord(db, wordID)
+	}
+
+	var state srs.State
+	var algorithm string
+	var due, lastReview sqliteTime
+	var failures, consecutiveFailures int
+	err = db.QueryRow(`
+		SELECT algorithm, repetitions, interval_days, ease, stability, difficulty, lapses,
+			due_at, last_reviewed_at, failures, consecutive_failures
+		FROM word_srs_state
+		WHERE word_id = ?`,
+		wordID).Scan(&algorithm, &state.Repetitions, &state.IntervalDays, &state.Ease, &state.Stability,
+		&state.Difficulty, &state.Lapses, &due, &lastReview, &failures, &consecutiveFailures)
+	if err == sql.ErrNoRows || (err == nil && (algorithm != scheduler.Name() || reviewedAt.Time.Before(lastReview.Time))) {
+		return rescheduleWord(db, wordID)
+	}
+	if err != nil {
+		return err
+	}
+	state.Due, state.LastReview = due.Time, lastReview.Time
+
+	state = scheduler.Next(state, reviewGrade(correct, quality, hintUsed), reviewedAt.Time)
+	if correct {
+		consecutiveFailures = 0
+	} else {
+		failures++
+		consecutiveFailures++
+	}
+	return storeSRSState(db, wordID, state, failures, consecutiveFailures)
+}
+
+// storeSRSState saves the scheduling state of a word with its failure counts
+func storeSRSState(db execer, wordID int, state srs.State, failures, consecutiveFailures int) error {
+	_, err := db.Exec(`
+		INSERT OR REPLACE INTO word_srs_state (
+			word_id, algorithm, repetitions, interval_days, ease, stability, difficulty,
+			lapses, due_at, last_reviewed_at, failures, consecutive_failures
+		)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		wordID, scheduler.Name(), state.Repetitions, state.IntervalDays, state.Ease, state.Stability,
+		state.Difficulty, state.Lapses, state.Due.UTC().Format(reviewTimeFormat),
+		state.LastReview.UTC().Format(reviewTimeFormat), failures, consecutiveFailures)
+	return err
+}
+
+// RescheduleStaleWords rebuilds the states missing for reviewed words or
+// stored by another algorithm than the current one, returning how many
+// words were rescheduled
+func RescheduleStaleWords(db *sql.DB) (int, error) {
+	rows, err := db.Query(`
+		SELECT DISTINCT wri.word_id
+		FROM first_word_review_items wri
+		LEFT JOIN word_srs_state s ON s.word_id = wri.word_id
+		WHERE s.word_id IS NULL OR s.algorithm != ?`,
+		scheduler.Name())
+	if err != nil {
+		return 0, err
+	}
+	var wordIDs []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		wordIDs = append(wordIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, id := range wordIDs {
+		if err := rescheduleWord(db, id); err != nil {
+			return 0, err
+		}
+	}
+	return len(wordIDs), nil
+}
+
+// srsColumns lists the columns of a word's scheduling state
+const srsColumns = `s.word_id, s.algorithm, s.repetitions, s.interval_days, s.ease, s.stability,
+	s.difficulty, s.lapses, s.due_at, s.last_reviewed_at`
+
+// wordScope restricts words w to those not suspended, of a group unless
+// groupID is 0. It returns sql.ErrNoRows for unknown groups.
+func wordScope(db *sql.DB, groupID int) (string, []interface{}, error) {
+	if groupID == 0 {
+		return "w.suspended_at IS NULL", nil, nil
+	}
+	members, args, err := groupMembers(db, groupID)
+	if err != nil {
+		return "", nil, err
+	}
+	return "w.suspended_at IS NULL AND w.id IN (" + members + ")", args, nil
+}
+
+// GetDueReviews lists the words due for review at now, most overdue first,
+// followed by up to q.New words never reviewed. Suspended words are left
+// out. It returns sql.ErrNoRows for unknown groups.
+func GetDueReviews(db *sql.DB, q DueQuery, now time.Time) (*DueReviews, error) {
+	scope, args, err := wordScope(db, q.GroupID)
+	if err != nil {
+		return nil, err
+	}
+	nowText := now.UTC().Format(reviewTimeFormat)
+
+	due := &DueReviews{Items: []DueWord{}}
+	err = db.QueryRow(`
+		SELECT
+			COALESCE(SUM(CASE WHEN s.due_at <= ? THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN s.word_id IS NULL THEN 1 ELSE 0 END), 0)
+		FROM words w
+		LEFT JOIN word_srs_state s ON s.word_id = w.id
+		WHERE `+scope,
+		append([]interface{}{nowText}, args...)...).Scan(&due.DueCount, &due.NewCount)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(`
+		SELECT `+wordColumns+`, `+srsColumns+`
+		FROM words w
+		JOIN word_srs_state s ON s.word_id = w.id
+		WHERE `+scope+` AND s.due_at <= ?
+		ORDER BY s.due_at, w.id
+		LIMIT ?`,
+		append(args, nowText, q.Limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		state := &WordSRS{}
+		word, err := scanWord(rowFunc(func(dest ...interface{}) error {
+			dest = append(dest, &state.WordID, &state.Algorithm, &state.Repetitions, &state.IntervalDays, &state.Ease,
+				&state.Stability, &state.Difficulty, &state.Lapses)
+			var dueAt, lastReview sqliteTime
+			if err := rows.Scan(append(dest, &dueAt, &lastReview)...); err != nil {
+				return err
+			}
+			state.Due, state.LastReview = dueAt.Time, lastReview.Time
+			return nil
+		}))
+		if err != nil {
+			return nil, err
+		}
+		due.Items = append(due.Items, DueWord{Word: *word, SRS: state})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	newLimit := q.Limit - len(due.Items)
+	if q.New < newLimit {
+		newLimit = q.New
+	}
+	if newLimit <= 0 {
+		return due, nil
+	}
+	rows, err = db.Query(`
+		SELECT `+wordColumns+`
+		FROM words w
+		WHERE `+scope+` AND w.id NOT IN (SELECT word_id FROM word_srs_state)
+		ORDER BY w.id
+		LIMIT ?`,
+		append(args, newLimit)...)
+	if err != nil {
+		return nil, err
+	}
+	words, err := scanWords(rows)
+	if err != nil {
+		return nil, err
+	}
+	for _, word := range words {
+		due.Items = append(due.Items, DueWord{Word: word})
+	}
+	return due, nil
+}
+
+// GetReviewForecast counts the reviews falling due on each of the next days,
+// starting with today. It returns sql.ErrNoRows for unknown groups.
+func GetReviewForecast(db *sql.DB, groupID, days int, now time.Time) (*ReviewForecast, error) {
+	scope, args, err := wordScope(db, groupID)
+	if err != nil {
+		return nil, err
+	}
+	now = now.UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	end := today.AddDate(0, 0, days)
+
+	forecast := &ReviewForecast{Days: make([]ForecastDay, days)}
+	for i := range forecast.Days {
+		forecast.Days[i].Date = today.AddDate(0, 0, i).Format("2006-01-02")
+	}
+
+	rows, err := db.Query(`
+		SELECT s.due_at
+		FROM words w
+		JOIN word_srs_state s ON s.word_id = w.id
+		WHERE `+scope+` AND s.due_at < ?`,
+		append(args, end.Format(reviewTimeFormat))...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var due sqliteTime
+		if err := rows.Scan(&due); err != nil {
+			return nil, err
+		}
+		if !due.Time.After(now) {
+			forecast.OverdueCount++
+		}
+		day := 0
+		if due.Time.After(today) {
+			day = int(due.Time.Sub(today) / srs.Day)
+		}
+		forecast.Days[day].DueCount++
+	}
+	return forecast, rows.Err()
+}
@@ -2,58 +2,97 @@ package models
 
 import (
 	"database/sql"
+	"encoding/json"
+	"strings"
 	"time"
 )
 
-type StudyActivity struct {
-	ID           int    `json:"id"`
-	Name         string `json:"name"`
-	ThumbnailURL string `json:"thumbnail_url"`
-	Description  string `json:"description"`
+type StudySessionDetail struct {
+	ID int `json:"id"`
+	// GroupID is only set for single-group sessions, GroupName joins the
+	// names of every group of the session
+	GroupID         *int       `json:"group_id"`
+	CreatedAt       time.Time  `json:"created_at"`
+	StudyActivityID int        `json:"study_activity_id"`
+	GroupName       string     `json:"group_name"`
+	ActivityName    string     `json:"activity_name"`
+	ReviewItemCount int        `json:"review_items_count"`
+	Status          string     `json:"status"`
+	CompletedAt     *time.Time `json:"completed_at"`
+	// Strategy and QueueSize describe the word queue of a new session, see
+	// GetSessionQueue
+	Strategy  string `json:"strategy,omitempty"`
+	QueueSize int    `json:"queue_size,omitempty"`
+	SessionScope
+	SessionTimes
 }
 
-type StudySessionDetail struct {
-	ID              int       `json:"id"`
-	GroupID         int       `json:"group_id"`
-	CreatedAt       time.Time `json:"created_at"`
-	StudyActivityID int       `json:"study_activity_id"`
-	GroupName       string    `json:"group_name"`
-	ActivityName    string    `json:"activity_name"`
-	ReviewItemCount int       `json:"review_items_count"`
+// Attempts selects which reviews stats count when a word is attempted more
+// than once in a session
+type Attempts string
+
+const (
+	AttemptsAll   Attempts = "all"
+	AttemptsFirst Attempts = "first"
+)
+
+// reviews is the table or view holding the counted reviews
+func (a Attempts) reviews() string {
+	if a == AttemptsFirst {
+		return "first_word_review_items"
+	}
+	return "word_review_items"
 }
 
-type WordReviewItem struct {
-	WordID         int       `json:"word_id"`
-	StudySessionID int       `json:"study_session_id"`
-	Correct        bool      `json:"correct"`
-	CreatedAt      time.Time `json:"created_at"`
+// StudySessionFilter narrows down study session lists. Zero values match
+// every session.
+type StudySessionFilter struct {
+	ActivityID int
+	// GroupID keeps the sessions started on a group, alone or with others
+	GroupID int
+	// Language only keeps sessions with words in that language, in their
+	// groups or in the words they were started on
+	Language string
 }
 
-// GetStudyActivity retrieves a single study activity
-func GetStudyActivity(db *sql.DB, id int) (*StudyActivity, error) {
-	var sa StudyActivity
-	err := db.QueryRow(`
-		SELECT id, name, thumbnail_url, description 
-		FROM study_activities 
-		WHERE id = ?`,
-		id).Scan(&sa.ID, &sa.Name, &sa.ThumbnailURL, &sa.Description)
-	if err != nil {
-		return nil, err
+// conditions builds the WHERE clause for sessions aliased as ss
+func (f StudySessionFilter) conditions() (string, []interface{}) {
+	where := []string{"1 = 1"}
+	var args []interface{}
+	if f.ActivityID != 0 {
+		where = append(where, "ss.study_activity_id = ?")
+		args = append(args, f.ActivityID)
+	}
+	if f.GroupID != 0 {
+		where = append(where, "ss.id IN (SELECT study_session_id FROM study_session_groups WHERE group_id = ?)")
+		args = append(args, f.GroupID)
+	}
+	if f.Language != "" {
+		where = append(where, `(EXISTS (
+			SELECT 1 FROM study_session_groups fsg
+			WHERE fsg.study_session_id = ss.id AND `+groupLanguageCondition("fsg.group_id")+`
+		) OR EXISTS (
+			SELECT 1 FROM study_session_words fsw
+			JOIN words fw ON fw.id = fsw.word_id
+			WHERE fsw.study_session_id = ss.id AND fw.language = ?
+		))`)
+		args = append(args, f.Language, f.Language)
 	}
-	return &sa, nil
+	return strings.Join(where, " AND "), args
 }
 
-// GetStudySessions retrieves study sessions for an activity
-func GetStudySessions(db *sql.DB, activityID int, page, perPage int) ([]StudySessionDetail, int, error) {
+// GetStudySessions retrieves a paginated list of study sessions
+func GetStudySessions(db *sql.DB, filter StudySessionFilter, page, perPage int) ([]StudySessionDetail, int, error) {
 	offset := (page - 1) * perPage
+	where, args := filter.conditions()
 
 	// Get total count
 	var total int
 	err := db.QueryRow(`
 		SELECT COUNT(*) 
-		FROM study_sessions 
-		WHERE study_activity_id = ?`,
-		activityID).Scan(&total)
+		FROM study_sessions ss
+		WHERE `+where,
+		args...).Scan(&total)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -62,18 +101,19 @@ func GetStudySessions(db *sql.DB, activityID int, page, perPage int) ([]StudySes
 	rows, err := db.Query(`
 		SELECT 
 			ss.id, ss.group_id, ss.created_at, ss.study_activity_id,
-			g.name as group_name,
+			`+sessionGroupNames+` as group_name,
 			sa.name as activity_name,
-			COUNT(wri.word_id) as review_items_count
+			COUNT(wri.word_id) as review_items_count,
+			ss.status, ss.completed_at, MAX(wri.created_at) as last_review_at,
+			`+sessionScopeColumns+`
 		FROM study_sessions ss
-		JOIN groups g ON ss.group_id = g.id
 		JOIN study_activities sa ON ss.study_activity_id = sa.id
 		LEFT JOIN word_review_items wri ON ss.id = wri.study_session_id
-		WHERE ss.study_activity_id = ?
+		WHERE `+where+`
 		GROUP BY ss.id
 		ORDER BY ss.created_at DESC
 		LIMIT ? OFFSET ?`,
-		activityID, perPage, offset)
+		append(args, perPage, offset)...)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -82,24 +122,74 @@ func GetStudySessions(db *sql.DB, activityID int, page, perPage int) ([]StudySes
 	var sessions []StudySessionDetail
 	for rows.Next() {
 		var s StudySessionDetail
-		if err := rows.Scan(
-			&s.ID, &s.GroupID, &s.CreatedAt, &s.StudyActivityID,
+		var groupID sql.NullInt64
+		var completedAt, lastReviewAt sqliteTime
+		var scope sessionScopeDest
+		if err := rows.Scan(append([]interface{}{
+			&s.ID, &groupID, &s.CreatedAt, &s.StudyActivityID,
 			&s.GroupName, &s.ActivityName, &s.ReviewItemCount,
-		); err != nil {
+			&s.Status, &completedAt, &lastReviewAt,
+		}, scope.fields()...)...); err != nil {
+			return nil, 0, err
+		}
+		s.GroupID = nullableInt(groupID)
+		if s.SessionScope, err = scope.decode(); err != nil {
 			return nil, 0, err
 		}
+		s.CompletedAt = completedAt.Ptr()
+		s.SessionTimes = newSessionTimes(s.CreatedAt, s.CompletedAt, lastReviewAt.Ptr())
 		sessions = append(sessions, s)
 	}
 
 	return sessions, total, nil
 }
 
-// CreateStudySession creates a new study session
-func CreateStudySession(db *sql.DB, groupID, activityID int) (*StudySessionDetail, error) {
-	result, err := db.Exec(`
-		INSERT INTO study_sessions (group_id, study_activity_id, created_at)
-		VALUES (?, ?, CURRENT_TIMESTAMP)`,
-		groupID, activityID)
+// CreateStudySession creates a new study session over a target along with
+// its word queue, built by the queue spec. It returns sql.ErrNoRows for
+// unknown groups.
+func CreateStudySession(db *sql.DB, target SessionTarget, activityID int, queue QueueSpec) (*StudySessionDetail, error) {
+	resolved, err := target.resolve(db)
+	if err != nil {
+		return nil, err
+	}
+	if err := queue.Normalize(); err != nil {
+		return nil, err
+	}
+
+	scope := SessionScope{Scope: resolved.scope, GroupIDs: []int{}, Query: target.Query}
+	var groupID *int
+	if resolved.scope == ScopeGroup {
+		groupID = &resolved.groupIDs[0]
+	}
+	if resolved.groupIDs != nil {
+		scope.GroupIDs = resolved.groupIDs
+	}
+	var query sql.NullString
+	if target.Query != nil {
+		encoded, err := json.Marshal(target.Query)
+		if err != nil {
+			return nil, err
+		}
+		query = sql.NullString{String: string(encoded), Valid: true}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// The queue is built in the transaction so that it matches the words
+	// recorded for the session
+	wordIDs, err := buildQueue(tx, resolved.members, resolved.args, queue, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := tx.Exec(`
+		INSERT INTO study_sessions (group_id, study_activity_id, created_at, strategy, queue_seed, scope, query)
+		VALUES (?, ?, CURRENT_TIMESTAMP, ?, ?, ?, ?)`,
+		groupID, activityID, queue.Strategy, *queue.Seed, resolved.scope, query)
 	if err != nil {
 		return nil, err
 	}
@@ -109,19 +199,38 @@ func CreateStudySession(db *sql.DB, groupID, activityID int) (*StudySessionDetai
 		return nil, err
 	}
 
+	for _, gid := range resolved.groupIDs {
+		_, err := tx.Exec("INSERT INTO study_session_groups (study_session_id, group_id) VALUES (?, ?)", id, gid)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := recordSessionWords(tx, id, resolved.sources); err != nil {
+		return nil, err
+	}
+	for i, wordID := range wordIDs {
+		_, err := tx.Exec(`
+			INSERT INTO study_session_queue (study_session_id, position, word_id)
+			VALUES (?, ?, ?)`,
+			id, i+1, wordID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	now := nowUTC()
 	return &StudySessionDetail{
 		ID:              int(id),
 		GroupID:         groupID,
 		StudyActivityID: activityID,
-		CreatedAt:       time.Now(),
+		CreatedAt:       now,
+		Status:          SessionActive,
+		Strategy:        queue.Strategy,
+		QueueSize:       len(wordIDs),
+		SessionScope:    scope,
+		SessionTimes:    newSessionTimes(now, nil, nil),
 	}, nil
 }
-
-// AddWordReview adds a word review to a study session
-func AddWordReview(db *sql.DB, sessionID, wordID int, correct bool) error {
-	_, err := db.Exec(`
-		INSERT INTO word_review_items (word_id, study_session_id, correct, created_at)
-		VALUES (?, ?, ?, CURRENT_TIMESTAMP)`,
-		wordID, sessionID, correct)
-	return err
-}
@@ -0,0 +1,235 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// StudyActivity is an entry of the activity catalog shown on the launchpad
+type StudyActivity struct {
+	ID           int       `json:"id"`
+	Name         string    `json:"name"`
+	Description  string    `json:"description"`
+	ThumbnailURL string    `json:"thumbnail_url"`
+	LaunchURL    string    `json:"launch_url"`
+	Enabled      bool      `json:"enabled"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// StudyActivityFilter narrows down the activity catalog. A nil Enabled
+// lists every activity.
+type StudyActivityFilter struct {
+	Enabled *bool
+}
+
+var (
+	ErrActivityInUse    = errors.New("study activity has study sessions")
+	ErrActivityDisabled = errors.New("study activity is disabled")
+)
+
+const studyActivityColumns = "id, name, description, thumbnail_url, launch_url, enabled, created_at, updated_at"
+
+func scanStudyActivity(row rowScanner) (*StudyActivity, error) {
+	var sa StudyActivity
+	var thumbnailURL, launchURL sql.NullString
+	var createdAt, updatedAt sqliteTime
+	err := row.Scan(&sa.ID, &sa.Name, &sa.Description, &thumbnailURL, &launchURL, &sa.Enabled, &createdAt, &updatedAt)
+	if err != nil {
+		return nil, err
+	}
+	sa.ThumbnailURL = thumbnailURL.String
+	sa.LaunchURL = launchURL.String
+	sa.CreatedAt, sa.UpdatedAt = createdAt.Time, updatedAt.Time
+	return &sa, nil
+}
+
+// Normalize trims the activity's fields and validates them
+func (sa *StudyActivity) Normalize() error {
+	sa.Name = strings.TrimSpace(sa.Name)
+	sa.Description = strings.TrimSpace(sa.Description)
+	sa.ThumbnailURL = strings.TrimSpace(sa.ThumbnailURL)
+	sa.LaunchURL = strings.TrimSpace(sa.LaunchURL)
+	if sa.Name == "" {
+		return &ValidationError{Field: "name", Message: "is required"}
+	}
+	// Thumbnails may be served by the frontend itself
+	if sa.ThumbnailURL != "" && !strings.HasPrefix(sa.ThumbnailURL, "/") && !isWebURL(sa.ThumbnailURL) {
+		return &ValidationError{Field: "thumbnail_url", Message: "must be an http(s) URL or an absolute path"}
+	}
+	if sa.LaunchURL != "" && !isWebURL(sa.LaunchURL) {
+		return &ValidationError{Field: "launch_url", Message: "must be an http(s) URL"}
+	}
+	if sa.Enabled && sa.LaunchURL == "" {
+		return &ValidationError{Field: "launch_url", Message: "is required for enabled activities"}
+	}
+	return nil
+}
+
+// ApplyPatch applies a JSON Merge Patch to the activity and validates the
+// result
+func (sa *StudyActivity) ApplyPatch(patch Patch) error {
+	if err := patch.checkFields("name", "description", "thumbnail_url", "launch_url", "enabled"); err != nil {
+		return err
+	}
+	if _, err := patch.decode("name", &sa.Name, false); err != nil {
+		return err
+	}
+	if _, err := patch.decode("enabled", &sa.Enabled, false); err != nil {
+		return err
+	}
+	for field, dest := range map[string]*string{"description": &sa.Description, "thumbnail_url": &sa.ThumbnailURL, "launch_url": &sa.LaunchURL} {
+		if ok, err := patch.decode(field, dest, true); err != nil {
+			return err
+		} else if ok && patch.isNull(field) {
+			*dest = ""
+		}
+	}
+	return sa.Normalize()
+}
+
+func isWebURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// checkActivityName rejects names already used by another activity
+func checkActivityName(db *sql.DB, sa *StudyActivity) error {
+	var taken bool
+	err := db.QueryRow(`
+		SELECT EXISTS(SELECT 1 FROM study_activities WHERE name = ? COLLATE NOCASE AND id != ?)`,
+		sa.Name, sa.ID).Scan(&taken)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return &ValidationError{Field: "name", Message: "is already used by another activity"}
+	}
+	return nil
+}
+
+// GetStudyActivities retrieves a paginated list of the activity catalog
+func GetStudyActivities(db *sql.DB, filter StudyActivityFilter, page, perPage int) ([]StudyActivity, int, error) {
+	offset := (page - 1) * perPage
+	where := "1 = 1"
+	var args []interface{}
+	if filter.Enabled != nil {
+		where = "enabled = ?"
+		args = append(args, *filter.Enabled)
+	}
+
+	// Get total count
+	var total int
+	err := db.QueryRow("SELECT COUNT(*) FROM study_activities WHERE "+where, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := db.Query(`
+		SELECT `+studyActivityColumns+`
+		FROM study_activities
+		WHERE `+where+`
+		ORDER BY id
+		LIMIT ? OFFSET ?`,
+		append(args, perPage, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	activities := []StudyActivity{}
+	for rows.Next() {
+		sa, err := scanStudyActivity(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		activities = append(activities, *sa)
+	}
+
+	return activities, total, rows.Err()
+}
+
+// GetStudyActivity retrieves a single study activity
+func GetStudyActivity(db *sql.DB, id int) (*StudyActivity, error) {
+	return scanStudyActivity(db.QueryRow(`
+		SELECT `+studyActivityColumns+`
+		FROM study_activities
+		WHERE id = ?`,
+		id))
+}
+
+// CreateStudyActivity adds an activity to the catalog
+func CreateStudyActivity(db *sql.DB, sa *StudyActivity) error {
+	if err := checkActivityName(db, sa); err != nil {
+		return err
+	}
+
+	now := nowUTC()
+	result, err := db.Exec(`
+		INSERT INTO study_activities (name, description, thumbnail_url, launch_url, enabled, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		sa.Name, sa.Description, nullableString(sa.ThumbnailURL), nullableString(sa.LaunchURL), sa.Enabled, now, now)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	sa.ID = int(id)
+	sa.CreatedAt, sa.UpdatedAt = now, now
+	return nil
+}
+
+// UpdateStudyActivity stores the activity's fields. It returns
+// sql.ErrNoRows for unknown activities.
+func UpdateStudyActivity(db *sql.DB, sa *StudyActivity) error {
+	if err := checkActivityName(db, sa); err != nil {
+		return err
+	}
+
+	result, err := db.Exec(`
+		UPDATE study_activities
+		SET name = ?, description = ?, thumbnail_url = ?, launch_url = ?, enabled = ?, updated_at = ?
+		WHERE id = ?`,
+		sa.Name, sa.Description, nullableString(sa.ThumbnailURL), nullableString(sa.LaunchURL), sa.Enabled, nowUTC(),
+		sa.ID)
+	if err != nil {
+		return err
+	}
+	if err := requireAffected(result); err != nil {
+		return err
+	}
+
+	// Reload so that created_at is returned as stored
+	updated, err := GetStudyActivity(db, sa.ID)
+	if err != nil {
+		return err
+	}
+	*sa = *updated
+	return nil
+}
+
+// DeleteStudyActivity removes an activity from the catalog. Activities with
+// study sessions are kept for their history and return ErrActivityInUse;
+// disable them instead.
+func DeleteStudyActivity(db *sql.DB, id int) error {
+	var inUse bool
+	err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM study_sessions WHERE study_activity_id = ?)", id).Scan(&inUse)
+	if err != nil {
+		return err
+	}
+	if inUse {
+		return ErrActivityInUse
+	}
+
+	result, err := db.Exec("DELETE FROM study_activities WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
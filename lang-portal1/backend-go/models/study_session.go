@@ -2,35 +2,152 @@ package models
 
 import (
 	"database/sql"
+	"errors"
+	"fmt"
 	"time"
 )
 
+// Study session statuses
+const (
+	SessionActive    = "active"
+	SessionCompleted = "completed"
+	SessionAbandoned = "abandoned"
+)
+
+var ErrSessionClosed = errors.New("study session is no longer active")
+
 type StudySession struct {
-	ID              int       `json:"id"`
-	GroupID         int       `json:"group_id"`
-	StudyActivityID int       `json:"study_activity_id"`
-	CreatedAt       time.Time `json:"created_at"`
-	CompletedAt     *time.Time `json:"completed_at,omitempty"`
+	ID int `json:"id"`
+	// GroupID is only set for single-group sessions
+	GroupID         *int       `json:"group_id"`
+	StudyActivityID int        `json:"study_activity_id"`
+	CreatedAt       time.Time  `json:"created_at"`
+	Status          string     `json:"status"`
+	CompletedAt     *time.Time `json:"completed_at"`
+	SessionScope
+	SessionTimes
+}
+
+// SessionTimes are the start, end and duration in seconds of a session.
+// Sessions end when they are closed; until then the end is inferred from
+// the last review.
+type SessionTimes struct {
+	StartTime time.Time  `json:"start_time"`
+	EndTime   *time.Time `json:"end_time"`
+	Duration  *int       `json:"duration"`
+}
+
+func newSessionTimes(start time.Time, completedAt, lastReviewAt *time.Time) SessionTimes {
+	times := SessionTimes{StartTime: start, EndTime: completedAt}
+	if times.EndTime == nil {
+		times.EndTime = lastReviewAt
+	}
+	if times.EndTime != nil {
+		duration := int(times.EndTime.Sub(start).Seconds())
+		if duration < 0 {
+			duration = 0
+		}
+		times.Duration = &duration
+	}
+	return times
 }
 
 // GetStudySession retrieves a study session by its ID
 func GetStudySession(db *sql.DB, id int) (*StudySession, error) {
 	var session StudySession
+	var groupID sql.NullInt64
+	var completedAt, lastReviewAt sqliteTime
+	var scope sessionScopeDest
 	query := `
-		SELECT id, group_id, study_activity_id, created_at, completed_at 
-		FROM study_sessions 
+		SELECT id, group_id, study_activity_id, created_at, status, completed_at,
+			(SELECT MAX(created_at) FROM word_review_items WHERE study_session_id = ss.id),
+			` + sessionScopeColumns + `
+		FROM study_sessions ss
 		WHERE id = $1`
-	
-	err := db.QueryRow(query, id).Scan(
+
+	err := db.QueryRow(query, id).Scan(append([]interface{}{
 		&session.ID,
-		&session.GroupID,
+		&groupID,
 		&session.StudyActivityID,
 		&session.CreatedAt,
-		&session.CompletedAt,
-	)
+		&session.Status,
+		&completedAt,
+		&lastReviewAt,
+	}, scope.fields()...)...)
 	if err != nil {
 		return nil, err
 	}
-	
+	session.GroupID = nullableInt(groupID)
+	if session.SessionScope, err = scope.decode(); err != nil {
+		return nil, err
+	}
+	session.CompletedAt = completedAt.Ptr()
+	session.SessionTimes = newSessionTimes(session.CreatedAt, session.CompletedAt, lastReviewAt.Ptr())
+
 	return &session, nil
 }
+
+// requireActiveSession returns sql.ErrNoRows for unknown sessions and
+// ErrSessionClosed for sessions that are over
+func requireActiveSession(db querier, id int) error {
+	var status string
+	if err := db.QueryRow("SELECT status FROM study_sessions WHERE id = ?", id).Scan(&status); err != nil {
+		return err
+	}
+	if status != SessionActive {
+		return ErrSessionClosed
+	}
+	return nil
+}
+
+// CloseStudySession marks an active session as completed or abandoned. It
+// returns ErrSessionClosed if the session is already over.
+func CloseStudySession(db *sql.DB, id int, status string) (*StudySession, error) {
+	if status != SessionCompleted && status != SessionAbandoned {
+		return nil, fmt.Errorf("cannot close a study session as %q", status)
+	}
+
+	result, err := db.Exec(`
+		UPDATE study_sessions
+		SET status = ?, completed_at = ?
+		WHERE id = ? AND status = ?`,
+		status, nowUTC(), id, SessionActive)
+	if err != nil {
+		return nil, err
+	}
+	if err := requireAffected(result); err == sql.ErrNoRows {
+		// Tell unknown sessions apart from closed ones
+		if err := requireActiveSession(db, id); err != nil {
+			return nil, err
+		}
+		return nil, ErrSessionClosed
+	} else if err != nil {
+		return nil, err
+	}
+
+	return GetStudySession(db, id)
+}
+
+// CloseIdleSessions closes the active sessions without reviews for longer
+// than idle. Sessions with reviews are completed at their last review, the
+// others are abandoned.
+func CloseIdleSessions(db *sql.DB, idle time.Duration) (int64, error) {
+	result, err := db.Exec(`
+		UPDATE study_sessions
+		SET status = CASE
+				WHEN EXISTS(SELECT 1 FROM word_review_items WHERE study_session_id = study_sessions.id) THEN ?
+				ELSE ?
+			END,
+			completed_at = datetime(COALESCE(
+				(SELECT MAX(created_at) FROM word_review_items WHERE study_session_id = study_sessions.id),
+				created_at))
+		WHERE status = ?
+			AND datetime(COALESCE(
+				(SELECT MAX(created_at) FROM word_review_items WHERE study_session_id = study_sessions.id),
+				created_at)) < datetime('now', ?)`,
+		SessionCompleted, SessionAbandoned, SessionActive, fmt.Sprintf("-%d seconds", int(idle.Seconds())))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
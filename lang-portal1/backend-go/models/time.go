@@ -0,0 +1,57 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// sqliteTime scans DATETIME values. Aggregates such as MAX(created_at) lose
+// the column type, so the driver hands them over as text instead of
+// time.Time.
+type sqliteTime struct {
+	Time  time.Time
+	Valid bool
+}
+
+func (t *sqliteTime) Scan(value interface{}) error {
+	t.Time, t.Valid = time.Time{}, false
+	var text string
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case time.Time:
+		t.Time, t.Valid = v, true
+		return nil
+	case string:
+		text = v
+	case []byte:
+		text = string(v)
+	default:
+		return fmt.Errorf("cannot scan %T into a time", value)
+	}
+
+	text = strings.TrimSuffix(text, "Z")
+	for _, layout := range sqlite3.SQLiteTimestampFormats {
+		if parsed, err := time.ParseInLocation(layout, text, time.UTC); err == nil {
+			t.Time, t.Valid = parsed, true
+			return nil
+		}
+	}
+	return fmt.Errorf("cannot parse time %q", text)
+}
+
+// Ptr returns the time, or nil for NULL
+func (t sqliteTime) Ptr() *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+// nowUTC is the current time at the second precision of CURRENT_TIMESTAMP
+func nowUTC() time.Time {
+	return time.Now().UTC().Truncate(time.Second)
+}
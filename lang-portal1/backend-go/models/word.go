@@ -3,70 +3,342 @@ package models
 import (
 	"database/sql"
 	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"lang-portal/backend/language"
 )
 
 type Word struct {
-	ID       int             `json:"id"`
-	Japanese string          `json:"japanese"`
-	Romaji   string          `json:"romaji"`
-	English  string          `json:"english"`
-	Parts    json.RawMessage `json:"parts"` // Store as JSON string
+	ID           int             `json:"id"`
+	Language     string          `json:"language"`
+	Term         string          `json:"term"`
+	Romanization string          `json:"romanization,omitempty"`
+	Glosses      []string        `json:"glosses"`
+	Parts        json.RawMessage `json:"parts"` // Store as JSON string
+	// SuspendedAt is set while the word is left out of study queues
+	SuspendedAt *time.Time `json:"suspended_at,omitempty"`
+
+	// Furigana is only filled in when requested, see AddFurigana
+	Furigana interface{} `json:"furigana,omitempty"`
+
+	// Japanese, Romaji and English mirror the term, romanization and first
+	// gloss of Japanese words for clients written before other languages
+	// were supported. They are always present for Japanese words, if empty,
+	// and left out for the others.
+	Japanese *string `json:"japanese,omitempty"`
+	Romaji   *string `json:"romaji,omitempty"`
+	English  *string `json:"english,omitempty"`
 }
 
 type WordWithGroups struct {
 	Word
-	Groups []Group `json:"groups"`
+	Groups             []Group       `json:"groups"`
+	Relations          []RelatedWord `json:"relations"`
+	SuggestedRelations []RelatedWord `json:"suggested_relations"`
+}
+
+// WordFilter narrows down word lists
+type WordFilter struct {
+	Language string
+	// Search matches the term, romanization or glosses
+	Search string
+	// Suspended keeps only suspended or only active words when set
+	Suspended *bool
+}
+
+// ValidationError reports a word or group field that failed validation
+type ValidationError struct {
+	Field   string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// rowFunc adapts a function to rowScanner, for scanning a word that is
+// preceded by other columns
+type rowFunc func(dest ...interface{}) error
+
+func (f rowFunc) Scan(dest ...interface{}) error {
+	return f(dest...)
+}
+
+// wordColumns lists the columns scanned by scanWord, for queries that alias
+// words as w
+const wordColumns = "w.id, w.language, w.term, w.romanization, w.glosses, w.parts, w.suspended_at"
+
+func scanWord(row rowScanner) (*Word, error) {
+	var w Word
+	var romanization sql.NullString
+	var glosses string
+	var suspendedAt sqliteTime
+	if err := row.Scan(&w.ID, &w.Language, &w.Term, &romanization, &glosses, &w.Parts, &suspendedAt); err != nil {
+		return nil, err
+	}
+	w.Romanization = romanization.String
+	w.SuspendedAt = suspendedAt.Ptr()
+	if err := json.Unmarshal([]byte(glosses), &w.Glosses); err != nil {
+		return nil, err
+	}
+	w.setLegacyFields()
+	return &w, nil
+}
+
+// scanWords collects the words from rows selected with wordColumns
+func scanWords(rows *sql.Rows) ([]Word, error) {
+	defer rows.Close()
+
+	var words []Word
+	for rows.Next() {
+		w, err := scanWord(rows)
+		if err != nil {
+			return nil, err
+		}
+		words = append(words, *w)
+	}
+	return words, rows.Err()
+}
+
+func (w *Word) setLegacyFields() {
+	w.Japanese, w.Romaji, w.English = nil, nil, nil
+	if w.Language != "ja" {
+		return
+	}
+	japanese, romaji, english := w.Term, w.Romanization, ""
+	if len(w.Glosses) > 0 {
+		english = w.Glosses[0]
+	}
+	w.Japanese, w.Romaji, w.English = &japanese, &romaji, &english
+}
+
+// Normalize validates a word against the rules of its language, filling in
+// the default language and deriving a romanization when none is given
+func (w *Word) Normalize() error {
+	w.Language = strings.ToLower(strings.TrimSpace(w.Language))
+	if w.Language == "" {
+		w.Language = language.Default
+	}
+	lang, ok := language.Get(w.Language)
+	if !ok {
+		return &ValidationError{Field: "language", Message: "unsupported language " + w.Language}
+	}
+
+	w.Term = strings.TrimSpace(w.Term)
+	w.Romanization = strings.TrimSpace(w.Romanization)
+	if err := lang.Validate(w.Term, w.Romanization); err != nil {
+		field := "term"
+		if err == language.ErrInvalidRomanization {
+			field = "romanization"
+		}
+		return &ValidationError{Field: field, Message: err.Error()}
+	}
+
+	var glosses []string
+	for _, g := range w.Glosses {
+		if g = strings.TrimSpace(g); g != "" {
+			glosses = append(glosses, g)
+		}
+	}
+	if len(glosses) == 0 {
+		return &ValidationError{Field: "glosses", Message: "at least one gloss is required"}
+	}
+	w.Glosses = glosses
+
+	if len(w.Parts) == 0 || string(w.Parts) == "null" {
+		w.Parts = json.RawMessage("[]")
+	}
+	if !json.Valid(w.Parts) {
+		return &ValidationError{Field: "parts", Message: "must be valid JSON"}
+	}
+
+	if w.Romanization == "" {
+		// Japanese readings in parts romanize even when the term has kanji
+		text := w.Term
+		if reading := w.Reading(); reading != "" {
+			text = reading
+		}
+		w.Romanization, _ = lang.Romanize(text)
+	}
+
+	w.setLegacyFields()
+	return nil
+}
+
+// ApplyPatch merges a JSON Merge Patch into the word. The word still has to
+// be normalized afterwards. Changing the term or language without a new
+// romanization clears the old one so that it is derived again.
+func (w *Word) ApplyPatch(patch Patch) error {
+	if err := patch.checkFields("language", "term", "romanization", "glosses", "parts", "japanese", "romaji", "english"); err != nil {
+		return err
+	}
+
+	var japanese, romaji, english string
+	if ok, err := patch.decode("japanese", &japanese, false); err != nil {
+		return err
+	} else if ok {
+		w.Term = japanese
+		if !patch.has("language") {
+			w.Language = "ja"
+		}
+	}
+	if _, err := patch.decode("language", &w.Language, false); err != nil {
+		return err
+	}
+	if _, err := patch.decode("term", &w.Term, false); err != nil {
+		return err
+	}
+	if patch.has("term", "language", "japanese") && !patch.has("romanization", "romaji") {
+		w.Romanization = ""
+	}
+
+	if ok, err := patch.decode("romaji", &romaji, true); err != nil {
+		return err
+	} else if ok {
+		w.Romanization = romaji
+	}
+	if ok, err := patch.decode("romanization", &w.Romanization, true); err != nil {
+		return err
+	} else if ok && patch.isNull("romanization") {
+		w.Romanization = ""
+	}
+
+	if ok, err := patch.decode("english", &english, false); err != nil {
+		return err
+	} else if ok {
+		w.Glosses = []string{english}
+	}
+	if ok, err := patch.decode("glosses", &w.Glosses, false); err != nil {
+		return err
+	} else if ok && len(w.Glosses) == 0 {
+		return &ValidationError{Field: "glosses", Message: "at least one gloss is required"}
+	}
+
+	if raw, ok := patch["parts"]; ok {
+		w.Parts = raw
+	}
+	return nil
+}
+
+// nullableString stores empty strings as NULL
+func nullableString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// requireAffected turns an update that matched no rows into sql.ErrNoRows
+func requireAffected(result sql.Result) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// likeEscaper escapes the wildcards of LIKE patterns, for use with
+// ESCAPE '\'
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// searchCondition matches the words aliased as alias whose term,
+// romanization or one of whose glosses contains search. Wildcards in search
+// match literally.
+func searchCondition(alias, search string) (string, []interface{}) {
+	pattern := "%" + likeEscaper.Replace(search) + "%"
+	condition := `(` + alias + `.term LIKE ? ESCAPE '\'
+			OR ` + alias + `.romanization LIKE ? ESCAPE '\'
+			OR EXISTS (SELECT 1 FROM json_each(` + alias + `.glosses) gl WHERE gl.value LIKE ? ESCAPE '\'))`
+	return condition, []interface{}{pattern, pattern, pattern}
+}
+
+// conditions builds the WHERE clause for words aliased as w
+func (f WordFilter) conditions() (string, []interface{}) {
+	var where []string
+	var args []interface{}
+	if f.Language != "" {
+		where = append(where, "w.language = ?")
+		args = append(args, f.Language)
+	}
+	if f.Search != "" {
+		condition, searchArgs := searchCondition("w", f.Search)
+		where = append(where, condition)
+		args = append(args, searchArgs...)
+	}
+	if f.Suspended != nil {
+		if *f.Suspended {
+			where = append(where, "w.suspended_at IS NOT NULL")
+		} else {
+			where = append(where, "w.suspended_at IS NULL")
+		}
+	}
+	if len(where) == 0 {
+		return "1 = 1", nil
+	}
+	return strings.Join(where, " AND "), args
 }
 
 // GetWords retrieves a paginated list of words
-func GetWords(db *sql.DB, page, perPage int) ([]Word, int, error) {
+func GetWords(db *sql.DB, filter WordFilter, page, perPage int) ([]Word, int, error) {
 	offset := (page - 1) * perPage
+	where, args := filter.conditions()
 
 	// Get total count
 	var total int
-	err := db.QueryRow("SELECT COUNT(*) FROM words").Scan(&total)
+	err := db.QueryRow("SELECT COUNT(*) FROM words w WHERE "+where, args...).Scan(&total)
 	if err != nil {
 		return nil, 0, err
 	}
 
 	// Get paginated words
 	rows, err := db.Query(`
-		SELECT id, japanese, romaji, english, parts 
-		FROM words 
-		LIMIT ? OFFSET ?`, 
-		perPage, offset)
+		SELECT `+wordColumns+`
+		FROM words w
+		WHERE `+where+`
+		ORDER BY w.id
+		LIMIT ? OFFSET ?`,
+		append(args, perPage, offset)...)
 	if err != nil {
 		return nil, 0, err
 	}
-	defer rows.Close()
 
-	var words []Word
-	for rows.Next() {
-		var w Word
-		if err := rows.Scan(&w.ID, &w.Japanese, &w.Romaji, &w.English, &w.Parts); err != nil {
-			return nil, 0, err
-		}
-		words = append(words, w)
+	words, err := scanWords(rows)
+	if err != nil {
+		return nil, 0, err
 	}
 
 	return words, total, nil
 }
 
+// LookupWord retrieves a single word by ID without its groups or relations
+func LookupWord(db *sql.DB, id int) (*Word, error) {
+	return scanWord(db.QueryRow(`
+		SELECT `+wordColumns+`
+		FROM words w
+		WHERE w.id = ?`,
+		id))
+}
+
 // GetWord retrieves a single word by ID
 func GetWord(db *sql.DB, id int) (*WordWithGroups, error) {
-	var w WordWithGroups
-	err := db.QueryRow(`
-		SELECT id, japanese, romaji, english, parts 
-		FROM words 
-		WHERE id = ?`,
-		id).Scan(&w.ID, &w.Japanese, &w.Romaji, &w.English, &w.Parts)
+	word, err := LookupWord(db, id)
 	if err != nil {
 		return nil, err
 	}
+	w := WordWithGroups{Word: *word}
 
 	// Get associated groups
 	rows, err := db.Query(`
-		SELECT g.id, g.name 
+		SELECT `+groupColumns+`
 		FROM groups g
 		JOIN words_groups wg ON g.id = wg.group_id
 		WHERE wg.word_id = ?`,
@@ -78,21 +350,36 @@ func GetWord(db *sql.DB, id int) (*WordWithGroups, error) {
 
 	for rows.Next() {
 		var g Group
-		if err := rows.Scan(&g.ID, &g.Name); err != nil {
+		if err := scanGroup(rows, &g); err != nil {
 			return nil, err
 		}
 		w.Groups = append(w.Groups, g)
 	}
 
+	// Get related and suggested words
+	w.Relations, err = GetWordRelations(db, id)
+	if err != nil {
+		return nil, err
+	}
+	w.SuggestedRelations, err = SuggestWordRelations(db, &w.Word, w.Relations)
+	if err != nil {
+		return nil, err
+	}
+
 	return &w, nil
 }
 
-// CreateWord creates a new word
+// CreateWord creates a new word. The word must have been normalized.
 func CreateWord(db *sql.DB, word *Word) error {
+	glosses, err := json.Marshal(word.Glosses)
+	if err != nil {
+		return err
+	}
+
 	result, err := db.Exec(`
-		INSERT INTO words (japanese, romaji, english, parts)
-		VALUES (?, ?, ?, ?)`,
-		word.Japanese, word.Romaji, word.English, word.Parts)
+		INSERT INTO words (language, term, romanization, glosses, parts, reading)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		word.Language, word.Term, nullableString(word.Romanization), string(glosses), word.Parts, word.Reading())
 	if err != nil {
 		return err
 	}
@@ -105,17 +392,46 @@ func CreateWord(db *sql.DB, word *Word) error {
 	return nil
 }
 
-// UpdateWord updates an existing word
+// FillWordReadings stores the readings of words added before readings were
+// stored, returning how many words were filled in
+func FillWordReadings(db *sql.DB) (int, error) {
+	rows, err := db.Query("SELECT " + wordColumns + " FROM words w WHERE w.reading IS NULL")
+	if err != nil {
+		return 0, err
+	}
+	words, err := scanWords(rows)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, w := range words {
+		if _, err := db.Exec("UPDATE words SET reading = ? WHERE id = ?", w.Reading(), w.ID); err != nil {
+			return 0, err
+		}
+	}
+	return len(words), nil
+}
+
+// UpdateWord updates an existing word. The word must have been normalized.
 func UpdateWord(db *sql.DB, word *Word) error {
-	_, err := db.Exec(`
+	glosses, err := json.Marshal(word.Glosses)
+	if err != nil {
+		return err
+	}
+
+	result, err := db.Exec(`
 		UPDATE words 
-		SET japanese = ?, romaji = ?, english = ?, parts = ?
+		SET language = ?, term = ?, romanization = ?, glosses = ?, parts = ?, reading = ?
 		WHERE id = ?`,
-		word.Japanese, word.Romaji, word.English, word.Parts, word.ID)
-	return err
+		word.Language, word.Term, nullableString(word.Romanization), string(glosses), word.Parts, word.Reading(), word.ID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
 }
 
-// DeleteWord deletes a word and its group associations
+// DeleteWord deletes a word along with its group associations, relations
+// and media attachments
 func DeleteWord(db *sql.DB, id int) error {
 	tx, err := db.Begin()
 	if err != nil {
@@ -129,6 +445,24 @@ func DeleteWord(db *sql.DB, id int) error {
 		return err
 	}
 
+	_, err = tx.Exec("DELETE FROM word_relations WHERE word_id = ? OR related_word_id = ?", id, id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	_, err = tx.Exec("DELETE FROM word_media WHERE word_id = ?", id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	_, err = tx.Exec("DELETE FROM word_srs_state WHERE word_id = ?", id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
 	// Delete the word
 	_, err = tx.Exec("DELETE FROM words WHERE id = ?", id)
 	if err != nil {
@@ -138,3 +472,159 @@ func DeleteWord(db *sql.DB, id int) error {
 
 	return tx.Commit()
 }
+
+// WordPart is a single segment of a word's "parts" breakdown, e.g.
+// {"kanji": "新", "romaji": ["a", "ta", "ra"]}
+type WordPart struct {
+	Kanji  string   `json:"kanji"`
+	Romaji []string `json:"romaji"`
+	Kana   string   `json:"kana,omitempty"`
+}
+
+// ParseParts decodes the parts JSON of a word. Entries that are not part
+// objects (e.g. plain part-of-speech strings) are skipped.
+func ParseParts(raw json.RawMessage) []WordPart {
+	var entries []json.RawMessage
+	if err := json.Unmarshal(raw, &entries); err != nil {
+		return nil
+	}
+
+	var parts []WordPart
+	for _, entry := range entries {
+		var p struct {
+			Kanji   string          `json:"kanji"`
+			Romaji  json.RawMessage `json:"romaji"`
+			Kana    string          `json:"kana"`
+			Reading string          `json:"reading"`
+		}
+		if err := json.Unmarshal(entry, &p); err != nil || p.Kanji == "" {
+			continue
+		}
+
+		part := WordPart{Kanji: p.Kanji, Kana: p.Kana}
+		if part.Kana == "" {
+			part.Kana = p.Reading
+		}
+		// romaji may be a list of syllables or a single string
+		if err := json.Unmarshal(p.Romaji, &part.Romaji); err != nil {
+			var single string
+			if json.Unmarshal(p.Romaji, &single) == nil && single != "" {
+				part.Romaji = []string{single}
+			}
+		}
+		parts = append(parts, part)
+	}
+	return parts
+}
+
+// PartsOfSpeech returns the parts of speech of a word, in lower case. The
+// parts of a word hold either part-of-speech strings, e.g. ["verb"], or a
+// kanji breakdown of part objects (see ParseParts); a breakdown names no
+// part of speech, so those words have none.
+func (w Word) PartsOfSpeech() []string {
+	var entries []json.RawMessage
+	if err := json.Unmarshal(w.Parts, &entries); err != nil {
+		return nil
+	}
+
+	var parts []string
+	for _, entry := range entries {
+		var part string
+		if err := json.Unmarshal(entry, &part); err != nil {
+			// A part object of a breakdown
+			continue
+		}
+		if part = strings.ToLower(strings.TrimSpace(part)); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
+
+// Reading returns the hiragana reading of the part
+func (p WordPart) Reading() string {
+	switch {
+	case p.Kana != "":
+		return language.KatakanaToHiragana(p.Kana)
+	case len(p.Romaji) > 0:
+		return language.RomajiToHiragana(strings.Join(p.Romaji, ""))
+	case language.IsAllKana(p.Kanji):
+		return language.KatakanaToHiragana(p.Kanji)
+	}
+	return ""
+}
+
+// Reading returns the hiragana reading of a Japanese word, built from its
+// parts when they carry readings and from its romanization otherwise.
+func (w Word) Reading() string {
+	if w.Language != "ja" {
+		return ""
+	}
+
+	var reading strings.Builder
+	for _, p := range ParseParts(w.Parts) {
+		r := p.Reading()
+		if r == "" {
+			reading.Reset()
+			break
+		}
+		reading.WriteString(r)
+	}
+	if reading.Len() > 0 {
+		return reading.String()
+	}
+	if language.IsAllKana(w.Term) {
+		return language.KatakanaToHiragana(w.Term)
+	}
+	return language.RomajiToHiragana(w.Romanization)
+}
+
+// FuriganaTokens aligns the readings of a Japanese word with its term. Part
+// readings are used where the parts spell out the term, otherwise the
+// reading of the whole word is aligned. Other languages have no furigana.
+func (w Word) FuriganaTokens() []language.FuriganaToken {
+	if w.Language != "ja" {
+		return nil
+	}
+
+	parts := ParseParts(w.Parts)
+	var spelled strings.Builder
+	for _, p := range parts {
+		spelled.WriteString(p.Kanji)
+	}
+
+	var tokens []language.FuriganaToken
+	if len(parts) > 0 && spelled.String() == w.Term {
+		for _, p := range parts {
+			tokens = append(tokens, language.AlignFurigana(p.Kanji, p.Reading())...)
+		}
+	} else {
+		tokens = language.AlignFurigana(w.Term, w.Reading())
+	}
+	return language.MergeFurigana(tokens)
+}
+
+// AddFurigana renders furigana onto the word in the given format. Words
+// without a Japanese reading are left alone.
+func (w *Word) AddFurigana(format string) error {
+	tokens := w.FuriganaTokens()
+	if tokens == nil {
+		return nil
+	}
+	rendered, err := language.RenderFurigana(tokens, format)
+	if err != nil {
+		return err
+	}
+	w.Furigana = rendered
+	return nil
+}
+
+// AddFurigana renders furigana onto each word in the given format
+func AddFurigana(words []Word, format string) error {
+	for i := range words {
+		if err := words[i].AddFurigana(format); err != nil {
+			return err
+		}
+	}
+	return nil
+}
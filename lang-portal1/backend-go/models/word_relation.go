@@ -0,0 +1,298 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"lang-portal/backend/language"
+)
+
+// Word relation types
+const (
+	RelationSynonym     = "synonym"
+	RelationAntonym     = "antonym"
+	RelationHomophone   = "homophone"
+	RelationSameKanji   = "same_kanji"
+	RelationDerivedFrom = "derived_from"
+)
+
+// Directions of a derived_from relation as seen from the requested word
+const (
+	RelationOutgoing = "outgoing"
+	RelationIncoming = "incoming"
+)
+
+var (
+	ErrInvalidRelationType = errors.New("invalid relation type")
+	ErrSelfRelation        = errors.New("a word cannot be related to itself")
+	ErrDuplicateRelation   = errors.New("relation already exists")
+)
+
+type WordRelation struct {
+	ID            int       `json:"id"`
+	WordID        int       `json:"word_id"`
+	RelatedWordID int       `json:"related_word_id"`
+	RelationType  string    `json:"relation_type"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// RelatedWord is a word linked to another word, as returned on the word show page
+type RelatedWord struct {
+	RelationID   int    `json:"relation_id,omitempty"`
+	RelationType string `json:"relation_type"`
+	Direction    string `json:"direction,omitempty"`
+	Word         Word   `json:"word"`
+}
+
+// IsValidRelationType reports whether t is a known relation type
+func IsValidRelationType(t string) bool {
+	switch t {
+	case RelationSynonym, RelationAntonym, RelationHomophone, RelationSameKanji, RelationDerivedFrom:
+		return true
+	}
+	return false
+}
+
+// isSymmetricRelation reports whether a relation reads the same in both directions
+func isSymmetricRelation(t string) bool {
+	return t != RelationDerivedFrom
+}
+
+// normalizeRelation orders symmetric relations so each pair is stored once
+func normalizeRelation(r *WordRelation) {
+	if isSymmetricRelation(r.RelationType) && r.WordID > r.RelatedWordID {
+		r.WordID, r.RelatedWordID = r.RelatedWordID, r.WordID
+	}
+}
+
+// GetWordRelations retrieves the words linked to a word in either direction
+func GetWordRelations(db *sql.DB, wordID int) ([]RelatedWord, error) {
+	rows, err := db.Query(`
+		SELECT wr.id, wr.relation_type, wr.word_id, `+wordColumns+`
+		FROM word_relations wr
+		JOIN words w ON w.id = CASE WHEN wr.word_id = ? THEN wr.related_word_id ELSE wr.word_id END
+		WHERE wr.word_id = ? OR wr.related_word_id = ?
+		ORDER BY wr.relation_type, w.term`,
+		wordID, wordID, wordID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var related []RelatedWord
+	for rows.Next() {
+		var r RelatedWord
+		var sourceID int
+		word, err := scanWord(rowFunc(func(dest ...interface{}) error {
+			return rows.Scan(append([]interface{}{&r.RelationID, &r.RelationType, &sourceID}, dest...)...)
+		}))
+		if err != nil {
+			return nil, err
+		}
+		r.Word = *word
+		if !isSymmetricRelation(r.RelationType) {
+			r.Direction = RelationOutgoing
+			if sourceID != wordID {
+				r.Direction = RelationIncoming
+			}
+		}
+		related = append(related, r)
+	}
+
+	return related, rows.Err()
+}
+
+// CreateWordRelation links two words. For derived_from relations WordID is
+// derived from RelatedWordID.
+func CreateWordRelation(db *sql.DB, relation *WordRelation) error {
+	if !IsValidRelationType(relation.RelationType) {
+		return ErrInvalidRelationType
+	}
+	if relation.WordID == relation.RelatedWordID {
+		return ErrSelfRelation
+	}
+	normalizeRelation(relation)
+
+	// Check if both words exist
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM words WHERE id IN (?, ?)",
+		relation.WordID, relation.RelatedWordID).Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count != 2 {
+		return sql.ErrNoRows
+	}
+
+	if err := checkDuplicateRelation(db, relation); err != nil {
+		return err
+	}
+
+	result, err := db.Exec(`
+		INSERT INTO word_relations (word_id, related_word_id, relation_type, created_at)
+		VALUES (?, ?, ?, CURRENT_TIMESTAMP)`,
+		relation.WordID, relation.RelatedWordID, relation.RelationType)
+	if err != nil {
+		return relationConflict(err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	relation.ID = int(id)
+	relation.CreatedAt = time.Now()
+	return nil
+}
+
+// UpdateWordRelation changes the type of a relation belonging to wordID
+func UpdateWordRelation(db *sql.DB, wordID int, relation *WordRelation) error {
+	if !IsValidRelationType(relation.RelationType) {
+		return ErrInvalidRelationType
+	}
+
+	var previousType string
+	err := db.QueryRow(`
+		SELECT word_id, related_word_id, relation_type, created_at
+		FROM word_relations
+		WHERE id = ? AND (word_id = ? OR related_word_id = ?)`,
+		relation.ID, wordID, wordID).Scan(&relation.WordID, &relation.RelatedWordID, &previousType, &relation.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	// Make the requested word the source when switching to a directed type
+	if isSymmetricRelation(previousType) && !isSymmetricRelation(relation.RelationType) && relation.WordID != wordID {
+		relation.WordID, relation.RelatedWordID = relation.RelatedWordID, relation.WordID
+	}
+	normalizeRelation(relation)
+
+	if err := checkDuplicateRelation(db, relation); err != nil {
+		return err
+	}
+
+	_, err = db.Exec(`
+		UPDATE word_relations
+		SET word_id = ?, related_word_id = ?, relation_type = ?
+		WHERE id = ?`,
+		relation.WordID, relation.RelatedWordID, relation.RelationType, relation.ID)
+	return relationConflict(err)
+}
+
+// DeleteWordRelation removes a relation belonging to wordID
+func DeleteWordRelation(db *sql.DB, wordID, relationID int) error {
+	result, err := db.Exec(`
+		DELETE FROM word_relations
+		WHERE id = ? AND (word_id = ? OR related_word_id = ?)`,
+		relationID, wordID, wordID)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func checkDuplicateRelation(db *sql.DB, relation *WordRelation) error {
+	var exists bool
+	err := db.QueryRow(`
+		SELECT EXISTS(
+			SELECT 1 FROM word_relations
+			WHERE word_id = ? AND related_word_id = ? AND relation_type = ? AND id != ?
+		)`,
+		relation.WordID, relation.RelatedWordID, relation.RelationType, relation.ID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return ErrDuplicateRelation
+	}
+	return nil
+}
+
+// relationConflict reports a relation written concurrently with an equal one,
+// which the duplicate check cannot see, as ErrDuplicateRelation
+func relationConflict(err error) error {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+		return ErrDuplicateRelation
+	}
+	return err
+}
+
+// SuggestWordRelations finds homophones (same kana reading) and words that
+// share a kanji with the given word, skipping pairs that are already linked
+// with that relation type.
+func SuggestWordRelations(db *sql.DB, word *Word, existing []RelatedWord) ([]RelatedWord, error) {
+	reading := word.Reading()
+	kanji := make(map[string]bool)
+	for _, k := range language.KanjiIn(word.Term) {
+		kanji[k] = true
+	}
+	if reading == "" && len(kanji) == 0 {
+		return nil, nil
+	}
+
+	type link struct {
+		relationType string
+		wordID       int
+	}
+	linked := make(map[link]bool)
+	for _, r := range existing {
+		linked[link{r.RelationType, r.Word.ID}] = true
+	}
+
+	// Only read the candidates: words with the same reading or containing
+	// one of the kanji
+	var candidates []string
+	args := []interface{}{word.ID, word.Language}
+	if reading != "" {
+		candidates = append(candidates, "w.reading = ?")
+		args = append(args, reading)
+	}
+	for k := range kanji {
+		candidates = append(candidates, "w.term LIKE ?")
+		args = append(args, "%"+k+"%")
+	}
+	rows, err := db.Query(`
+		SELECT `+wordColumns+`
+		FROM words w
+		WHERE w.id != ? AND w.language = ? AND (`+strings.Join(candidates, " OR ")+`)
+		ORDER BY w.id`,
+		args...)
+	if err != nil {
+		return nil, err
+	}
+	words, err := scanWords(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	var suggestions []RelatedWord
+	for _, w := range words {
+		if reading != "" && w.Reading() == reading && !linked[link{RelationHomophone, w.ID}] {
+			suggestions = append(suggestions, RelatedWord{RelationType: RelationHomophone, Word: w})
+		}
+
+		if linked[link{RelationSameKanji, w.ID}] {
+			continue
+		}
+		for _, k := range language.KanjiIn(w.Term) {
+			if kanji[k] {
+				suggestions = append(suggestions, RelatedWord{RelationType: RelationSameKanji, Word: w})
+				break
+			}
+		}
+	}
+
+	return suggestions, nil
+}
@@ -1,6 +1,7 @@
 require 'rspec'
 require 'httparty'
 require 'json'
+require 'csv'
 
 def api_url
   'http://localhost:8080/api'
@@ -169,6 +170,47 @@ RSpec.describe 'Groups API' do
     end
   end
 
+  describe 'PATCH /groups/:id' do
+    before do
+      @group_id = HTTParty.post(
+        base_url,
+        body: valid_group.to_json,
+        headers: { 'Content-Type' => 'application/json' }
+      ).parsed_response['id']
+    end
+
+    it 'only changes the supplied fields' do
+      response = HTTParty.patch(
+        "#{base_url}/#{@group_id}",
+        body: { name: 'JLPT N5 Core' }.to_json,
+        headers: { 'Content-Type' => 'application/merge-patch+json' }
+      )
+      expect(response.code).to eq(200)
+      expect(response.parsed_response).to include(
+        'name' => 'JLPT N5 Core',
+        'description' => valid_group[:description]
+      )
+    end
+
+    it 'clears the description with null' do
+      response = HTTParty.patch(
+        "#{base_url}/#{@group_id}",
+        body: { description: nil }.to_json,
+        headers: { 'Content-Type' => 'application/merge-patch+json' }
+      )
+      expect(response.parsed_response['description']).to eq('')
+    end
+
+    it 'returns 404 for a missing group' do
+      response = HTTParty.patch(
+        "#{base_url}/999999",
+        body: { name: 'Missing' }.to_json,
+        headers: { 'Content-Type' => 'application/merge-patch+json' }
+      )
+      expect(response.code).to eq(404)
+    end
+  end
+
   describe 'DELETE /groups/:id' do
     context 'when group exists' do
       before do
@@ -338,3 +380,376 @@ RSpec.describe 'Groups API' do
     end
   end
 end
+
+RSpec.describe 'Smart Groups API' do
+  before(:all) do
+    # A part of speech no other spec uses, so the group only holds these words
+    @part = "particle-#{SecureRandom.hex(4)}"
+    @word_ids = [
+      create_word('は', 'wa', 'topic marker', parts: [@part.upcase]),
+      create_word('が', 'ga', 'subject marker', parts: ['noun', @part])
+    ]
+    create_word('払う', 'harau', 'to pay', parts: [{ kanji: '払う', romaji: %w[ha ra u] }])
+    @group_id = create_group('Particles', filter: { part_of_speech: @part })
+  end
+
+  it 'selects words by part of speech' do
+    words = HTTParty.get("#{api_url}/groups/#{@group_id}/words").parsed_response['items']
+    expect(words.map { |w| w['id'] }).to match_array(@word_ids)
+  end
+
+  it 'rejects unknown filter fields' do
+    expect(post_json('/groups', { name: 'Typo', filter: { part_of_speach: 'verb' } }).code).to eq(400)
+  end
+
+  it 'counts the words of smart groups in the group list' do
+    expect(HTTParty.get("#{api_url}/groups/#{@group_id}").parsed_response['word_count']).to eq(2)
+
+    groups = []
+    page = 1
+    loop do
+      response = HTTParty.get("#{api_url}/groups", query: { sort: 'word_count', order: 'desc', page: page }).parsed_response
+      groups.concat(response['items'])
+      break if page >= response['total_pages']
+
+      page += 1
+    end
+    expect(groups.find { |g| g['id'] == @group_id }['word_count']).to eq(2)
+    counts = groups.map { |g| g['word_count'] }
+    expect(counts).to eq(counts.sort.reverse)
+  end
+end
+
+RSpec.describe 'Group List API' do
+  before(:all) do
+    @group_id = create_group('Stats Group', description: 'Listed with stats', color: '#1e90ff', icon: 'star')
+    @word_ids = %w[上 下].map { |japanese| create_word(japanese) }
+    add_words_to_group(@group_id, @word_ids)
+    session = start_session(group_id: @group_id).parsed_response
+    post_to_session(session, "/words/#{@word_ids.first}/review", correct: true)
+  end
+
+  def all_groups(query = {})
+    groups = []
+    page = 1
+    loop do
+      response = HTTParty.get("#{api_url}/groups", query: query.merge(page: page)).parsed_response
+      groups.concat(response['items'])
+      break if page >= response['total_pages']
+
+      page += 1
+    end
+    groups
+  end
+
+  it 'lists groups with their metadata and stats' do
+    group = all_groups.find { |g| g['id'] == @group_id }
+    expect(group).to include(
+      'description' => 'Listed with stats', 'color' => '#1e90ff', 'icon' => 'star',
+      'word_count' => 2, 'study_session_count' => 1, 'success_rate' => 100
+    )
+    expect(group['last_studied_at']).not_to be_nil
+  end
+
+  it 'uses the same session count name as the group detail' do
+    detail = HTTParty.get("#{api_url}/groups/#{@group_id}").parsed_response
+    expect(detail['study_session_count']).to eq(1)
+  end
+
+  it 'sorts by study_session_count' do
+    counts = all_groups(sort: 'study_session_count', order: 'desc').map { |g| g['study_session_count'] }
+    expect(counts).to eq(counts.sort.reverse)
+  end
+
+  it 'rejects unknown sort fields' do
+    expect(HTTParty.get("#{api_url}/groups", query: { sort: 'session_count' }).code).to eq(400)
+  end
+end
+
+RSpec.describe 'Group Words API' do
+  before(:all) do
+    @group_id = create_group('Searchable Group')
+    @word_ids = [
+      create_word('全部', 'zenbu', '100% all'),
+      create_word('多分', 'tabun', 'perhaps', glosses: %w[perhaps maybe]),
+      create_word('下線', 'kasen', 'snake_case underline')
+    ]
+    add_words_to_group(@group_id, @word_ids)
+    session = start_session(group_id: @group_id).parsed_response
+    post_to_session(session, "/words/#{@word_ids[0]}/review", correct: true)
+    post_to_session(session, "/words/#{@word_ids[1]}/review", correct: false)
+  end
+
+  def group_words(query = {})
+    HTTParty.get("#{api_url}/groups/#{@group_id}/words", query: query)
+  end
+
+  def terms(query)
+    group_words(query).parsed_response['items'].map { |w| w['japanese'] }
+  end
+
+  it 'paginates the words' do
+    response = group_words(per_page: 2).parsed_response
+    expect(response['items'].length).to eq(2)
+    expect(response).to include('total_items' => 3, 'total_pages' => 2)
+  end
+
+  it 'matches search wildcards literally' do
+    expect(terms(search: '%')).to eq(['全部'])
+    expect(terms(search: '_')).to eq(['下線'])
+  end
+
+  it 'searches each gloss rather than the stored JSON' do
+    expect(terms(search: 'maybe')).to eq(['多分'])
+    expect(terms(search: '","')).to be_empty
+  end
+
+  it 'returns the accuracy of each word in the group' do
+    words = group_words(sort: 'correct_count', order: 'desc').parsed_response['items']
+    expect(words.first).to include('japanese' => '全部', 'correct_count' => 1, 'wrong_count' => 0, 'accuracy' => 100)
+    expect(words.find { |w| w['japanese'] == '多分' }).to include('wrong_count' => 1, 'accuracy' => 0)
+  end
+
+  it 'rejects unknown sort fields' do
+    expect(group_words(sort: 'glosses').code).to eq(400)
+  end
+
+  it 'returns 404 for a missing group' do
+    expect(HTTParty.get("#{api_url}/groups/999999/words").code).to eq(404)
+  end
+end
+
+RSpec.describe 'Group Report API' do
+  before(:all) do
+    @group_id = create_group('Report Group')
+    @word_ids = [
+      post_json('/words', { language: 'ko', term: '개', romanization: '-gae', glosses: ['dog'] }).parsed_response['id'],
+      create_word('猫', 'neko', 'cat')
+    ]
+    add_words_to_group(@group_id, @word_ids)
+    session = start_session(group_id: @group_id).parsed_response
+    post_to_session(session, "/words/#{@word_ids[0]}/review", correct: true)
+    post_to_session(session, "/words/#{@word_ids[1]}/review", correct: false)
+    @session_id = session['id']
+  end
+
+  it 'returns the mastery matrix as JSON' do
+    report = HTTParty.get("#{api_url}/groups/#{@group_id}/report", query: { weakest: 1 }).parsed_response
+    expect(report['sessions'].map { |s| s['id'] }).to eq([@session_id])
+    expect(report['words'].map { |w| w['results'] }).to eq([['correct'], ['incorrect']])
+    expect(report['weakest'].map { |w| w['word_id'] }).to eq([@word_ids[1]])
+    expect(report['mastery']).to eq(50)
+  end
+
+  it 'downloads the matrix as CSV with formulas escaped' do
+    response = HTTParty.get("#{api_url}/groups/#{@group_id}/report", query: { format: 'csv' })
+    expect(response.code).to eq(200)
+    expect(response.headers['content-type']).to start_with('text/csv')
+    rows = CSV.parse(response.body)
+    expect(rows.length).to eq(3)
+    expect(rows[1][0..3]).to eq([@word_ids[0].to_s, '개', "'-gae", 'correct'])
+  end
+
+  it 'rejects unknown formats' do
+    expect(HTTParty.get("#{api_url}/groups/#{@group_id}/report", query: { format: 'xml' }).code).to eq(400)
+  end
+
+  it 'adds a column for word list sessions over the group words' do
+    group_id = create_group('Word List Report Group')
+    word_ids = %w[朝 夜].map { |japanese| create_word(japanese) }
+    add_words_to_group(group_id, word_ids)
+    session = start_session(word_ids: word_ids).parsed_response
+    post_to_session(session, "/words/#{word_ids[0]}/review", correct: true)
+
+    response = HTTParty.get("#{api_url}/groups/#{group_id}/report")
+    expect(response.code).to eq(200)
+    report = response.parsed_response
+    expect(report['sessions'].map { |s| s['id'] }).to eq([session['id']])
+    expect(report['words'].map { |w| w['results'] }).to eq([['correct'], [nil]])
+    expect(report['words'].map { |w| [w['correct_count'], w['wrong_count']] }).to eq([[1, 0], [0, 0]])
+  end
+
+  it 'has a column for each session scope that reviewed the group' do
+    group_id = create_group('Mixed Scope Report Group')
+    other_id = create_group('Mixed Scope Other Group')
+    word_ids = %w[東 西].map { |japanese| create_word(japanese) }
+    add_words_to_group(group_id, word_ids)
+    add_words_to_group(other_id, [create_word('南')])
+
+    sessions = [
+      start_session(group_id: group_id),
+      start_session(group_ids: [group_id, other_id]),
+      start_session(word_ids: word_ids)
+    ].map(&:parsed_response)
+    sessions.each_with_index do |session, i|
+      post_to_session(session, "/words/#{word_ids[0]}/review", correct: i != 1)
+    end
+
+    report = HTTParty.get("#{api_url}/groups/#{group_id}/report").parsed_response
+    expect(report['sessions'].map { |s| s['id'] }).to eq(sessions.map { |s| s['id'] })
+    expect(report['words'][0]).to include('results' => %w[correct incorrect correct], 'correct_count' => 2, 'wrong_count' => 1)
+    expect(report['words'][1]).to include('results' => [nil, nil, nil], 'correct_count' => 0, 'wrong_count' => 0)
+  end
+end
+
+RSpec.describe 'Group Hierarchy API' do
+  before(:all) do
+    @course_id = create_group('Course')
+    @unit_id = create_group('Unit', parent_id: @course_id)
+    @lesson_id = create_group('Lesson', parent_id: @unit_id)
+    @word_ids = %w[一 二 三].map { |japanese| create_word(japanese) }
+    add_words_to_group(@lesson_id, @word_ids.first(2))
+    add_words_to_group(@unit_id, [@word_ids.last])
+    session = start_session(group_id: @lesson_id).parsed_response
+    post_to_session(session, "/words/#{@word_ids.first}/review", correct: true)
+  end
+
+  it 'lists the children of a group' do
+    children = HTTParty.get("#{api_url}/groups/#{@course_id}/children").parsed_response
+    expect(children.map { |g| g['id'] }).to eq([@unit_id])
+    expect(children.first['parent_id']).to eq(@course_id)
+  end
+
+  it 'rolls stats up over the whole subtree' do
+    course = HTTParty.get("#{api_url}/groups/#{@course_id}").parsed_response
+    expect(course).to include('word_count' => 3, 'study_session_count' => 1, 'success_rate' => 100, 'child_count' => 1)
+  end
+
+  it 'includes the words of descendants on request' do
+    course = HTTParty.get("#{api_url}/groups/#{@course_id}", query: { include_descendants: true }).parsed_response
+    expect(course['words'].map { |w| w['id'] }).to match_array(@word_ids)
+  end
+
+  it 'keeps the parent when a PUT renames a child group' do
+    parent_id = create_group('Parent')
+    child_id = create_group('Child', parent_id: parent_id)
+    response = HTTParty.put(
+      "#{api_url}/groups/#{child_id}",
+      body: { name: 'Child renamed' }.to_json,
+      headers: { 'Content-Type' => 'application/json' }
+    )
+    expect(response.code).to eq(200)
+    expect(response.parsed_response).to include('name' => 'Child renamed', 'parent_id' => parent_id)
+  end
+
+  it 'keeps the kind and filter when a PUT renames a smart group' do
+    smart_id = create_group('Smart Child', filter: { search: '一' })
+    response = HTTParty.put(
+      "#{api_url}/groups/#{smart_id}",
+      body: { name: 'Smart renamed' }.to_json,
+      headers: { 'Content-Type' => 'application/json' }
+    )
+    expect(response.code).to eq(200)
+    expect(response.parsed_response).to include('kind' => 'smart', 'filter' => { 'search' => '一' })
+  end
+
+  it 'prevents cycles' do
+    response = HTTParty.patch(
+      "#{api_url}/groups/#{@course_id}",
+      body: { parent_id: @lesson_id }.to_json,
+      headers: { 'Content-Type' => 'application/merge-patch+json' }
+    )
+    expect(response.code).to eq(400)
+  end
+end
+
+RSpec.describe 'Group Membership API' do
+  before(:all) do
+    @word_ids = %w[春 夏 秋].map { |japanese| create_word(japanese) }
+  end
+
+  def group_word_ids(group_id)
+    HTTParty.get("#{api_url}/groups/#{group_id}/words").parsed_response['items'].map { |w| w['id'] }
+  end
+
+  def send_words(method, group_id, word_ids)
+    HTTParty.send(
+      method,
+      "#{api_url}/groups/#{group_id}/words",
+      body: { word_ids: word_ids }.to_json,
+      headers: { 'Content-Type' => 'application/json' }
+    )
+  end
+
+  it 'adds words idempotently' do
+    group_id = create_group('Bulk Add')
+    expect(add_words_to_group(group_id, @word_ids.first(2)).parsed_response).to include('added' => 2, 'word_count' => 2)
+    expect(add_words_to_group(group_id, @word_ids.first(2)).parsed_response).to include('added' => 0, 'word_count' => 2)
+    expect(HTTParty.post("#{api_url}/groups/#{group_id}/words/#{@word_ids.first}").code).to eq(200)
+  end
+
+  it 'replaces and removes words' do
+    group_id = create_group('Bulk Replace')
+    add_words_to_group(group_id, @word_ids.first(2))
+    expect(send_words(:put, group_id, @word_ids.last(2)).parsed_response).to include('added' => 1, 'removed' => 1)
+    expect(send_words(:delete, group_id, [@word_ids.last, @word_ids.last]).parsed_response).to include('removed' => 1, 'word_count' => 1)
+    expect(group_word_ids(group_id)).to eq([@word_ids[1]])
+  end
+
+  it 'rejects unknown words' do
+    expect(add_words_to_group(create_group('Bulk Unknown'), [999_999]).code).to eq(400)
+  end
+
+  it 'clones a group with its words' do
+    group_id = create_group('Clone Source')
+    add_words_to_group(group_id, @word_ids)
+    clone = post_json("/groups/#{group_id}/clone", { name: 'Clone Copy' })
+    expect(clone.code).to eq(201)
+    expect(clone.parsed_response['name']).to eq('Clone Copy')
+    expect(group_word_ids(clone.parsed_response['id'])).to match_array(@word_ids)
+  end
+
+  it 'merges groups and deletes the sources' do
+    target_id = create_group('Merge Target')
+    source_ids = [create_group('Merge A'), create_group('Merge B')]
+    add_words_to_group(source_ids[0], @word_ids.first(2))
+    add_words_to_group(source_ids[1], @word_ids.last(2))
+    response = post_json("/groups/#{target_id}/merge", { group_ids: source_ids })
+    expect(response.parsed_response).to include('word_count' => 3)
+    source_ids.each { |id| expect(HTTParty.get("#{api_url}/groups/#{id}").code).to eq(404) }
+    expect(post_json("/groups/#{target_id}/merge", { group_ids: [target_id] }).code).to eq(400)
+  end
+
+  it 'points quizzes and smart filters at the merge target' do
+    target_id = create_group('Merge Quiz Target')
+    source_id = create_group('Merge Quiz Source')
+    add_words_to_group(source_id, @word_ids)
+    quiz = post_json('/quizzes', { group_id: source_id, study_activity_id: 1 }).parsed_response
+    smart_id = create_group('Merge Smart', kind: 'smart', filter: { in_groups: [source_id], not_in_groups: [source_id, target_id] })
+
+    post_json("/groups/#{target_id}/merge", { group_ids: [source_id] })
+    expect(HTTParty.get("#{api_url}/quizzes/#{quiz['id']}").parsed_response['group_id']).to eq(target_id)
+    expect(HTTParty.get("#{api_url}/groups/#{smart_id}").parsed_response['filter']).to eq(
+      'in_groups' => [target_id], 'not_in_groups' => [target_id]
+    )
+  end
+
+  it 'drops quizzes and smart filter references when deleting a group' do
+    kept_id = create_group('Delete Kept')
+    deleted_id = create_group('Delete Removed')
+    add_words_to_group(deleted_id, @word_ids)
+    quiz = post_json('/quizzes', { group_id: deleted_id, study_activity_id: 1 }).parsed_response
+    smart_id = create_group('Delete Smart', kind: 'smart', filter: { in_groups: [deleted_id, kept_id], not_in_groups: [deleted_id] })
+
+    expect(HTTParty.delete("#{api_url}/groups/#{deleted_id}").code).to eq(200)
+    expect(HTTParty.get("#{api_url}/quizzes/#{quiz['id']}").code).to eq(404)
+    expect(HTTParty.get("#{api_url}/groups/#{smart_id}").parsed_response['filter']).to eq('in_groups' => [kept_id])
+  end
+
+  it 'combines groups into a new group' do
+    a = create_group('Set A')
+    b = create_group('Set B')
+    add_words_to_group(a, @word_ids.first(2))
+    add_words_to_group(b, @word_ids.last(2))
+    expected = {
+      'union' => @word_ids,
+      'intersection' => [@word_ids[1]],
+      'difference' => [@word_ids[0]]
+    }
+    expected.each do |operation, word_ids|
+      response = post_json("/groups/#{operation}", { group_ids: [a, b], name: "Set #{operation}" })
+      expect(response.code).to eq(201)
+      expect(group_word_ids(response.parsed_response['id'])).to match_array(word_ids)
+    end
+  end
+end
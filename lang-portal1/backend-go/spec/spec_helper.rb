@@ -35,6 +35,37 @@ module ApiHelper
     schema = JSON.parse(File.read(response_schema_file(schema_name)))
     JSON::Validator.validate!(schema, response)
   end
+
+  def post_json(path, body, headers = {})
+    HTTParty.post(
+      "#{api_url}#{path}",
+      body: body.to_json,
+      headers: { 'Content-Type' => 'application/json' }.merge(headers)
+    )
+  end
+
+  # Fixtures, returning the ID of what they create
+  def create_group(name, params = {})
+    post_json('/groups', { name: name }.merge(params)).parsed_response['id']
+  end
+
+  def create_word(japanese, romaji = 'romaji', english = japanese, params = {})
+    post_json('/words', { japanese: japanese, romaji: romaji, english: english }.merge(params)).parsed_response['id']
+  end
+
+  def add_words_to_group(group_id, word_ids)
+    post_json("/groups/#{group_id}/words", { word_ids: word_ids })
+  end
+
+  # Starts a session of the seeded activity, returning the response
+  def start_session(params)
+    post_json('/study_sessions', { study_activity_id: 1 }.merge(params))
+  end
+
+  # Posts to a session with the token it was started with
+  def post_to_session(session, path, body = {})
+    post_json("/study_sessions/#{session['id']}#{path}", body, 'Authorization' => "Bearer #{session['token']}")
+  end
 end
 
 RSpec.configure do |config|
@@ -262,3 +262,508 @@ RSpec.describe 'Study API' do
     end
   end
 end
+
+RSpec.describe 'Study Activities API' do
+  let(:base_url) { "#{api_url}/study_activities" }
+
+  describe 'GET /study_activities' do
+    before do
+      @response = HTTParty.get(base_url, query: { enabled: true })
+    end
+
+    it 'returns the enabled activities of the catalog' do
+      expect(@response.code).to eq(200)
+      expect(@response.parsed_response['items']).to all(include('name', 'launch_url', 'enabled' => true))
+    end
+  end
+
+  describe 'POST /study_activities' do
+    it 'creates an activity' do
+      response = HTTParty.post(
+        base_url,
+        body: { name: "Spec Activity #{Time.now.to_f}", launch_url: 'http://localhost:9000' }.to_json,
+        headers: { 'Content-Type' => 'application/json' }
+      )
+      expect(response.code).to eq(201)
+      expect(response.parsed_response).to include('id', 'enabled' => true)
+    end
+
+    it 'rejects launch URLs that are not http(s)' do
+      response = HTTParty.post(
+        base_url,
+        body: { name: 'Spec Activity', launch_url: 'ftp://localhost' }.to_json,
+        headers: { 'Content-Type' => 'application/json' }
+      )
+      expect(response.code).to eq(400)
+    end
+
+    it 'still starts study sessions for bodies with a study_activity_id' do
+      group_id = create_group('Activity Group')
+      response = post_json('/study_activities', { group_id: group_id, study_activity_id: 1 })
+      expect(response.code).to eq(201)
+      expect(response.headers['deprecation']).to eq('true')
+      expect(response.headers['link']).to include('/api/study_sessions')
+      expect(JSON.parse(response.body)['group_id']).to eq(group_id)
+    end
+  end
+
+  describe 'DELETE /study_activities/:id' do
+    it 'returns 404 for unknown activities' do
+      expect(HTTParty.delete("#{base_url}/999999").code).to eq(404)
+    end
+  end
+end
+
+RSpec.describe 'Study Activity Launch API' do
+  before(:all) do
+    group_response = HTTParty.post(
+      "#{api_url}/groups",
+      body: { name: 'Launch Group' }.to_json,
+      headers: { 'Content-Type' => 'application/json' }
+    )
+    @group_id = group_response.parsed_response['id']
+    @launch = HTTParty.post(
+      "#{api_url}/study_activities/1/launch",
+      body: { group_id: @group_id }.to_json,
+      headers: { 'Content-Type' => 'application/json' }
+    )
+  end
+
+  it 'returns the filled in launch URL and a token' do
+    expect(@launch.code).to eq(201)
+    expect(@launch.parsed_response['launch_url']).to include("group_id=#{@group_id}", 'token=')
+  end
+
+  it 'rejects the token for other study sessions' do
+    session_id = @launch.parsed_response['session']['id']
+    response = HTTParty.post(
+      "#{api_url}/study_sessions/#{session_id + 1}/words/1/review",
+      body: { correct: true }.to_json,
+      headers: { 'Content-Type' => 'application/json', 'Authorization' => "Bearer #{@launch.parsed_response['token']}" }
+    )
+    expect(response.code).to eq(403)
+  end
+end
+
+RSpec.describe 'Word Review API' do
+  before(:all) do
+    group_id = create_group('Review Group')
+    @word_id = create_word('猫', 'neko', 'cat')
+    @session = start_session(group_id: group_id).parsed_response
+  end
+
+  it 'returns a token scoped to the new session' do
+    expect(@session['token']).to start_with("#{@session['id']}.")
+  end
+
+  it 'rejects reviews without the session token' do
+    response = post_json("/study_sessions/#{@session['id']}/words/#{@word_id}/review", { correct: true })
+    expect(response.code).to eq(401)
+  end
+
+  it 'rejects the token in the query string' do
+    response = post_json("/study_sessions/#{@session['id']}/words/#{@word_id}/review?token=#{@session['token']}", { correct: true })
+    expect(response.code).to eq(401)
+  end
+
+  it 'records wrong answers with their details' do
+    response = post_to_session(
+      @session, "/words/#{@word_id}/review",
+      { correct: false, answer: 'inu', response_time_ms: 3200, hint_used: true }
+    )
+    expect(response.code).to eq(201)
+    expect(response.parsed_response).to include('correct' => false, 'answer' => 'inu', 'response_time_ms' => 3200)
+  end
+
+  it 'derives correct from the quality grade' do
+    response = post_to_session(@session, "/words/#{@word_id}/review", { quality: 4 })
+    expect(response.parsed_response).to include('correct' => true, 'attempt_number' => 2)
+  end
+end
+
+RSpec.describe 'Review Scheduling API' do
+  before(:all) do
+    @word_id = create_word('犬', 'inu', 'dog')
+    @group_id = create_group('Scheduling Group')
+    add_words_to_group(@group_id, [@word_id])
+    @session = start_session(group_id: @group_id).parsed_response
+  end
+
+  it 'offers new words until they are reviewed' do
+    response = HTTParty.get("#{api_url}/review/due?group_id=#{@group_id}&new=5")
+    expect(response.parsed_response['items'].map { |w| w['id'] }).to include(@word_id)
+  end
+
+  it 'schedules a reviewed word for a later day' do
+    post_to_session(@session, "/words/#{@word_id}/review", { correct: true })
+    due = HTTParty.get("#{api_url}/review/due?group_id=#{@group_id}&new=5")
+    expect(due.parsed_response['items']).to be_empty
+    forecast = HTTParty.get("#{api_url}/review/forecast?group_id=#{@group_id}&days=3")
+    expect(forecast.parsed_response['days'][1]['due_count']).to eq(1)
+  end
+end
+
+RSpec.describe 'Leeches API' do
+  before(:all) do
+    @word_id = create_word('鳥', 'tori', 'bird')
+    @group_id = create_group('Leech Group')
+    4.times do
+      session = start_session(group_id: @group_id).parsed_response
+      post_to_session(session, "/words/#{@word_id}/review", { correct: false })
+    end
+  end
+
+  it 'lists words failed four times in a row' do
+    response = HTTParty.get("#{api_url}/words/leeches")
+    leech = response.parsed_response['items'].find { |w| w['id'] == @word_id }
+    expect(leech).to include('consecutive_failures' => 4)
+  end
+
+  it 'counts leeches on the dashboard' do
+    response = HTTParty.get("#{api_url}/dashboard/quick-stats")
+    expect(response.parsed_response['leech_count']).to be >= 1
+  end
+
+  it 'restores the failure counts when a review is undone' do
+    word_id = create_word('虫', 'mushi', 'insect')
+    sessions = Array.new(4) { start_session(group_id: @group_id).parsed_response }
+    sessions.each { |session| post_to_session(session, "/words/#{word_id}/review", { correct: false }) }
+    HTTParty.delete(
+      "#{api_url}/study_sessions/#{sessions.last['id']}/reviews/last",
+      headers: { 'Authorization' => "Bearer #{sessions.last['token']}" }
+    )
+    leeches = HTTParty.get("#{api_url}/words/leeches").parsed_response['items']
+    expect(leeches.map { |w| w['id'] }).not_to include(word_id)
+  end
+
+  it 'unsuspends words' do
+    response = HTTParty.post("#{api_url}/words/#{@word_id}/unsuspend")
+    expect(response.code).to eq(200)
+    expect(response.parsed_response).not_to have_key('suspended_at')
+  end
+end
+
+RSpec.describe 'Quizzes API' do
+  before(:all) do
+    @group_id = create_group('Quiz Group')
+    word_ids = [%w[食べる taberu to\ eat], %w[飲む nomu to\ drink], %w[食事 shokuji meal]].map do |japanese, romaji, english|
+      create_word(japanese, romaji, english)
+    end
+    add_words_to_group(@group_id, word_ids)
+  end
+
+  it 'generates questions with shuffled options' do
+    response = post_json('/quizzes', { group_id: @group_id, direction: 'jp_en', count: 3, options: 3, study_activity_id: 1 })
+    expect(response.code).to eq(201)
+    expect(response.parsed_response['questions'].length).to eq(3)
+    expect(response.parsed_response['questions'].first['options'].length).to eq(3)
+    expect(response.parsed_response['questions'].first).not_to have_key('correct_option')
+  end
+
+  it 'records answers as reviews in the linked session' do
+    quiz = post_json('/quizzes', { group_id: @group_id, study_activity_id: 1 }).parsed_response
+    session = { 'id' => quiz['study_session_id'], 'token' => quiz['token'] }
+    response = post_to_session(session, "/quizzes/#{quiz['id']}/questions/1/answer", { option: 0 })
+    expect(response.parsed_response['review']['study_session_id']).to eq(quiz['study_session_id'])
+    again = post_to_session(session, "/quizzes/#{quiz['id']}/questions/1/answer", { option: 0 })
+    expect(again.code).to eq(409)
+    reviews = HTTParty.get("#{api_url}/study_sessions/#{session['id']}/words").parsed_response
+    expect(reviews['summary']['correct_count'] + reviews['summary']['wrong_count']).to eq(1)
+  end
+
+  it 'rejects sessions of other groups' do
+    other_session = start_session(group_id: create_group('Other Quiz Group')).parsed_response
+    response = post_json('/quizzes', { group_id: @group_id, study_session_id: other_session['id'] })
+    expect(response.code).to eq(400)
+  end
+
+  it 'does not answer quizzes of other sessions' do
+    quiz = post_json('/quizzes', { group_id: @group_id, study_activity_id: 1 }).parsed_response
+    other_session = start_session(group_id: @group_id).parsed_response
+    response = post_to_session(other_session, "/quizzes/#{quiz['id']}/questions/1/answer", { option: 0 })
+    expect(response.code).to eq(404)
+  end
+end
+
+RSpec.describe 'Answer Grading API' do
+  before(:all) do
+    @word_id = post_json('/words', { language: 'ja', term: '東京', romanization: 'toukyou', glosses: ['Tokyo'] }).parsed_response['id']
+  end
+
+  def grade(answer, expect = 'reading')
+    post_json('/grade', { word_id: @word_id, answer: answer, expect: expect }).parsed_response
+  end
+
+  it 'accepts katakana and romaji variants' do
+    expect(grade('トウキョウ')['result']).to eq('correct')
+    expect(grade('tōkyō')['result']).to eq('correct')
+  end
+
+  it 'reports the vowel length as partial with a diff' do
+    result = grade('tokyo')
+    expect(result).to include('result' => 'partial', 'reason' => 'vowel_length')
+    expect(result['diff']).to include('op' => 'missing', 'text' => 'ー')
+  end
+
+  it 'accepts the gloss as meaning' do
+    expect(grade('tokyo', 'meaning')['result']).to eq('correct')
+  end
+
+  it 'records graded answers only through the session routes' do
+    session = start_session(word_ids: [@word_id]).parsed_response
+    path = "/study_sessions/#{session['id']}/words/#{@word_id}/grade"
+    expect(post_json(path, { answer: 'tokyo', expect: 'reading' }).code).to eq(401)
+
+    response = post_to_session(session, path, { answer: 'tokyo', expect: 'reading' })
+    expect(response.code).to eq(201)
+    expect(response.parsed_response['review']).to include('correct' => true, 'quality' => 3)
+  end
+end
+
+RSpec.describe 'Session Queue API' do
+  before(:all) do
+    @group_id = create_group('Queue Group')
+    add_words_to_group(@group_id, %w[一 二 三 四].map { |japanese| create_word(japanese) })
+  end
+
+  def queue_word_ids(session_id)
+    HTTParty.get("#{api_url}/study_sessions/#{session_id}/queue")
+      .parsed_response['items'].map { |item| item['id'] }
+  end
+
+  it 'rebuilds the same random queue from a seed' do
+    first = start_session(group_id: @group_id, strategy: 'random', seed: 7).parsed_response['id']
+    second = start_session(group_id: @group_id, strategy: 'random', seed: 7).parsed_response['id']
+    expect(queue_word_ids(first)).to eq(queue_word_ids(second))
+  end
+
+  it 'caps the queue at size and tracks reviewed words' do
+    session = start_session(group_id: @group_id, size: 2).parsed_response
+    expect(session['queue_size']).to eq(2)
+    word_id = queue_word_ids(session['id']).first
+    post_to_session(session, "/words/#{word_id}/review", { correct: true })
+    queue = HTTParty.get("#{api_url}/study_sessions/#{session['id']}/queue").parsed_response
+    expect(queue['remaining']).to eq(1)
+  end
+
+  it 'rejects unknown strategies' do
+    expect(start_session(group_id: @group_id, strategy: 'alphabetical').code).to eq(400)
+  end
+end
+
+RSpec.describe 'Session Scopes API' do
+  before(:all) do
+    @group_ids = ['Lesson 1', 'Lesson 2'].map { |name| create_group(name) }
+    @word_ids = %w[行く 来る].map { |japanese| create_word(japanese) }
+    @group_ids.zip(@word_ids).each do |group_id, word_id|
+      add_words_to_group(group_id, [word_id])
+    end
+  end
+
+  it 'starts a session over several groups' do
+    response = start_session(group_ids: @group_ids)
+    expect(response.code).to eq(201)
+    expect(response.parsed_response).to include('group_id' => nil, 'scope' => 'groups', 'group_ids' => @group_ids)
+    expect(response.parsed_response['queue_size']).to eq(2)
+  end
+
+  it 'lists multi-group sessions under each of their groups' do
+    session_id = start_session(group_ids: @group_ids).parsed_response['id']
+    @group_ids.each do |group_id|
+      sessions = HTTParty.get("#{api_url}/groups/#{group_id}/study_sessions").parsed_response['items']
+      expect(sessions.map { |s| s['id'] }).to include(session_id)
+    end
+  end
+
+  it 'starts sessions over a word list or a query' do
+    expect(start_session(word_ids: @word_ids).parsed_response['scope']).to eq('words')
+    expect(start_session(query: { search: '行く' }).parsed_response['scope']).to eq('query')
+  end
+
+  it 'requires exactly one target' do
+    expect(start_session(group_id: @group_ids.first, word_ids: @word_ids).code).to eq(400)
+  end
+
+  it 'counts word list reviews for the groups of the word' do
+    group_id = create_group('Attribution Group')
+    word_id = create_word('帰る', 'kaeru', 'to return')
+    add_words_to_group(group_id, [word_id])
+    session = start_session(word_ids: [word_id]).parsed_response
+    post_to_session(session, "/words/#{word_id}/review", correct: true)
+    post_to_session(session, "/words/#{word_id}/review", correct: false)
+
+    last = HTTParty.get("#{api_url}/dashboard/last_study_session").parsed_response
+    expect(last).to include('id' => session['id'], 'correct_count' => 1, 'total_count' => 2)
+    group = HTTParty.get("#{api_url}/groups/#{group_id}").parsed_response
+    expect(group['success_rate']).to eq(50)
+  end
+end
+
+RSpec.describe 'Review Batch API' do
+  before(:all) do
+    @group_id = create_group('Batch Group')
+    @word_id = create_word('魚', 'sakana', 'fish')
+    add_words_to_group(@group_id, [@word_id])
+  end
+
+  it 'records a batch once per client ID' do
+    session = start_session(group_id: @group_id).parsed_response
+    batch = [{ word_id: @word_id, correct: true, client_id: 'batch-1' }]
+    expect(post_to_session(session, '/reviews', batch).parsed_response).to include('created' => 1)
+    expect(post_to_session(session, '/reviews', batch).parsed_response).to include('created' => 0, 'duplicates' => 1)
+  end
+
+  it 'records nothing when an item is invalid' do
+    session = start_session(group_id: @group_id).parsed_response
+    response = post_to_session(session, '/reviews', [{ word_id: @word_id, correct: true }, { word_id: 999_999, correct: true }])
+    expect(response.code).to eq(400)
+    expect(response.parsed_response['results'].map { |r| r['status'] }).to eq(%w[skipped invalid])
+  end
+
+  it 'rejects review times outside the session' do
+    session = start_session(group_id: @group_id).parsed_response
+    [1.day.ago, 1.day.from_now].each do |time|
+      response = post_to_session(session, '/reviews', [{ word_id: @word_id, correct: true, reviewed_at: time.utc.iso8601 }])
+      expect(response.code).to eq(400)
+    end
+  end
+
+  it 'accepts timed batches for completed sessions' do
+    session = start_session(group_id: @group_id).parsed_response
+    post_to_session(session, '/complete')
+    expect(post_to_session(session, '/reviews', [{ word_id: @word_id, correct: true }]).code).to eq(400)
+    response = post_to_session(session, '/reviews', [{ word_id: @word_id, correct: true, reviewed_at: Time.now.utc.iso8601 }])
+    expect(response.code).to eq(200)
+  end
+
+  it 'rejects batches for abandoned sessions' do
+    session = start_session(group_id: @group_id).parsed_response
+    post_to_session(session, '/abandon')
+    response = post_to_session(session, '/reviews', [{ word_id: @word_id, correct: true, reviewed_at: Time.now.utc.iso8601 }])
+    expect(response.code).to eq(409)
+  end
+end
+
+RSpec.describe 'Session Lifecycle API' do
+  before(:all) do
+    @group_id = create_group('Lifecycle Group')
+    @word_id = create_word('花', 'hana', 'flower')
+    add_words_to_group(@group_id, [@word_id])
+  end
+
+  it 'completes active sessions' do
+    session = start_session(group_id: @group_id).parsed_response
+    expect(session['status']).to eq('active')
+    response = post_to_session(session, '/complete')
+    expect(response.code).to eq(200)
+    expect(response.parsed_response).to include('status' => 'completed')
+    expect(response.parsed_response['completed_at']).not_to be_nil
+  end
+
+  it 'abandons active sessions' do
+    session = start_session(group_id: @group_id).parsed_response
+    response = post_to_session(session, '/abandon')
+    expect(response.code).to eq(200)
+    expect(response.parsed_response).to include('status' => 'abandoned')
+  end
+
+  it 'returns 409 for reviews and closing once closed' do
+    session = start_session(group_id: @group_id).parsed_response
+    post_to_session(session, '/complete')
+    expect(post_to_session(session, "/words/#{@word_id}/review", { correct: true }).code).to eq(409)
+    expect(post_to_session(session, '/abandon').code).to eq(409)
+    expect(HTTParty.delete(
+      "#{api_url}/study_sessions/#{session['id']}/reviews/last",
+      headers: { 'Authorization' => "Bearer #{session['token']}" }
+    ).code).to eq(409)
+  end
+end
+
+RSpec.describe 'Review Attempts API' do
+  before(:all) do
+    @group_id = create_group('Attempts Group')
+    @word_id = create_word('空', 'sora', 'sky')
+    add_words_to_group(@group_id, [@word_id])
+  end
+
+  def session_reviews(session)
+    HTTParty.get("#{api_url}/study_sessions/#{session['id']}/words").parsed_response['items']
+  end
+
+  it 'numbers repeated reviews as further attempts' do
+    session = start_session(group_id: @group_id).parsed_response
+    first = post_to_session(session, "/words/#{@word_id}/review", { correct: false }).parsed_response
+    second = post_to_session(session, "/words/#{@word_id}/review", { correct: true }).parsed_response
+    expect([first['attempt_number'], second['attempt_number']]).to eq([1, 2])
+  end
+
+  it 'orders attempts by when they were made' do
+    session = start_session(group_id: @group_id).parsed_response
+    sleep 1
+    post_to_session(session, "/words/#{@word_id}/review", { correct: true })
+    post_to_session(session, '/reviews', [{ word_id: @word_id, correct: false, reviewed_at: session['created_at'] }])
+    attempts = session_reviews(session).to_h { |review| [review['attempt_number'], review['correct']] }
+    expect(attempts).to eq(1 => false, 2 => true)
+  end
+
+  it 'counts only first attempts with attempts=first' do
+    session = start_session(group_id: @group_id).parsed_response
+    post_to_session(session, "/words/#{@word_id}/review", { correct: false })
+    post_to_session(session, "/words/#{@word_id}/review", { correct: true })
+    all = HTTParty.get("#{api_url}/study_sessions/#{session['id']}/words").parsed_response['summary']
+    first = HTTParty.get("#{api_url}/study_sessions/#{session['id']}/words?attempts=first").parsed_response['summary']
+    expect(all).to include('correct_count' => 1, 'wrong_count' => 1)
+    expect(first).to include('correct_count' => 0, 'wrong_count' => 1)
+  end
+
+  it 'renumbers attempts when one is undone' do
+    session = start_session(group_id: @group_id).parsed_response
+    sleep 1
+    post_to_session(session, "/words/#{@word_id}/review", { correct: true })
+    post_to_session(session, '/reviews', [{ word_id: @word_id, correct: false, reviewed_at: session['created_at'] }])
+    HTTParty.delete(
+      "#{api_url}/study_sessions/#{session['id']}/reviews/last",
+      headers: { 'Authorization' => "Bearer #{session['token']}" }
+    )
+    expect(session_reviews(session).map { |review| review['attempt_number'] }).to eq([1])
+  end
+end
+
+RSpec.describe 'Session Words API' do
+  before(:all) do
+    @group_id = create_group('Session Words Group')
+    @word_ids = [create_word('月', 'tsuki', 'moon'), create_word('星', 'hoshi', 'star'), create_word('空', 'sora', 'sky')]
+    add_words_to_group(@group_id, @word_ids)
+    @session = start_session(group_id: @group_id).parsed_response
+    post_to_session(@session, "/words/#{@word_ids[0]}/review", correct: true)
+    post_to_session(@session, "/words/#{@word_ids[1]}/review", correct: false)
+  end
+
+  def session_words(query = {})
+    HTTParty.get("#{api_url}/study_sessions/#{@session['id']}/words", query: query)
+  end
+
+  it 'lists the reviews of the session, oldest first' do
+    items = session_words.parsed_response['items']
+    expect(items.map { |w| w['id'] }).to eq(@word_ids.first(2))
+    expect(items.first).to include('japanese' => '月', 'romaji' => 'tsuki', 'english' => 'moon', 'correct' => true, 'result' => 'correct')
+    expect(items.first['reviewed_at']).not_to be_nil
+    expect(items.last).to include('correct' => false, 'result' => 'incorrect')
+  end
+
+  it 'paginates the reviews' do
+    response = session_words(per_page: 1).parsed_response
+    expect(response['items'].length).to eq(1)
+    expect(response).to include('total_items' => 2, 'total_pages' => 2)
+  end
+
+  it 'summarizes the session' do
+    summary = session_words.parsed_response['summary']
+    expect(summary).to include('correct_count' => 1, 'wrong_count' => 1, 'accuracy' => 50)
+    expect(summary['unreviewed_words'].map { |w| w['id'] }).to eq([@word_ids[2]])
+  end
+
+  it 'returns 404 for unknown sessions' do
+    expect(HTTParty.get("#{api_url}/study_sessions/999999/words").code).to eq(404)
+  end
+end
@@ -1,6 +1,7 @@
 require 'rspec'
 require 'httparty'
 require 'json'
+require 'tempfile'
 
 def api_url
   'http://localhost:8080/api'
@@ -205,4 +206,204 @@ RSpec.describe 'Words API' do
       end
     end
   end
+
+  describe 'word relations' do
+    before do
+      @bridge_id = HTTParty.post(
+        base_url,
+        body: { japanese: '橋', romaji: 'hashi', english: 'bridge', parts: [{ kanji: '橋', romaji: %w[ha shi] }] }.to_json,
+        headers: { 'Content-Type' => 'application/json' }
+      ).parsed_response['id']
+      @chopsticks_id = HTTParty.post(
+        base_url,
+        body: { japanese: '箸', romaji: 'hashi', english: 'chopsticks', parts: [{ kanji: '箸', romaji: %w[ha shi] }] }.to_json,
+        headers: { 'Content-Type' => 'application/json' }
+      ).parsed_response['id']
+    end
+
+    it 'suggests homophones from the readings in parts' do
+      response = HTTParty.get("#{base_url}/#{@bridge_id}")
+      suggested = response.parsed_response['suggested_relations'].map { |r| [r['relation_type'], r['word']['id']] }
+      expect(suggested).to include(['homophone', @chopsticks_id])
+    end
+
+    it 'creates, lists and deletes a relation' do
+      create_response = HTTParty.post(
+        "#{base_url}/#{@bridge_id}/relations",
+        body: { related_word_id: @chopsticks_id, relation_type: 'homophone' }.to_json,
+        headers: { 'Content-Type' => 'application/json' }
+      )
+      expect(create_response.code).to eq(201)
+
+      list_response = HTTParty.get("#{base_url}/#{@chopsticks_id}/relations")
+      expect(list_response.parsed_response.map { |r| r['word']['id'] }).to include(@bridge_id)
+
+      delete_response = HTTParty.delete("#{base_url}/#{@bridge_id}/relations/#{create_response.parsed_response['id']}")
+      expect(delete_response.code).to eq(204)
+    end
+
+    it 'rejects unknown relation types' do
+      response = HTTParty.post(
+        "#{base_url}/#{@bridge_id}/relations",
+        body: { related_word_id: @chopsticks_id, relation_type: 'cousin' }.to_json,
+        headers: { 'Content-Type' => 'application/json' }
+      )
+      expect(response.code).to eq(400)
+    end
+  end
+
+  describe 'languages' do
+    before do
+      @korean_id = HTTParty.post(
+        base_url,
+        body: { language: 'ko', term: '한국어', glosses: ['Korean language'] }.to_json,
+        headers: { 'Content-Type' => 'application/json' }
+      ).parsed_response['id']
+    end
+
+    it 'romanizes terms when no romanization is given' do
+      response = HTTParty.get("#{base_url}/#{@korean_id}")
+      expect(response.parsed_response).to include('language' => 'ko', 'romanization' => 'hangugeo')
+    end
+
+    it 'keeps the legacy fields for Japanese words only' do
+      japanese = post_json('/words', { language: 'ja', term: 'こんにちは', glosses: ['hello'] }).parsed_response
+      expect { validate_schema(japanese, 'word') }.not_to raise_error
+      korean = HTTParty.get("#{base_url}/#{@korean_id}").parsed_response
+      expect(korean.keys).not_to include('japanese', 'romaji', 'english')
+    end
+
+    it 'filters word lists by language' do
+      response = HTTParty.get(base_url, query: { language: 'ko', per_page: 1000 })
+      expect(response.parsed_response['items'].map { |w| w['language'] }.uniq).to eq(['ko'])
+    end
+
+    it 'rejects terms outside the language script' do
+      response = HTTParty.post(
+        base_url,
+        body: { language: 'ko', term: 'hello', glosses: ['hello'] }.to_json,
+        headers: { 'Content-Type' => 'application/json' }
+      )
+      expect(response.code).to eq(400)
+    end
+  end
+
+  describe 'furigana' do
+    before do
+      @word_id = HTTParty.post(
+        base_url,
+        body: {
+          japanese: '払う', romaji: 'harau', english: 'to pay',
+          parts: [{ kanji: '払う', romaji: %w[ha ra u] }]
+        }.to_json,
+        headers: { 'Content-Type' => 'application/json' }
+      ).parsed_response['id']
+    end
+
+    it 'renders ruby markup by default' do
+      response = HTTParty.get("#{base_url}/#{@word_id}/furigana")
+      expect(response.code).to eq(200)
+      expect(response.parsed_response['furigana']).to eq('<ruby>払<rt>はら</rt></ruby>う')
+    end
+
+    it 'renders Anki bracket notation' do
+      response = HTTParty.get("#{base_url}/#{@word_id}/furigana", query: { format: 'anki' })
+      expect(response.parsed_response['furigana']).to eq('払[はら]う')
+    end
+
+    it 'rejects unknown formats' do
+      response = HTTParty.get("#{base_url}/#{@word_id}/furigana", query: { format: 'xml' })
+      expect(response.code).to eq(400)
+    end
+  end
+end
+
+RSpec.describe 'Word Media API' do
+  # An MP3 frame without an ID3 tag, which sniffing alone cannot recognize
+  let(:mp3) { [0xFF, 0xFB, 0x90, 0x64].pack('C*') + ("\0" * 400) + SecureRandom.hex(8) }
+
+  before(:all) do
+    @word_ids = %w[鳥 馬].map { |japanese| create_word(japanese) }
+  end
+
+  def upload(word_id, content, filename)
+    file = Tempfile.new(['media', File.extname(filename)])
+    file.binmode
+    file.write(content)
+    file.rewind
+    HTTParty.post("#{api_url}/words/#{word_id}/media", body: { file: file })
+  ensure
+    file.close!
+  end
+
+  def delete_media(word_id, media_id)
+    HTTParty.delete("#{api_url}/words/#{word_id}/media/#{media_id}")
+  end
+
+  it 'accepts MP3 files by their extension' do
+    response = upload(@word_ids.first, mp3, 'word.mp3')
+    expect(response.code).to eq(201)
+    expect(response.parsed_response).to include('kind' => 'audio', 'mime_type' => 'audio/mpeg')
+  end
+
+  it 'rejects files that are not images or audio' do
+    expect(upload(@word_ids.first, 'plain text', 'notes.txt').code).to eq(415)
+    expect(upload(@word_ids.first, '<html><script></script></html>', 'image.png').code).to eq(415)
+  end
+
+  it 'stores identical uploads once' do
+    content = mp3
+    hashes = @word_ids.map { |word_id| upload(word_id, content, 'word.mp3').parsed_response['hash'] }
+    expect(hashes.uniq.length).to eq(1)
+  end
+
+  it 'keeps a shared file until the last word using it drops it' do
+    content = mp3
+    items = @word_ids.map { |word_id| upload(word_id, content, 'word.mp3').parsed_response }
+    media_url = "#{api_url}/media/#{items.first['hash']}"
+
+    expect(delete_media(@word_ids.first, items.first['id']).code).to eq(204)
+    expect(HTTParty.get(media_url).code).to eq(200)
+    expect(delete_media(@word_ids.last, items.last['id']).code).to eq(204)
+    expect(HTTParty.get(media_url).code).to eq(404)
+  end
+
+  it 'returns 404 for unknown words' do
+    expect(upload(999_999, mp3, 'word.mp3').code).to eq(404)
+  end
+end
+
+# Needs the server started with TTS_PROVIDER=fake
+RSpec.describe 'Word Audio API' do
+  before(:all) do
+    @word_id = create_word('雨', 'ame', 'rain')
+    @group_id = create_group('Audio Group')
+    add_words_to_group(@group_id, [@word_id])
+  end
+
+  before do
+    skip 'text-to-speech is not enabled' if HTTParty.get("#{api_url}/words/#{@word_id}/audio").code == 503
+  end
+
+  it 'generates the same speech for the same text' do
+    first = HTTParty.post("#{api_url}/words/#{@word_id}/audio", query: { force: true })
+    second = HTTParty.post("#{api_url}/words/#{@word_id}/audio", query: { force: true })
+    expect(first.code).to eq(201)
+    expect(first.parsed_response).to include('kind' => 'audio', 'mime_type' => 'audio/wave', 'source' => 'tts')
+    expect(second.parsed_response['hash']).to eq(first.parsed_response['hash'])
+  end
+
+  it 'serves the audio of a word' do
+    response = HTTParty.get("#{api_url}/words/#{@word_id}/audio")
+    expect(response.code).to eq(200)
+    expect(response.headers['content-type']).to eq('audio/wave')
+  end
+
+  it 'generates the audio of a group in the background' do
+    job = HTTParty.post("#{api_url}/groups/#{@group_id}/audio")
+    expect(job.code).to eq(202)
+    sleep 0.5
+    status = HTTParty.get("#{api_url}/audio_jobs/#{job.parsed_response['id']}").parsed_response
+    expect(status).to include('status' => 'completed', 'total' => 1, 'completed' => 1, 'failed' => 0)
+  end
 end
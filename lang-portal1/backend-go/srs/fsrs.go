@@ -0,0 +1,109 @@
+package srs
+
+import (
+	"math"
+	"time"
+)
+
+// FSRS ratings
+const (
+	fsrsAgain = 1
+	fsrsHard  = 2
+	fsrsGood  = 3
+	fsrsEasy  = 4
+)
+
+// Forgetting curve constants, chosen so that R(S, S) = 0.9
+const (
+	fsrsDecay  = -0.5
+	fsrsFactor = 19.0 / 81.0
+)
+
+// fsrsDefaultWeights are the published FSRS-4.5 default parameters
+var fsrsDefaultWeights = [17]float64{
+	0.4872, 1.4003, 3.7145, 13.8206, 5.1618, 1.2298, 0.8975, 0.031,
+	1.6474, 0.1367, 1.0461, 2.1072, 0.0793, 0.3246, 1.587, 0.2272, 2.8755,
+}
+
+// FSRS is the Free Spaced Repetition Scheduler (version 4.5). It models each
+// word's memory stability and difficulty and schedules the next review for
+// when recall is predicted to drop to the desired retention.
+type FSRS struct {
+	Weights [17]float64
+	// Retention is the probability of recall to schedule reviews at
+	Retention float64
+}
+
+// NewFSRS returns the scheduler with default weights at 90% retention
+func NewFSRS() FSRS {
+	return FSRS{Weights: fsrsDefaultWeights, Retention: 0.9}
+}
+
+func (FSRS) Name() string { return "fsrs" }
+
+func (f FSRS) Next(state State, quality int, reviewedAt time.Time) State {
+	w := f.Weights
+	rating := fsrsRating(quality)
+	next := state
+
+	if state.Stability == 0 {
+		next.Stability = w[rating-1]
+		next.Difficulty = f.initialDifficulty(rating)
+	} else {
+		elapsed := math.Max(0, reviewedAt.Sub(state.LastReview).Hours()/24)
+		recall := math.Pow(1+fsrsFactor*elapsed/state.Stability, fsrsDecay)
+
+		if rating == fsrsAgain {
+			next.Stability = w[11] * math.Pow(state.Difficulty, -w[12]) *
+				(math.Pow(state.Stability+1, w[13]) - 1) * math.Exp(w[14]*(1-recall))
+		} else {
+			bonus := 1.0
+			if rating == fsrsHard {
+				bonus = w[15]
+			} else if rating == fsrsEasy {
+				bonus = w[16]
+			}
+			next.Stability = state.Stability * (1 + math.Exp(w[8])*(11-state.Difficulty)*
+				math.Pow(state.Stability, -w[9])*(math.Exp(w[10]*(1-recall))-1)*bonus)
+		}
+
+		difficulty := state.Difficulty - w[6]*float64(rating-fsrsGood)
+		next.Difficulty = clampDifficulty(w[7]*f.initialDifficulty(fsrsEasy) + (1-w[7])*difficulty)
+	}
+
+	if rating == fsrsAgain {
+		if next.Repetitions > 0 {
+			next.Lapses++
+		}
+		next.Repetitions = 0
+	} else {
+		next.Repetitions++
+	}
+
+	interval := next.Stability / fsrsFactor * (math.Pow(f.Retention, 1/fsrsDecay) - 1)
+	next.IntervalDays, next.Due = due(reviewedAt, interval)
+	next.LastReview = reviewedAt
+	return next
+}
+
+func (f FSRS) initialDifficulty(rating int) float64 {
+	return clampDifficulty(f.Weights[4] - float64(rating-fsrsGood)*f.Weights[5])
+}
+
+func clampDifficulty(d float64) float64 {
+	return math.Min(10, math.Max(1, d))
+}
+
+// fsrsRating maps a 0-5 quality to the four FSRS ratings
+func fsrsRating(quality int) int {
+	switch {
+	case quality <= 2:
+		return fsrsAgain
+	case quality == 3:
+		return fsrsHard
+	case quality == 4:
+		return fsrsGood
+	default:
+		return fsrsEasy
+	}
+}
@@ -0,0 +1,51 @@
+package srs
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	sm2InitialEase = 2.5
+	sm2MinEase     = 1.3
+	// sm2PassingQuality is the lowest quality that counts as recalled
+	sm2PassingQuality = 3
+)
+
+// SM2 is the SuperMemo 2 algorithm: intervals of 1 and 6 days, then growing
+// by the word's ease factor, which falls with poor answers
+type SM2 struct{}
+
+func (SM2) Name() string { return "sm2" }
+
+func (SM2) Next(state State, quality int, reviewedAt time.Time) State {
+	next := state
+	if next.Ease == 0 {
+		next.Ease = sm2InitialEase
+	}
+
+	interval := next.IntervalDays
+	if quality >= sm2PassingQuality {
+		switch next.Repetitions {
+		case 0:
+			interval = 1
+		case 1:
+			interval = 6
+		default:
+			interval = math.Round(interval * next.Ease)
+		}
+		next.Repetitions++
+	} else {
+		if next.Repetitions > 0 {
+			next.Lapses++
+		}
+		next.Repetitions = 0
+		interval = 1
+	}
+
+	miss := float64(5 - quality)
+	next.Ease = math.Max(sm2MinEase, next.Ease+0.1-miss*(0.08+miss*0.02))
+	next.IntervalDays, next.Due = due(reviewedAt, interval)
+	next.LastReview = reviewedAt
+	return next
+}
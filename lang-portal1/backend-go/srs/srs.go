@@ -0,0 +1,75 @@
+package srs
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Default is the scheduler used when none is configured
+const Default = "sm2"
+
+// Day is the unit of scheduling intervals
+const Day = 24 * time.Hour
+
+// State is the scheduling state of a word. The zero State is a word that
+// has never been reviewed.
+type State struct {
+	// Repetitions counts the reviews passed in a row
+	Repetitions  int     `json:"repetitions"`
+	IntervalDays float64 `json:"interval_days"`
+	// Ease is the SM-2 ease factor
+	Ease float64 `json:"ease,omitempty"`
+	// Stability and Difficulty are the FSRS memory state
+	Stability  float64 `json:"stability,omitempty"`
+	Difficulty float64 `json:"difficulty,omitempty"`
+	// Lapses counts how often the word was forgotten after being learned
+	Lapses     int       `json:"lapses"`
+	Due        time.Time `json:"due_at"`
+	LastReview time.Time `json:"last_reviewed_at"`
+}
+
+// Scheduler decides when a word is due again
+type Scheduler interface {
+	// Name identifies the algorithm, e.g. "sm2"
+	Name() string
+	// Next returns the state after a review graded quality, from 0 (blackout)
+	// to 5 (perfect), at the given time
+	Next(state State, quality int, reviewedAt time.Time) State
+}
+
+var registry = map[string]Scheduler{}
+
+// Register makes a scheduler available to the portal
+func Register(s Scheduler) {
+	registry[s.Name()] = s
+}
+
+// Get looks up a scheduler by name
+func Get(name string) (Scheduler, bool) {
+	s, ok := registry[strings.ToLower(name)]
+	return s, ok
+}
+
+// Names returns the names of the registered schedulers in order
+func Names() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func init() {
+	Register(SM2{})
+	Register(NewFSRS())
+}
+
+// due schedules a review intervalDays after reviewedAt, rounded to whole days
+// of at least one
+func due(reviewedAt time.Time, intervalDays float64) (float64, time.Time) {
+	days := math.Max(1, math.Round(intervalDays))
+	return days, reviewedAt.Add(time.Duration(days) * Day)
+}
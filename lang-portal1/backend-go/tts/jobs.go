@@ -0,0 +1,105 @@
+package tts
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Job statuses
+const (
+	JobRunning   = "running"
+	JobCompleted = "completed"
+)
+
+// Job tracks a background batch of audio generation
+type Job struct {
+	ID         int        `json:"id"`
+	Status     string     `json:"status"`
+	Total      int        `json:"total"`
+	Completed  int        `json:"completed"`
+	Failed     int        `json:"failed"`
+	Errors     []string   `json:"errors,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// jobRetention is how long finished jobs can still be looked up
+const jobRetention = time.Hour
+
+// Jobs runs batches in the background and keeps their progress in memory.
+// Finished jobs are forgotten after jobRetention.
+type Jobs struct {
+	mu     sync.Mutex
+	nextID int
+	jobs   map[int]*Job
+}
+
+func NewJobs() *Jobs {
+	return &Jobs{jobs: make(map[int]*Job)}
+}
+
+// Start runs fn for each item in a background goroutine and returns a
+// snapshot of the new job
+func (j *Jobs) Start(items []int, fn func(ctx context.Context, item int) error) Job {
+	j.mu.Lock()
+	j.evict(time.Now())
+	j.nextID++
+	job := &Job{
+		ID:        j.nextID,
+		Status:    JobRunning,
+		Total:     len(items),
+		CreatedAt: time.Now(),
+	}
+	j.jobs[job.ID] = job
+	snapshot := *job
+	j.mu.Unlock()
+
+	go func() {
+		for _, item := range items {
+			err := fn(context.Background(), item)
+
+			j.mu.Lock()
+			if err != nil {
+				job.Failed++
+				job.Errors = append(job.Errors, err.Error())
+			} else {
+				job.Completed++
+			}
+			j.mu.Unlock()
+		}
+
+		j.mu.Lock()
+		now := time.Now()
+		job.Status = JobCompleted
+		job.FinishedAt = &now
+		j.mu.Unlock()
+	}()
+
+	return snapshot
+}
+
+// Get returns a snapshot of a job
+func (j *Jobs) Get(id int) (Job, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.evict(time.Now())
+	job, ok := j.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	snapshot := *job
+	snapshot.Errors = append([]string(nil), job.Errors...)
+	return snapshot, true
+}
+
+// evict forgets the jobs that finished more than jobRetention before now.
+// The caller holds j.mu.
+func (j *Jobs) evict(now time.Time) {
+	for id, job := range j.jobs {
+		if job.FinishedAt != nil && now.Sub(*job.FinishedAt) > jobRetention {
+			delete(j.jobs, id)
+		}
+	}
+}
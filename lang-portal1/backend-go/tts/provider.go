@@ -0,0 +1,146 @@
+package tts
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Request describes the speech to synthesize
+type Request struct {
+	Text     string
+	Language string
+	Voice    string
+}
+
+// Audio is synthesized speech
+type Audio struct {
+	Data     []byte
+	MimeType string
+}
+
+// Provider turns text into speech
+type Provider interface {
+	Synthesize(ctx context.Context, req Request) (*Audio, error)
+}
+
+// HTTPProvider calls a locally hosted speech service that exposes an
+// OpenAI-compatible /v1/audio/speech endpoint, such as the OPEA TTS
+// microservice.
+type HTTPProvider struct {
+	URL    string
+	Client *http.Client
+}
+
+// NewHTTPProvider creates a provider for the speech service at url
+func NewHTTPProvider(url string, timeout time.Duration) *HTTPProvider {
+	return &HTTPProvider{
+		URL:    url,
+		Client: &http.Client{Timeout: timeout},
+	}
+}
+
+func (p *HTTPProvider) Synthesize(ctx context.Context, req Request) (*Audio, error) {
+	body, err := json.Marshal(map[string]string{
+		"input":           req.Text,
+		"voice":           req.Voice,
+		"language":        req.Language,
+		"response_format": "mp3",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.Client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("speech service returned %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("speech service returned no audio")
+	}
+
+	// Services that do not label the audio are trusted to return the mp3
+	// that was asked for
+	mimeType := resp.Header.Get("Content-Type")
+	if mimeType == "" || strings.HasPrefix(mimeType, "application/octet-stream") {
+		mimeType = "audio/mpeg"
+	}
+	return &Audio{Data: data, MimeType: mimeType}, nil
+}
+
+// FakeProvider produces a short WAV tone whose pitch is derived from the
+// request, so the same text and voice always yield the same bytes. It is
+// meant for tests and for running the portal without a speech service.
+type FakeProvider struct{}
+
+const fakeSampleRate = 8000
+
+func (FakeProvider) Synthesize(ctx context.Context, req Request) (*Audio, error) {
+	h := fnv.New32a()
+	h.Write([]byte(req.Language + "\x00" + req.Voice + "\x00" + req.Text))
+	frequency := 220 + float64(h.Sum32()%660)
+
+	// One tenth of a second per character, at least half a second
+	samples := fakeSampleRate / 10 * len([]rune(req.Text))
+	if samples < fakeSampleRate/2 {
+		samples = fakeSampleRate / 2
+	}
+
+	pcm := make([]byte, samples)
+	for i := range pcm {
+		pcm[i] = byte(128 + 64*math.Sin(2*math.Pi*frequency*float64(i)/fakeSampleRate))
+	}
+
+	// 8-bit mono PCM WAV header
+	var buf bytes.Buffer
+	buf.WriteString("RIFF")
+	binary.Write(&buf, binary.LittleEndian, uint32(36+len(pcm)))
+	buf.WriteString("WAVEfmt ")
+	binary.Write(&buf, binary.LittleEndian, uint32(16))
+	binary.Write(&buf, binary.LittleEndian, uint16(1))
+	binary.Write(&buf, binary.LittleEndian, uint16(1))
+	binary.Write(&buf, binary.LittleEndian, uint32(fakeSampleRate))
+	binary.Write(&buf, binary.LittleEndian, uint32(fakeSampleRate))
+	binary.Write(&buf, binary.LittleEndian, uint16(1))
+	binary.Write(&buf, binary.LittleEndian, uint16(8))
+	buf.WriteString("data")
+	binary.Write(&buf, binary.LittleEndian, uint32(len(pcm)))
+	buf.Write(pcm)
+
+	return &Audio{Data: buf.Bytes(), MimeType: "audio/wave"}, nil
+}
+
+// Service is the configured provider together with the voice used for
+// generated word audio and the tracker for background batches
+type Service struct {
+	Provider Provider
+	Voice    string
+	Jobs     *Jobs
+}
+
+func NewService(provider Provider, voice string) *Service {
+	return &Service{Provider: provider, Voice: voice, Jobs: NewJobs()}
+}